@@ -0,0 +1,178 @@
+package http
+
+import (
+	"barista/internal/modules"
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Admin struct {
+	Ledger *modules.LedgerHandler
+	Cafe   *modules.CafeHandler
+}
+
+func (h Admin) RunReconciliation(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, ReportTimeOut)
+	defer cancel()
+
+	run, err := h.Ledger.Reconcile(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to reconcile ledger. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func (h Admin) ReconciliationRuns(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	runs, err := h.Ledger.GetRuns(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reconciliation runs. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
+}
+
+func (h Admin) ReconciliationReport(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	runID, err := strconv.Atoi(c.Query("run_id"))
+	if err != nil {
+		log.GetLog().Errorf("Invalid run id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	run, err := h.Ledger.GetRun(ctx, int32(runID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reconciliation report. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func (h Admin) RunRecommendations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, ReportTimeOut)
+	defer cancel()
+
+	run, err := h.Cafe.RecomputeRecommendations(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to recompute recommendations. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func (h Admin) RecommendationRun(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	run, err := h.Cafe.LatestRecommendationRun(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get recommendation run. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"run": run})
+}
+
+func (h Admin) ReviewQueue(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	page, _ := strconv.Atoi(c.Query("page"))
+
+	queue, err := h.Cafe.ReviewQueue(ctx, page)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review queue. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+type RequestModerateReview struct {
+	ReviewID int32 `json:"review_id"`
+	Approve  bool  `json:"approve"`
+}
+
+func (h Admin) ModerateReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestModerateReview
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	err = h.Cafe.ModerateReview(ctx, req.ReviewID, req.Approve)
+	if err != nil {
+		log.GetLog().Errorf("Unable to moderate review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h Admin) ReviewPhotoQueue(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	page, _ := strconv.Atoi(c.Query("page"))
+
+	queue, err := h.Cafe.ReviewPhotoQueue(ctx, page)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review photo queue. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+type RequestModerateReviewPhoto struct {
+	ImageID string `json:"image"`
+	Approve bool   `json:"approve"`
+}
+
+func (h Admin) ModerateReviewPhoto(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestModerateReviewPhoto
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	err = h.Cafe.ModerateReviewPhoto(ctx, req.ImageID, req.Approve)
+	if err != nil {
+		log.GetLog().Errorf("Unable to moderate review photo. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -6,10 +6,12 @@ import (
 	"barista/pkg/log"
 	"barista/pkg/models"
 	"barista/pkg/repo"
+	"barista/pkg/utils"
 	"context"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"go.uber.org/atomic"
@@ -21,7 +23,6 @@ import (
 
 type Cafe struct {
 	Handler     *modules.CafeHandler
-	Rating      repo.RatingsRepo
 	ImageRepo   repo.ImageRepo
 	FirstSearch *atomic.Bool
 }
@@ -58,10 +59,23 @@ func (h Cafe) Create(c *gin.Context) {
 }
 
 type RequestSearchCafe struct {
-	Name     string `json:"name"`
-	Province string `json:"province"`
-	City     string `json:"city"`
-	Category string `json:"category"`
+	Name       string                   `json:"name"`
+	Province   string                   `json:"province"`
+	City       string                   `json:"city"`
+	Category   string                   `json:"category"`
+	Categories []models.CafeCategory    `json:"categories"`
+	Amenities  []models.AmenityCategory `json:"amenities"`
+	MinPrice   float64                  `json:"min_price"`
+	MaxPrice   float64                  `json:"max_price"`
+	MinRating  float64                  `json:"min_rating"`
+	OpenNow    bool                     `json:"open_now"`
+	HasEvents  bool                     `json:"has_events"`
+	Lat        *float64                 `json:"lat"`
+	Lng        *float64                 `json:"lng"`
+	InMyCity   bool                     `json:"in_my_city"`
+	Sort       models.SearchSort        `json:"sort"`
+	Cursor     string                   `json:"cursor"`
+	Limit      int                      `json:"limit"`
 }
 
 func (h Cafe) SearchCafe(c *gin.Context) {
@@ -76,9 +90,41 @@ func (h Cafe) SearchCafe(c *gin.Context) {
 		return
 	}
 
-	cafes, err := h.Handler.SearchCafe(ctx, req.Name, req.Province, req.City, req.Category)
+	if req.Category != "" {
+		req.Categories = append(req.Categories, models.CafeCategory(req.Category))
+	}
+
+	var userID int32
+	if userIDValue, exists := c.Get("userID"); exists {
+		userID = userIDValue.(int32)
+	}
+
+	result, err := h.Handler.SearchCafe(ctx, &models.CafeSearchFilter{
+		UserID:     userID,
+		Query:      req.Name,
+		Province:   cast.ToInt(req.Province),
+		City:       cast.ToInt(req.City),
+		Categories: req.Categories,
+		Amenities:  req.Amenities,
+		MinPrice:   req.MinPrice,
+		MaxPrice:   req.MaxPrice,
+		MinRating:  req.MinRating,
+		OpenNow:    req.OpenNow,
+		HasEvents:  req.HasEvents,
+		Lat:        req.Lat,
+		Lng:        req.Lng,
+		InMyCity:   req.InMyCity,
+		Sort:       req.Sort,
+		Cursor:     req.Cursor,
+		Limit:      req.Limit,
+	})
 	if err != nil {
 		log.GetLog().Errorf("Unable to search cafe. error: %v", err)
+		if errors.ErrSearchFilterInvalid.Is(err) || errors.ErrSearchCursorInvalid.Is(err) ||
+			errors.ErrLocationUnknown.Is(err) || errors.ErrAreaLookupOff.Is(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
 		return
 	}
@@ -122,7 +168,11 @@ func (h Cafe) SearchCafe(c *gin.Context) {
 	// 	h.FirstSearch.Store(false)
 	// }
 
-	c.JSON(http.StatusOK, gin.H{"cafes": cafes})
+	c.JSON(http.StatusOK, gin.H{
+		"cafes":       result.Cafes,
+		"total":       result.Total,
+		"next_cursor": result.NextCursor,
+	})
 }
 
 func (h Cafe) PublicCafeProfile(c *gin.Context) {
@@ -144,7 +194,7 @@ func (h Cafe) PublicCafeProfile(c *gin.Context) {
 		userID = 0
 	}
 
-	cafe, err := h.Handler.PublicCafeProfile(ctx, int32(cafe_id), userID)
+	cafe, err := h.Handler.PublicCafeProfile(ctx, int32(cafe_id), userID, c.ClientIP())
 	if err != nil {
 		log.GetLog().Errorf("Unable to get public cafe profile. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -198,30 +248,22 @@ func (h Cafe) GetComments(c *gin.Context) {
 	defer cancel()
 
 	CafeID := c.Query("cafe_id")
-	Counter := c.Query("counter")
 
 	cafe_id, err := strconv.Atoi(CafeID)
 	if err != nil {
-		log.GetLog().Errorf("Unable to convert userID to int32. error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
-		return
-	}
-
-	counter, err := strconv.Atoi(Counter)
-	if err != nil {
-		log.GetLog().Errorf("Unable to convert userID to int32. error: %v", err)
+		log.GetLog().Errorf("Unable to convert cafeID to int32. error: %v", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
 
-	comments, err := h.Handler.GetComments(ctx, int32(cafe_id), counter)
+	comments, err := h.Handler.GetComments(ctx, int32(cafe_id), reviewQuery(c))
 	if err != nil {
 		log.GetLog().Errorf("Unable to get comments. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"comments": comments})
+	c.JSON(http.StatusOK, gin.H{"comments": comments.Reviews, "next": comments.Next})
 	return
 }
 
@@ -327,23 +369,63 @@ func (h Cafe) PrivateMenu(c *gin.Context) {
 		return
 	}
 
-	categories, menu, cafeName, cafeImage, err := h.Handler.GetMenu(ctx, cafe.ID)
+	categories, menu, cafeName, cafeImage, err := h.Handler.GetMenu(ctx, cafe.ID, models.MenuFilter{All: true})
 	if err != nil {
 		log.GetLog().Errorf("Unable to get menu. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	schedules, err := h.Handler.MenuCategorySchedules(ctx, cafe.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get menu category schedules. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"categories": categories,
-		"menu":       menu,
-		"cafe_id":    cafe.ID,
-		"cafe_name":  cafeName,
-		"cafe_image": cafeImage,
+		"categories":         categories,
+		"menu":               menu,
+		"category_schedules": schedules,
+		"cafe_id":            cafe.ID,
+		"cafe_name":          cafeName,
+		"cafe_image":         cafeImage,
 	})
 	return
 }
 
+// menuFilter reads the comma separated dietary and exclude_allergens query parameters, and the time the
+// menu is wanted for as RFC 3339 in at.
+func menuFilter(c *gin.Context) (models.MenuFilter, bool) {
+	var filter models.MenuFilter
+	if at := c.Query("at"); at != "" {
+		t, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return filter, false
+		}
+		filter.At = t
+	}
+	for _, tag := range strings.Split(c.Query("dietary"), ",") {
+		if tag == "" {
+			continue
+		}
+		if _, ok := models.DietaryTagPersians[models.DietaryTag(tag)]; !ok {
+			return filter, false
+		}
+		filter.Dietary = append(filter.Dietary, models.DietaryTag(tag))
+	}
+	for _, allergen := range strings.Split(c.Query("exclude_allergens"), ",") {
+		if allergen == "" {
+			continue
+		}
+		if _, ok := models.AllergenPersians[models.Allergen(allergen)]; !ok {
+			return filter, false
+		}
+		filter.ExcludeAllergens = append(filter.ExcludeAllergens, models.Allergen(allergen))
+	}
+	return filter, true
+}
+
 func (h Cafe) PublicMenu(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
@@ -357,7 +439,13 @@ func (h Cafe) PublicMenu(c *gin.Context) {
 		return
 	}
 
-	categories, menu, cafeName, cafeImage, err := h.Handler.GetMenu(ctx, int32(cafe_id))
+	filter, ok := menuFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	categories, menu, cafeName, cafeImage, err := h.Handler.GetMenu(ctx, int32(cafe_id), filter)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get menu. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -373,16 +461,16 @@ func (h Cafe) PublicMenu(c *gin.Context) {
 	return
 }
 
-func (h Cafe) EditMenuItem(c *gin.Context) {
+func (h Cafe) SetMenuAvailability(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	var req models.MenuItem
+	var req models.MenuAvailability
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		log.GetLog().WithError(err).Error("Unable to bind json")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
 
@@ -398,25 +486,37 @@ func (h Cafe) EditMenuItem(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.EditMenuItem(ctx, req)
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	item, err := h.Handler.SetMenuAvailability(ctx, userID.(int32), &req)
 	if err != nil {
-		log.GetLog().Errorf("Unable to edit menu item. error: %v", err)
+		log.GetLog().Errorf("Unable to set menu item availability. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	return
+	c.JSON(http.StatusOK, gin.H{"item": item})
 }
 
-func (h Cafe) DeleteMenuItem(c *gin.Context) {
+type RequestMenuItemSold struct {
+	ItemID   int32 `json:"item"`
+	Quantity int32 `json:"quantity"`
+}
+
+func (h Cafe) MenuItemSold(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	Item := c.Query("item")
-	itemID, err := strconv.Atoi(Item)
+	var req RequestMenuItemSold
+
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
-		log.GetLog().Errorf("Invalid item type. error: %v", err)
+		log.GetLog().WithError(err).Error("Unable to bind json")
 		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
@@ -433,65 +533,42 @@ func (h Cafe) DeleteMenuItem(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.DeleteMenuItem(ctx, int32(itemID))
-	if err != nil {
-		log.GetLog().Errorf("Unable to delete menu item. error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	return
-}
-
-func (h Cafe) Home(c *gin.Context) {
-	cafe, comments, events, err := h.Handler.Home(c)
+	err = h.Handler.UseMenuStock(ctx, userID.(int32), req.ItemID, req.Quantity)
 	if err != nil {
+		log.GetLog().Errorf("Unable to use menu item stock. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"cafes": cafe, "comments": comments, "events": events})
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-type RequestReserveEvent struct {
-	EventID int32 `json:"event_id"`
+type RequestMenuCategorySchedule struct {
+	Category models.MenuItemCategory `json:"category"`
+	Schedule *models.MenuSchedule    `json:"schedule"`
 }
 
-func (h Cafe) ReserveEvent(c *gin.Context) {
+// SetMenuCategorySchedule clears the category's schedule when schedule is null.
+func (h Cafe) SetMenuCategorySchedule(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
-	var data RequestReserveEvent
-
-	err := c.ShouldBindJSON(&data)
-	if err != nil {
-		log.GetLog().WithError(err).Error("Unable to bind json")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
-		return
-	}
 
-	userID, exists := c.Get("userID")
-	if !exists {
-		log.GetLog().Errorf("Unable to get user id.")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
-		return
-	}
+	var req RequestMenuCategorySchedule
 
-	err = h.Handler.ReserveEvent(ctx, data.EventID, userID.(int32))
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
-		log.GetLog().Errorf("Unable to add menu item. error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		log.GetLog().WithError(err).Error("Unable to bind json")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	return
-}
-
-func (h Cafe) PrivateCafe(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c, TimeOut)
-	defer cancel()
-
 	role, exists := c.Get("role")
 	if !exists {
 		log.GetLog().Errorf("Unable to get user role")
@@ -511,36 +588,26 @@ func (h Cafe) PrivateCafe(c *gin.Context) {
 		return
 	}
 
-	cafe, err := h.Handler.CafeRepo.GetByOwnerID(ctx, userID.(int32))
-	if err != nil {
-		log.GetLog().Errorf("Unable to get cafe by id. error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
-		return
-	}
-
-	privateCafe, err := h.Handler.PrivateCafeProfile(ctx, cafe.ID)
+	err = h.Handler.SetMenuCategorySchedule(ctx, userID.(int32), req.Category, req.Schedule)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get private cafe profile. error: %v", err)
+		log.GetLog().Errorf("Unable to set menu category schedule. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"cafe": privateCafe,
-	})
-	return
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-func (h Cafe) EditCafe(c *gin.Context) {
+func (h Cafe) EditMenuItem(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	var data modules.RequestEditCafe
+	var req models.MenuItem
 
-	err := c.ShouldBindJSON(&data)
+	err := c.ShouldBindJSON(&req)
 	if err != nil {
 		log.GetLog().WithError(err).Error("Unable to bind json")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error()})
 		return
 	}
 
@@ -556,9 +623,9 @@ func (h Cafe) EditCafe(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.EditCafe(ctx, data)
+	err = h.Handler.EditMenuItem(ctx, req)
 	if err != nil {
-		log.GetLog().Errorf("Unable to edit cafe. error: %v", err)
+		log.GetLog().Errorf("Unable to edit menu item. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -567,16 +634,15 @@ func (h Cafe) EditCafe(c *gin.Context) {
 	return
 }
 
-func (h Cafe) EditEvent(c *gin.Context) {
+func (h Cafe) DeleteMenuItem(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	var data models.Event
-
-	err := c.ShouldBindJSON(&data)
+	Item := c.Query("item")
+	itemID, err := strconv.Atoi(Item)
 	if err != nil {
-		log.GetLog().WithError(err).Error("Unable to bind json")
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		log.GetLog().Errorf("Invalid item type. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
 
@@ -592,9 +658,9 @@ func (h Cafe) EditEvent(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.EditEvent(ctx, data)
+	err = h.Handler.DeleteMenuItem(ctx, int32(itemID))
 	if err != nil {
-		log.GetLog().Errorf("Unable to edit event. error: %v", err)
+		log.GetLog().Errorf("Unable to delete menu item. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -603,18 +669,12 @@ func (h Cafe) EditEvent(c *gin.Context) {
 	return
 }
 
-func (h Cafe) DeleteEvent(c *gin.Context) {
+// ImportMenu reads the menu from the request body in the format given by format, csv or json.
+// mode=replace removes items the file does not have, and dry_run=true only reports what would change.
+func (h Cafe) ImportMenu(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	event_id := c.Query("id")
-	eventID, err := strconv.Atoi(event_id)
-	if err != nil {
-		log.GetLog().Errorf("Invalid event id. error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
-		return
-	}
-
 	role, exists := c.Get("role")
 	if !exists {
 		log.GetLog().Errorf("Unable to get user role")
@@ -627,95 +687,494 @@ func (h Cafe) DeleteEvent(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.DeleteEvent(ctx, int32(eventID))
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	report, err := h.Handler.ImportMenu(ctx, userID.(int32), c.Query("format"), c.Request.Body, models.MenuImportMode(c.Query("mode")), dryRun)
 	if err != nil {
-		log.GetLog().Errorf("Unable to delete event. error: %v", err)
+		log.GetLog().Errorf("Unable to import menu. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	return
+	status := http.StatusOK
+	if len(report.Errors) > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{"report": report})
 }
 
-func (h Cafe) GetFullyBookedDays(c *gin.Context) {
+func (h Cafe) ExportMenu(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", modules.MenuFormatJSON)
+	if format != modules.MenuFormatJSON && format != modules.MenuFormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	items, err := h.Handler.ExportMenu(ctx, userID.(int32))
 	if err != nil {
-		log.GetLog().Errorf("Unable to convert cafe id. error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cafe_id"})
+		log.GetLog().Errorf("Unable to export menu. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	startDate := time.Now()
+	c.Header("Content-Disposition", "attachment; filename=menu."+format)
+	if format == modules.MenuFormatJSON {
+		c.JSON(http.StatusOK, items)
+		return
+	}
 
-	days, err := h.Handler.GetFullyBookedDays(ctx, int32(cafeID), startDate)
+	var file strings.Builder
+	err = utils.WriteMenuCSV(&file, items)
+	if err != nil {
+		log.GetLog().Errorf("Unable to write menu csv. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(file.String()))
+}
+
+func (h Cafe) Home(c *gin.Context) {
+	cafe, reviews, events, err := h.Handler.Home(c)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get fully booked days. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"fully_booked_days": days})
-	return
+	c.JSON(http.StatusOK, gin.H{"cafes": cafe, "reviews": reviews, "events": events})
 }
 
-func (h Cafe) GetTimeSlots(c *gin.Context) {
+func (h Cafe) HomeFeed(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	lat, err := optionalFloat(c, "lat")
 	if err != nil {
-		log.GetLog().Errorf("Unable to convert cafe id. error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cafe_id"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
-
-	dayStr := c.Query("day")
-	day, err := time.Parse("2006-01-02", dayStr)
+	lng, err := optionalFloat(c, "lng")
 	if err != nil {
-		log.GetLog().Errorf("Unable to parse day. error: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid day format"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
 
-	slots, err := h.Handler.GetAvailableTimeSlots(ctx, int32(cafeID), day)
+	var userID int32
+	if userIDValue, exists := c.Get("userID"); exists {
+		userID = userIDValue.(int32)
+	}
+
+	feed, err := h.Handler.HomeFeed(ctx, userID, lat, lng, cast.ToInt(c.Query("city")))
 	if err != nil {
-		log.GetLog().Errorf("Unable to get available time slots. error: %v", err)
+		log.GetLog().Errorf("Unable to get home feed. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"time_slots": slots})
-	return
-}
-
-type RequestReserveCafe struct {
-	CafeID    int32  `json:"cafe_id"`
-	StartTime string `json:"start_time"`
-	EndTime   string `json:"end_time"`
-	People    int32  `json:"people"`
+	c.JSON(http.StatusOK, feed)
 }
 
-func (h Cafe) ReserveCafe(c *gin.Context) {
+func (h Cafe) Trending(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
 
-	var req RequestReserveCafe
-
-	err := c.ShouldBindJSON(&req)
+	lat, err := optionalFloat(c, "lat")
 	if err != nil {
-		log.GetLog().WithError(err).Error("Unable to bind JSON")
 		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
 		return
 	}
-
-	userID, exists := c.Get("userID")
-	if !exists {
-		log.GetLog().Error("Unable to get userID from context")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	lng, err := optionalFloat(c, "lng")
+	if err != nil || (lat == nil) != (lng == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	city, cafes, err := h.Handler.Trending(ctx, cast.ToInt(c.Query("city")), lat, lng, cast.ToInt(c.Query("limit")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get trending cafes. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"city": city, "cafes": cafes})
+}
+
+func (h Cafe) Recommendations(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	cafes, err := h.Handler.Recommendations(ctx, userID.(int32), cast.ToInt(c.Query("limit")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get recommendations. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cafes": cafes})
+}
+
+type RequestReserveEvent struct {
+	EventID int32 `json:"event_id"`
+
+	models.CheckoutOptions
+}
+
+func (h Cafe) ReserveEvent(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+	var data RequestReserveEvent
+
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind json")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	err = h.Handler.ReserveEvent(ctx, data.EventID, userID.(int32), data.CheckoutOptions)
+	if err != nil {
+		log.GetLog().Errorf("Unable to add menu item. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return
+}
+
+func (h Cafe) PrivateCafe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	cafe, err := h.Handler.CafeRepo.GetByOwnerID(ctx, userID.(int32))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by id. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	privateCafe, err := h.Handler.PrivateCafeProfile(ctx, cafe.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get private cafe profile. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cafe": privateCafe,
+	})
+	return
+}
+
+func (h Cafe) EditCafe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var data modules.RequestEditCafe
+
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind json")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	err = h.Handler.EditCafe(ctx, data)
+	if err != nil {
+		log.GetLog().Errorf("Unable to edit cafe. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return
+}
+
+func (h Cafe) EditEvent(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var data models.Event
+
+	err := c.ShouldBindJSON(&data)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind json")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	err = h.Handler.EditEvent(ctx, data)
+	if err != nil {
+		log.GetLog().Errorf("Unable to edit event. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return
+}
+
+func (h Cafe) DeleteEvent(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	event_id := c.Query("id")
+	eventID, err := strconv.Atoi(event_id)
+	if err != nil {
+		log.GetLog().Errorf("Invalid event id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	err = h.Handler.DeleteEvent(ctx, int32(eventID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete event. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return
+}
+
+func (h Cafe) GetFullyBookedDays(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	if err != nil {
+		log.GetLog().Errorf("Unable to convert cafe id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cafe_id"})
+		return
+	}
+
+	startDate := time.Now()
+
+	days, err := h.Handler.GetFullyBookedDays(ctx, int32(cafeID), startDate)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get fully booked days. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"fully_booked_days": days})
+	return
+}
+
+func (h Cafe) GetTimeSlots(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	if err != nil {
+		log.GetLog().Errorf("Unable to convert cafe id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cafe_id"})
+		return
+	}
+
+	dayStr := c.Query("day")
+	day, err := time.Parse("2006-01-02", dayStr)
+	if err != nil {
+		log.GetLog().Errorf("Unable to parse day. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid day format"})
+		return
+	}
+
+	slots, err := h.Handler.GetAvailableTimeSlots(ctx, int32(cafeID), day)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get available time slots. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"time_slots": slots})
+	return
+}
+
+type RequestReserveCafe struct {
+	CafeID    int32  `json:"cafe_id"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	People    int32  `json:"people"`
+
+	models.CheckoutOptions
+}
+
+func (h Cafe) ReserveCafe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReserveCafe
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	startTime, err := time.Parse(time.RFC3339, req.StartTime)
+	if err != nil {
+		log.GetLog().Errorf("Unable to parse start time. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start_time format"})
+		return
+	}
+
+	endTime, err := time.Parse(time.RFC3339, req.EndTime)
+	if err != nil {
+		log.GetLog().Errorf("Unable to parse end time. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end_time format"})
+		return
+	}
+
+	reservation := models.Reservation{
+		UserID:    cast.ToInt32(userID),
+		CafeID:    req.CafeID,
+		StartTime: startTime,
+		EndTime:   endTime,
+		People:    req.People,
+	}
+
+	err = h.Handler.ReserveCafe(ctx, &reservation, req.CheckoutOptions)
+	if err != nil {
+		log.GetLog().Errorf("Unable to reserve cafe. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	return
+}
+
+type RequestReserveCafeGroup struct {
+	CafeID    int32    `json:"cafe_id"`
+	StartTime string   `json:"start_time"`
+	EndTime   string   `json:"end_time"`
+	People    int32    `json:"people"`
+	Invitees  []string `json:"invitees"`
+}
+
+func (h Cafe) ReserveCafeGroup(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReserveCafeGroup
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
@@ -741,15 +1200,168 @@ func (h Cafe) ReserveCafe(c *gin.Context) {
 		People:    req.People,
 	}
 
-	err = h.Handler.ReserveCafe(ctx, &reservation)
+	shares, err := h.Handler.ReserveCafeGroup(ctx, &reservation, req.Invitees)
 	if err != nil {
-		log.GetLog().Errorf("Unable to reserve cafe. error: %v", err)
+		log.GetLog().Errorf("Unable to reserve cafe for group. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-	return
+	c.JSON(http.StatusOK, gin.H{"reservation": reservation, "shares": shares})
+}
+
+type RequestReservationShare struct {
+	ReservationID int32 `json:"reservation_id"`
+}
+
+func (h Cafe) PayReservationShare(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReservationShare
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	confirmed, err := h.Handler.PayReservationShare(ctx, cast.ToInt32(userID), req.ReservationID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to pay reservation share. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"confirmed": confirmed})
+}
+
+func (h Cafe) PendingReservationShares(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	shares, err := h.Handler.PendingReservationShares(ctx, cast.ToInt32(userID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get pending reservation shares. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+func (h Cafe) ReservationShares(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	reservationID, err := strconv.Atoi(c.Query("reservation_id"))
+	if err != nil {
+		log.GetLog().Errorf("Invalid reservation id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	shares, err := h.Handler.ReservationShares(ctx, cast.ToInt32(userID), int32(reservationID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reservation shares. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+func (h Cafe) GetLoyaltyRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	if err != nil {
+		log.GetLog().Errorf("Invalid cafe id. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	rule, err := h.Handler.GetLoyaltyRule(ctx, int32(cafeID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty rule. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loyalty_rule": rule})
+}
+
+func (h Cafe) SetLoyaltyRule(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var rule models.LoyaltyRule
+
+	err := c.ShouldBindJSON(&rule)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	cafe, err := h.Handler.CafeRepo.GetByOwnerID(ctx, userID.(int32))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	rule.CafeID = cafe.ID
+	err = h.Handler.SetLoyaltyRule(ctx, &rule)
+	if err != nil {
+		log.GetLog().Errorf("Unable to set loyalty rule. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"loyalty_rule": rule})
 }
 
 type RequestNearestCafes struct {
@@ -781,6 +1393,38 @@ func (h Cafe) GetNearestCafes(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"cafes": cafes})
 }
 
+func (h Cafe) GeoSearchCafes(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req models.CafeSearchFilter
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	result, err := h.Handler.GeoSearchCafes(ctx, &req)
+	if err != nil {
+		log.GetLog().Errorf("Unable to geo search cafes. error: %v", err)
+		if errors.ErrSearchFilterInvalid.Is(err) || errors.ErrSearchCursorInvalid.Is(err) ||
+			errors.ErrLocationUnknown.Is(err) || errors.ErrAreaLookupOff.Is(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cafes":       result.Cafes,
+		"total":       result.Total,
+		"next_cursor": result.NextCursor,
+	})
+}
+
 func (h Cafe) SetCafeLocation(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
@@ -794,7 +1438,26 @@ func (h Cafe) SetCafeLocation(c *gin.Context) {
 		return
 	}
 
-	err = h.Handler.SetCafeLocation(ctx, &req)
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	err = h.Handler.SetCafeLocation(ctx, userID.(int32), &req)
 	if err != nil {
 		log.GetLog().Errorf("Unable to set cafe location. error: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -804,6 +1467,85 @@ func (h Cafe) SetCafeLocation(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+type RequestHideCafe struct {
+	Hidden bool `json:"hidden"`
+}
+
+func (h Cafe) HideCafe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestHideCafe
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	err = h.Handler.SetCafeHidden(ctx, userID.(int32), req.Hidden)
+	if err != nil {
+		log.GetLog().Errorf("Unable to hide cafe. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h Cafe) DeleteCafe(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	role, exists := c.Get("role")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user role")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	if role.(int32) != 2 {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	err := h.Handler.DeleteCafe(ctx, userID.(int32))
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete cafe. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
 type RequestGetCafeLocation struct {
 	CafeID int32 `json:"cafe_id"`
 }
@@ -1030,7 +1772,7 @@ func (h Cafe) GetFavoriteList(c *gin.Context) {
 	}
 
 	for i, cafe := range favorites {
-		favorites[i].Rating, err = h.Rating.GetCafesRating(ctx, cafe.ID)
+		favorites[i].Rating, err = h.Handler.Reviews.CafeRating(ctx, cafe.ID)
 		if err != nil {
 			log.GetLog().Errorf("Unable to get cafe rating. error: %v", err)
 		}
@@ -1,13 +1,19 @@
 package http
 
 import (
+	"barista/internal/modules"
+	"barista/pkg/errors"
+	"barista/pkg/log"
 	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/cast"
 	"net/http"
 )
 
 type PublicHandler struct {
+	Gazetteer *modules.GazetteerHandler
 }
 
 func (h PublicHandler) HealthCheck(c *gin.Context) {
@@ -16,6 +22,10 @@ func (h PublicHandler) HealthCheck(c *gin.Context) {
 	})
 }
 
+func (h PublicHandler) GetProvinces(c *gin.Context) {
+	c.JSON(http.StatusOK, models.Provinces)
+}
+
 func (h PublicHandler) GetCities(c *gin.Context) {
 	id := c.Query("id")
 	val, ok := models.ProvinceCities[cast.ToInt(id)]
@@ -30,3 +40,100 @@ func (h PublicHandler) GetCities(c *gin.Context) {
 		"cities": val,
 	})
 }
+
+func (h PublicHandler) Locate(c *gin.Context) {
+	lat, err := optionalFloat(c, "lat")
+	if err != nil || lat == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+	lng, err := optionalFloat(c, "lng")
+	if err != nil || lng == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	province, city := utils.LocateArea(*lat, *lng)
+	if province == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": errors.ErrLocationUnknown.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"province": province,
+		"city":     city,
+	})
+}
+
+func (h PublicHandler) GazetteerProvinces(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	provinces, err := h.Gazetteer.Provinces(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get gazetteer provinces. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"provinces": provinces})
+}
+
+func (h PublicHandler) GazetteerCities(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cities, err := h.Gazetteer.ProvinceCities(ctx, cast.ToInt(c.Query("province_id")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get gazetteer cities. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cities": cities})
+}
+
+func (h PublicHandler) GazetteerCity(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	city, err := h.Gazetteer.City(ctx, cast.ToInt(c.Query("id")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get gazetteer city. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"city": city})
+}
+
+func (h PublicHandler) GazetteerSearch(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	provinces, cities, err := h.Gazetteer.Search(ctx, c.Query("q"), cast.ToInt(c.Query("limit")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to search gazetteer. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"provinces": provinces,
+		"cities":    cities,
+	})
+}
+
+func (h PublicHandler) GazetteerNeighbours(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cities, byOutline, err := h.Gazetteer.Neighbours(ctx, cast.ToInt(c.Query("id")), cast.ToInt(c.Query("limit")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get neighbouring cities. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cities": cities, "by_outline": byOutline})
+}
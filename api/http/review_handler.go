@@ -0,0 +1,318 @@
+package http
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (h Cafe) WriteReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req models.Review
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := h.Handler.WriteReview(ctx, cast.ToInt32(userID), &req)
+	if err != nil {
+		log.GetLog().Errorf("Unable to write review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"review": review})
+}
+
+// reviewQuery reads the sort, cursor and size query parameters of a review listing.
+func reviewQuery(c *gin.Context) models.ReviewQuery {
+	size, _ := strconv.Atoi(c.Query("size"))
+	return models.ReviewQuery{Sort: models.ReviewSort(c.Query("sort")), Cursor: c.Query("cursor"), Limit: size}
+}
+
+func (h Cafe) GetReviews(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+	reviews, err := h.Handler.CafeReviews(ctx, int32(cafeID), reviewQuery(c))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reviews. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reviews)
+}
+
+func (h Cafe) DeleteReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	cafeID, err := strconv.Atoi(c.Query("cafe_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err = h.Handler.DeleteReview(ctx, cast.ToInt32(userID), int32(cafeID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+type RequestReplyReview struct {
+	ReviewID int32  `json:"review_id"`
+	Reply    string `json:"reply"`
+}
+
+func (h Cafe) ReplyToReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReplyReview
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := h.Handler.ReplyToReview(ctx, cast.ToInt32(userID), req.ReviewID, req.Reply)
+	if err != nil {
+		log.GetLog().Errorf("Unable to reply to review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"review": review})
+}
+
+func (h Cafe) DeleteReply(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	reviewID, err := strconv.Atoi(c.Query("review_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err = h.Handler.DeleteReply(ctx, cast.ToInt32(userID), int32(reviewID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete reply. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (h Cafe) ReviewHistory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	reviewID, err := strconv.Atoi(c.Query("review_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	role, _ := c.Get("role")
+
+	history, err := h.Handler.ReviewHistory(ctx, cast.ToInt32(userID), models.Role(cast.ToInt32(role)), int32(reviewID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review history. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+type RequestReportReview struct {
+	ReviewID int32  `json:"review_id"`
+	Reason   string `json:"reason"`
+}
+
+func (h Cafe) ReportReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReportReview
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err = h.Handler.ReportReview(ctx, cast.ToInt32(userID), req.ReviewID, req.Reason)
+	if err != nil {
+		log.GetLog().Errorf("Unable to report review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+type RequestAddReviewPhoto struct {
+	CafeID  int32  `json:"cafe_id"`
+	ImageID string `json:"image"`
+}
+
+// AddReviewPhoto takes the file id returned by the image upload.
+func (h Cafe) AddReviewPhoto(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestAddReviewPhoto
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+	if _, err := primitive.ObjectIDFromHex(req.ImageID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	photo, err := h.Handler.AddReviewPhoto(ctx, cast.ToInt32(userID), req.CafeID, req.ImageID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to add review photo. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"photo": photo})
+}
+
+func (h Cafe) DeleteReviewPhoto(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	err := h.Handler.DeleteReviewPhoto(ctx, cast.ToInt32(userID), c.Query("image"))
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete review photo. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+type RequestVoteReview struct {
+	ReviewID int32 `json:"review_id"`
+	Helpful  *bool `json:"helpful"`
+}
+
+// VoteReview takes back the user's vote when helpful is null.
+func (h Cafe) VoteReview(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestVoteReview
+
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().WithError(err).Error("Unable to bind JSON")
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Error("Unable to get userID from context")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	review, err := h.Handler.VoteReview(ctx, cast.ToInt32(userID), req.ReviewID, req.Helpful)
+	if err != nil {
+		log.GetLog().Errorf("Unable to vote on review. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"review": review})
+}
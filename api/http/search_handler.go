@@ -0,0 +1,97 @@
+package http
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cast"
+)
+
+// optionalFloat reads a query parameter that may be absent; a present but malformed value is an error.
+func optionalFloat(c *gin.Context, key string) (*float64, error) {
+	raw, ok := c.GetQuery(key)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (h Cafe) Suggest(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	lat, err := optionalFloat(c, "lat")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+	lng, err := optionalFloat(c, "lng")
+	if err != nil || (lat == nil) != (lng == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errors.ErrBadRequest.Error().Error()})
+		return
+	}
+
+	var userID int32
+	if userIDValue, exists := c.Get("userID"); exists {
+		userID = userIDValue.(int32)
+	}
+
+	suggestions, err := h.Handler.Suggest(ctx, userID, c.Query("q"), lat, lng, cast.ToInt(c.Query("limit")))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get suggestions. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+}
+
+func (h Cafe) RecentSearches(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	searches, err := h.Handler.RecentSearches(ctx, userID.(int32))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get recent searches. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"searches": searches})
+}
+
+func (h Cafe) ClearRecentSearches(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get user id")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error().Error()})
+		return
+	}
+
+	err := h.Handler.ClearRecentSearches(ctx, userID.(int32))
+	if err != nil {
+		log.GetLog().Errorf("Unable to clear recent searches. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
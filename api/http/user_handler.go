@@ -17,7 +17,8 @@ import (
 )
 
 var (
-	TimeOut = 5 * time.Second
+	TimeOut       = 5 * time.Second
+	ReportTimeOut = 2 * time.Minute
 )
 
 type User struct {
@@ -155,6 +156,13 @@ func (u User) UserProfile(c *gin.Context) {
 		return
 	}
 
+	referral, err := u.Handler.ReferralStats(ctx, user.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get referral stats. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	if role.(int32) == 2 {
 		c.JSON(http.StatusOK, gin.H{
 			"status":       "ok",
@@ -165,9 +173,17 @@ func (u User) UserProfile(c *gin.Context) {
 			"sex":          user.Sex,
 			"bank_account": user.BankAccount,
 			"national_id":  user.NationalID,
+			"referral":     referral,
 		})
 		return
 	}
+	loyalty, err := u.Handler.LoyaltyCards(ctx, user.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty cards. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"status":     "ok",
 		"first_name": user.FirstName,
@@ -175,10 +191,108 @@ func (u User) UserProfile(c *gin.Context) {
 		"email":      user.Email,
 		"phone":      user.Phone,
 		"sex":        user.Sex,
+		"loyalty":    loyalty,
+		"referral":   referral,
 	})
 	return
 }
 
+func (u User) LoyaltyHistory(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get token ID.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error()})
+		return
+	}
+
+	history, err := u.Handler.LoyaltyHistory(ctx, cast.ToInt32(userID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty history. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": history})
+}
+
+func (u User) Notifications(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get token ID.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error()})
+		return
+	}
+
+	notifications, unread, err := u.Handler.Notifications(ctx, cast.ToInt32(userID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to get notifications. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notifications": notifications, "unread": unread})
+}
+
+type RequestReadNotifications struct {
+	IDs []int32 `json:"ids"`
+}
+
+func (u User) ReadNotifications(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	var req RequestReadNotifications
+	err := c.ShouldBindJSON(&req)
+	if err != nil {
+		log.GetLog().Errorf("Unable to bind json. error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get token ID.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error()})
+		return
+	}
+
+	err = u.Handler.ReadNotifications(ctx, cast.ToInt32(userID), req.IDs)
+	if err != nil {
+		log.GetLog().Errorf("Unable to read notifications. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (u User) CreateReferralCode(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c, TimeOut)
+	defer cancel()
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		log.GetLog().Errorf("Unable to get token ID.")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrUnableToGetUser.Error()})
+		return
+	}
+
+	code, err := u.Handler.CreateReferralCode(ctx, cast.ToInt32(userID))
+	if err != nil {
+		log.GetLog().Errorf("Unable to create referral code. error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errors.ErrInternalError.Error().Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"code": code})
+}
+
 func (u User) EditProfile(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c, TimeOut)
 	defer cancel()
@@ -0,0 +1,7 @@
+// Package assets embeds the static data files, so the binary does not depend on its working directory.
+package assets
+
+import "embed"
+
+//go:embed ostan.json shahr.json boundaries.geojson districts.json
+var Files embed.FS
@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/utils"
+	"context"
+	"time"
+)
+
+func nextDailyRun(now time.Time, hour int) time.Time {
+	now = now.In(utils.IranLocation)
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, utils.IranLocation)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// runDaily runs job every day at the given hour of Iran time.
+func runDaily(name string, hour int, timeout time.Duration, job func(ctx context.Context)) {
+	go func() {
+		for {
+			next := nextDailyRun(time.Now(), hour)
+			log.GetLog(true).WithField("Job", name).WithField("At", next).Info("Scheduling job")
+			time.Sleep(time.Until(next))
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			job(ctx)
+			cancel()
+		}
+	}()
+}
+
+// runEvery runs job on a fixed interval, starting one interval after startup.
+func runEvery(name string, interval time.Duration, timeout time.Duration, job func(ctx context.Context)) {
+	log.GetLog(true).WithField("Job", name).WithField("Every", interval).Info("Scheduling job")
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			job(ctx)
+			cancel()
+		}
+	}()
+}
@@ -10,28 +10,38 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 const (
-	commentsLimit = 5
+	groupReservationHold = 30 * time.Minute
 )
 
 type CafeHandler struct {
-	CafeRepo        repo.CafesRepo
-	Rating          repo.RatingsRepo
-	CommentRepo     repo.CommentsRepo
-	ImageRepo       repo.ImageRepo
-	EventRepo       repo.EventRepo
-	UserRepo        repo.UsersRepo
-	ReservationRepo repo.ReservationRepo
-	MenuItemRepo    repo.MenuItemsRepo
-	PaymentRepo     repo.Transaction
-	LocationsRepo   repo.LocationsRepo
-	FavoriteRepo    repo.FavoritesRepo
-	Redis           *redis.Client
+	CafeRepo           repo.CafesRepo
+	Reviews            repo.ReviewsRepo
+	ReviewPhotos       repo.ReviewPhotosRepo
+	NotificationRepo   repo.NotificationsRepo
+	ImageRepo          repo.ImageRepo
+	ImageFiles         repo.ImageFilesRepo
+	EventRepo          repo.EventRepo
+	UserRepo           repo.UsersRepo
+	ReservationRepo    repo.ReservationRepo
+	MenuItemRepo       repo.MenuItemsRepo
+	PaymentRepo        repo.Transaction
+	LocationsRepo      repo.LocationsRepo
+	GeoIndex           repo.GeoIndexRepo
+	Suggestions        repo.SuggestionsRepo
+	FeedCache          repo.FeedCacheRepo
+	RecommendationRepo repo.RecommendationsRepo
+	Popularity         repo.PopularityRepo
+	FavoriteRepo       repo.FavoritesRepo
+	LoyaltyRepo        repo.LoyaltyRepo
+	Referrals          ReferralHandler
+	Redis              *redis.Client
 }
 
 func (c CafeHandler) Create(ctx context.Context, cafe *models.Cafe) error {
@@ -40,6 +50,7 @@ func (c CafeHandler) Create(ctx context.Context, cafe *models.Cafe) error {
 		log.GetLog().Errorf("Unable to create cafe. error: %v", err)
 		return err
 	}
+	c.refreshSearchDocument(ctx, cafeID)
 	for _, photoID := range cafe.Images {
 		err = c.ImageRepo.Create(ctx, &models.Image{
 			ID:        photoID,
@@ -58,24 +69,25 @@ func (c CafeHandler) GetCafeByID() {
 	panic("implement me")
 }
 
-func (c CafeHandler) SearchCafe(ctx context.Context, name string, address string, location string, category string) ([]models.Cafe, error) {
-	cafes, err := c.CafeRepo.SearchCafe(ctx, name, address, location, category)
+func (c CafeHandler) SearchCafe(ctx context.Context, filter *models.CafeSearchFilter) (*models.CafeSearchResult, error) {
+	query := filter.Query
+	err := prepareSearchFilter(filter)
 	if err != nil {
-		log.GetLog().Errorf("Unable to search cafe. error: %v", err)
 		return nil, err
 	}
 
-	for i, cafe := range cafes {
-		c22, err := c.LocationsRepo.GetCafeLocation(ctx, cafe.ID)
-		if err != nil {
-			log.GetLog().Errorf("Unable to get cafe rating. error: %v", err)
-			continue
-		}
-		cafes[i].Location = c22
+	result, err := c.CafeRepo.SearchCafe(ctx, filter)
+	if err != nil {
+		log.GetLog().Errorf("Unable to search cafe. error: %v", err)
+		return nil, err
+	}
+	if filter.Cursor == "" {
+		c.rememberSearch(ctx, filter.UserID, query)
 	}
+	cafes := result.Cafes
 
 	for i, cafe := range cafes {
-		cafes[i].Rating, err = c.Rating.GetCafesRating(ctx, cafe.ID)
+		cafes[i].Rating, err = c.Reviews.CafeRating(ctx, cafe.ID)
 		if err != nil {
 			log.GetLog().Errorf("Unable to get cafe rating. error: %v", err)
 		}
@@ -93,7 +105,7 @@ func (c CafeHandler) SearchCafe(ctx context.Context, name string, address string
 		}
 	}
 
-	return cafes, err
+	return result, nil
 }
 
 type PublicCafeProvinceCity struct {
@@ -102,7 +114,7 @@ type PublicCafeProvinceCity struct {
 	Description      string                   `json:"description"`
 	OpeningTime      int8                     `json:"opening_time"`
 	ClosingTime      int8                     `json:"closing_time"`
-	Comments         []CommentWithUserName    `json:"comments"`
+	Reviews          []*models.Review         `json:"reviews"`
 	Rating           float64                  `json:"rating"`
 	Images           []string                 `json:"photos"`
 	Events           []models.Event           `json:"events"`
@@ -114,34 +126,28 @@ type PublicCafeProvinceCity struct {
 	CityName         string                   `json:"city_name"`
 	ReservationPrice float64                  `json:"reservation_price"`
 	Favorite         bool                     `json:"favorite"`
+	RatingStats      *models.RatingStats      `json:"rating_stats"`
+	VisitorPhotos    []models.ReviewPhoto     `json:"visitor_photos"`
+	SimilarCafes     []models.CafeCard        `json:"similar_cafes"`
 }
 
-func (c CafeHandler) PublicCafeProfile(ctx context.Context, cafeID int32, userID int32) (*PublicCafeProvinceCity, error) {
+func (c CafeHandler) PublicCafeProfile(ctx context.Context, cafeID int32, userID int32, clientIP string) (*PublicCafeProvinceCity, error) {
 	cafe, err := c.CafeRepo.GetByID(ctx, int32(cafeID))
 	if err != nil {
 		log.GetLog().Errorf("Cafe id does not exist. error: %v", err)
 		return nil, err
 	}
+	if cafe.Hidden {
+		return nil, errors.ErrCafeNotFound.Error()
+	}
+	c.recordView(ctx, cafe, userID, clientIP)
 
-	comments, err := c.CommentRepo.GetAllByCafeID(ctx, int32(cafeID))
+	reviews, err := c.Reviews.GetByCafeID(ctx, cafeID, models.ReviewQuery{Sort: models.ReviewSortNewest, Limit: reviewsPageSize})
 	if err != nil {
-		log.GetLog().Errorf("Unable to get all comments. error: %v", err)
+		log.GetLog().Errorf("Unable to get reviews. error: %v", err)
 		return nil, err
 	}
 
-	commentsWithNames := make([]CommentWithUserName, len(comments))
-	for i, comment := range comments {
-		userName, err := c.UserRepo.GetByID(ctx, comment.UserID)
-		if err != nil {
-			log.GetLog().Errorf("Unable to get user name for user ID %d: %v", comment.UserID, err)
-			return nil, err
-		}
-
-		commentsWithNames[i].Comment = comment
-		commentsWithNames[i].UserFirstName = userName.FirstName
-		commentsWithNames[i].UserLastName = userName.LastName
-	}
-
 	events, err := c.EventRepo.GetEventsByCafeID(ctx, int32(cafeID))
 	if err != nil {
 		log.GetLog().Errorf("Unable to get events by cafe id. error: %v", err)
@@ -162,9 +168,16 @@ func (c CafeHandler) PublicCafeProfile(ctx context.Context, cafeID int32, userID
 		}
 	}
 
-	cafe.Rating, err = c.Rating.GetCafesRating(ctx, int32(cafeID))
+	ratingStats, err := c.Reviews.RatingStats(ctx, cafeID, time.Now().Add(-ratingTrendWindow))
 	if err != nil {
-		log.GetLog().Errorf("Unable to get rating by cafe id. error: %v", err)
+		log.GetLog().Errorf("Unable to get rating stats by cafe id. error: %v", err)
+		return nil, err
+	}
+	cafe.Rating = ratingStats.Average
+
+	visitorPhotos, err := c.ReviewPhotos.Gallery(ctx, cafeID, visitorGallerySize)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get visitor photos by cafe id. error: %v", err)
 		return nil, err
 	}
 
@@ -193,13 +206,19 @@ func (c CafeHandler) PublicCafeProfile(ctx context.Context, cafeID int32, userID
 		isFavorite = false
 	}
 
+	similarCafes, err := c.SimilarCafes(ctx, cafe)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get similar cafes. error: %v", err)
+		similarCafes = []models.CafeCard{}
+	}
+
 	publicCafe := PublicCafeProvinceCity{
 		ID:               cafe.ID,
 		Name:             cafe.Name,
 		Description:      cafe.Description,
 		OpeningTime:      cafe.OpeningTime,
 		ClosingTime:      cafe.ClosingTime,
-		Comments:         commentsWithNames,
+		Reviews:          reviews.Reviews,
 		Rating:           cafe.Rating,
 		Images:           cafe.Images,
 		Events:           cafe.Events,
@@ -211,6 +230,9 @@ func (c CafeHandler) PublicCafeProfile(ctx context.Context, cafeID int32, userID
 		CityName:         models.Cities[cityNum-1].Name,
 		ReservationPrice: cafe.ReservationPrice,
 		Favorite:         isFavorite,
+		RatingStats:      ratingStats,
+		VisitorPhotos:    visitorPhotos,
+		SimilarCafes:     similarCafes,
 	}
 
 	return &publicCafe, nil
@@ -222,7 +244,7 @@ type PrivateCafeProvinceCity struct {
 	Description      string                   `json:"description"`
 	OpeningTime      int8                     `json:"opening_time"`
 	ClosingTime      int8                     `json:"closing_time"`
-	Comments         []CommentWithUserName    `json:"comments"`
+	Reviews          []*models.Review         `json:"reviews"`
 	Rating           float64                  `json:"rating"`
 	Images           []string                 `json:"photos"`
 	Events           []models.Event           `json:"events"`
@@ -242,25 +264,12 @@ func (c CafeHandler) PrivateCafeProfile(ctx context.Context, cafeID int32) (*Pri
 		return nil, err
 	}
 
-	comments, err := c.CommentRepo.GetAllByCafeID(ctx, int32(cafeID))
+	reviews, err := c.Reviews.GetByCafeID(ctx, cafeID, models.ReviewQuery{Sort: models.ReviewSortNewest, Limit: reviewsPageSize})
 	if err != nil {
-		log.GetLog().Errorf("Unable to get all comments. error: %v", err)
+		log.GetLog().Errorf("Unable to get reviews. error: %v", err)
 		return nil, err
 	}
 
-	commentsWithNames := make([]CommentWithUserName, len(comments))
-	for i, comment := range comments {
-		userName, err := c.UserRepo.GetByID(ctx, comment.UserID)
-		if err != nil {
-			log.GetLog().Errorf("Unable to get user name for user ID %d: %v", comment.UserID, err)
-			return nil, err
-		}
-
-		commentsWithNames[i].Comment = comment
-		commentsWithNames[i].UserFirstName = userName.FirstName
-		commentsWithNames[i].UserLastName = userName.LastName
-	}
-
 	events, err := c.EventRepo.GetEventsByCafeID(ctx, int32(cafeID))
 	if err != nil {
 		log.GetLog().Errorf("Unable to get events by cafe id. error: %v", err)
@@ -281,7 +290,7 @@ func (c CafeHandler) PrivateCafeProfile(ctx context.Context, cafeID int32) (*Pri
 		}
 	}
 
-	cafe.Rating, err = c.Rating.GetCafesRating(ctx, int32(cafeID))
+	cafe.Rating, err = c.Reviews.CafeRating(ctx, int32(cafeID))
 	if err != nil {
 		log.GetLog().Errorf("Unable to get rating by cafe id. error: %v", err)
 		return nil, err
@@ -307,7 +316,7 @@ func (c CafeHandler) PrivateCafeProfile(ctx context.Context, cafeID int32) (*Pri
 		Description:      cafe.Description,
 		OpeningTime:      cafe.OpeningTime,
 		ClosingTime:      cafe.ClosingTime,
-		Comments:         commentsWithNames,
+		Reviews:          reviews.Reviews,
 		Rating:           cafe.Rating,
 		Images:           cafe.Images,
 		Events:           cafe.Events,
@@ -323,77 +332,41 @@ func (c CafeHandler) PrivateCafeProfile(ctx context.Context, cafeID int32) (*Pri
 	return &privateCafe, nil
 }
 
-func (c CafeHandler) AddComment(ctx context.Context, cafeID int32, userID string, comment string) (CommentWithUserName, error) {
-	CWU := CommentWithUserName{}
-
+func (c CafeHandler) AddComment(ctx context.Context, cafeID int32, userID string, comment string) (*models.Review, error) {
 	user_id, err := strconv.Atoi(userID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to convert user id to int32. error: %v", err)
-		return CWU, err
+		return nil, err
 	}
 
-	commentID := rand.Int31()
-
-	AddedComment := &models.Comment{
-		ID:      commentID,
-		UserID:  int32(user_id),
-		CafeID:  cafeID,
-		Comment: comment,
-		Date:    time.Now().UTC(),
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil, errors.ErrReviewInvalid.Error()
 	}
 
-	err = c.CommentRepo.Create(ctx, AddedComment)
+	status, flags, err := c.moderateReview(ctx, int32(user_id), cafeID, comment)
 	if err != nil {
-		log.GetLog().Errorf("Unable to add comment. error: %v", err)
-		return CWU, err
+		return nil, err
 	}
 
-	user, err := c.UserRepo.GetByID(ctx, int32(user_id))
+	err = c.Reviews.SetComment(ctx, int32(user_id), cafeID, comment, status, flags)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get user name for user id %d: %v", user_id, err)
-		return CWU, err
+		log.GetLog().Errorf("Unable to add comment. error: %v", err)
+		return nil, err
 	}
 
-	CWU.Comment = AddedComment
-	CWU.UserFirstName = user.FirstName
-	CWU.UserLastName = user.LastName
-
-	return CWU, err
-}
-
-type CommentWithUserName struct {
-	*models.Comment
-	UserFirstName string `json:"first_name"`
-	UserLastName  string `json:"last_name"`
+	return c.Reviews.GetByUserAndCafe(ctx, int32(user_id), cafeID)
 }
 
-func (c CafeHandler) GetComments(ctx context.Context, cafeID int32, counter int) ([]CommentWithUserName, error) {
-	offset := (counter - 1) * commentsLimit
-	comments, err := c.CommentRepo.GetLast(ctx, cafeID, commentsLimit, offset)
+func (c CafeHandler) GetComments(ctx context.Context, cafeID int32, query models.ReviewQuery) (*models.ReviewPage, error) {
+	query.WithComment = true
+	comments, err := c.CafeReviews(ctx, cafeID, query)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get 5 last comments. error: %v", comments)
+		log.GetLog().Errorf("Unable to get comments. error: %v", err)
 		return nil, err
 	}
 
-	var commentsWithNames []CommentWithUserName
-
-	for _, comment := range comments {
-		userName, err := c.UserRepo.GetByID(ctx, comment.UserID)
-		if err != nil {
-			log.GetLog().Errorf("Unable to get user name for user ID %d: %v", comment.UserID, err)
-			return nil, err
-		}
-
-		commentWithUserName := CommentWithUserName{
-			Comment:       comment,
-			UserFirstName: userName.FirstName,
-			UserLastName:  userName.LastName,
-		}
-
-		commentsWithNames = append(commentsWithNames, commentWithUserName)
-	}
-
-	return commentsWithNames, err
+	return comments, nil
 }
 
 func (c CafeHandler) CreateEvent(ctx context.Context, event models.Event) (int32, error) {
@@ -473,11 +446,21 @@ func (c CafeHandler) AddMenuItem(ctx context.Context, menuItem *models.MenuItem)
 		return nil, fmt.Errorf("invalid menu item category: %s", menuItem.Category)
 	}
 
+	err := normalizeMenuOptions(menuItem.Options, menuItem.Price)
+	if err != nil {
+		return nil, err
+	}
+	err = normalizeDietInfo(menuItem)
+	if err != nil {
+		return nil, err
+	}
+
 	itemID, err := c.MenuItemRepo.Create(ctx, menuItem)
 	if err != nil {
 		log.GetLog().Errorf("Unable to create menu item. error: %v", err)
 		return nil, err
 	}
+	c.refreshSearchDocument(ctx, menuItem.CafeID)
 
 	if menuItem.ImageID != "" {
 		err := c.ImageRepo.Create(ctx, &models.Image{
@@ -494,13 +477,33 @@ func (c CafeHandler) AddMenuItem(ctx context.Context, menuItem *models.MenuItem)
 	return menuItem, err
 }
 
-func (c CafeHandler) GetMenu(ctx context.Context, cafeID int32) ([]string, map[string][]*models.MenuItem, string, string, error) {
-	menuItems, err := c.MenuItemRepo.GetItemsByCafeID(ctx, cafeID)
+func (c CafeHandler) GetMenu(ctx context.Context, cafeID int32, filter models.MenuFilter) ([]string, map[string][]*models.MenuItem, string, string, error) {
+	allItems, err := c.MenuItemRepo.GetItemsByCafeID(ctx, cafeID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get menu items. error: %v", err)
 		return nil, nil, "", "", err
 	}
 
+	schedules, err := c.MenuItemRepo.CategorySchedules(ctx, cafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get menu category schedules. error: %v", err)
+		return nil, nil, "", "", err
+	}
+
+	if filter.At.IsZero() {
+		filter.At = time.Now()
+	}
+	day := menuDay(filter.At)
+
+	var menuItems []*models.MenuItem
+	for _, item := range allItems {
+		markStock(item, day)
+		available := !item.Hidden && !item.SoldOut && scheduleCovers(item.Schedule, filter.At) && scheduleCovers(schedules[item.Category], filter.At)
+		if menuItemMatches(item, filter) && (available || filter.All) {
+			menuItems = append(menuItems, item)
+		}
+	}
+
 	for i, item := range menuItems {
 		images, err := c.ImageRepo.GetByReferenceID(ctx, item.ID)
 		if err != nil {
@@ -554,6 +557,38 @@ func (c CafeHandler) EditMenuItem(ctx context.Context, newItem models.MenuItem)
 		preItem.ImageID = images[0].ID
 	}
 
+	if newItem.Options != nil {
+		price := preItem.Price
+		if newItem.Price != 0 {
+			price = newItem.Price
+		}
+		err = normalizeMenuOptions(newItem.Options, price)
+		if err != nil {
+			return err
+		}
+	}
+
+	// allergens, tags and nutrition left out of the request keep their values
+	dietChanged := newItem.Allergens != nil || newItem.Dietary != nil || newItem.Calories != nil || newItem.CaffeineMg != nil
+	if dietChanged {
+		if newItem.Allergens == nil {
+			newItem.Allergens = preItem.Allergens
+		}
+		if newItem.Dietary == nil {
+			newItem.Dietary = preItem.Dietary
+		}
+		if newItem.Calories == nil {
+			newItem.Calories = preItem.Calories
+		}
+		if newItem.CaffeineMg == nil {
+			newItem.CaffeineMg = preItem.CaffeineMg
+		}
+		err = normalizeDietInfo(&newItem)
+		if err != nil {
+			return err
+		}
+	}
+
 	if newItem.Name != preItem.Name && newItem.Name != "" {
 		err = c.MenuItemRepo.UpdateName(ctx, newItem.ID, newItem.Name)
 		if err != nil {
@@ -578,6 +613,24 @@ func (c CafeHandler) EditMenuItem(ctx context.Context, newItem models.MenuItem)
 		}
 	}
 
+	// options left out of the request stay as they are; an empty list removes them
+	if newItem.Options != nil {
+		err = c.MenuItemRepo.UpdateOptions(ctx, newItem.ID, newItem.Options)
+		if err != nil {
+			log.GetLog().Errorf("Unable to update menu items options. error: %v", err)
+			return err
+		}
+	}
+
+	if dietChanged {
+		err = c.MenuItemRepo.UpdateDietInfo(ctx, &newItem)
+		if err != nil {
+			log.GetLog().Errorf("Unable to update menu items diet info. error: %v", err)
+			return err
+		}
+	}
+	c.refreshSearchDocument(ctx, preItem.CafeID)
+
 	if newItem.ImageID != "" {
 		err := c.ImageRepo.DeleteByID(ctx, newItem.ImageID)
 		if err != nil {
@@ -602,11 +655,18 @@ func (c CafeHandler) EditMenuItem(ctx context.Context, newItem models.MenuItem)
 }
 
 func (c CafeHandler) DeleteMenuItem(ctx context.Context, itemID int32) error {
-	err := c.MenuItemRepo.DeleteByID(ctx, itemID)
+	item, err := c.MenuItemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		log.GetLog().Errorf("Incorrect menu item id. error: %v", err)
+		return err
+	}
+
+	err = c.MenuItemRepo.DeleteByID(ctx, itemID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to delete item by menu item id. error: %v", err)
 		return err
 	}
+	c.refreshSearchDocument(ctx, item.CafeID)
 
 	err = c.ImageRepo.DeleteByReferenceID(ctx, itemID)
 	if err != nil {
@@ -617,14 +677,21 @@ func (c CafeHandler) DeleteMenuItem(ctx context.Context, itemID int32) error {
 	return err
 }
 
-func (c CafeHandler) Home(ctx context.Context) ([]models.Cafe, []*models.Comment, []*models.Event, error) {
-	cafes, err := c.Rating.GetNTopRatings(ctx, 5)
+func (c CafeHandler) Home(ctx context.Context) ([]models.Cafe, []*models.Review, []*models.Event, error) {
+	cafes, err := c.Popularity.Top(ctx, 0, 5)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get home cafes. error: %v", err)
 		return nil, nil, nil, err
 	}
+	// before the first popularity run there are no scores yet
+	if len(cafes) == 0 {
+		cafes, err = c.Reviews.TopRated(ctx, 5)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get home cafes. error: %v", err)
+			return nil, nil, nil, err
+		}
+	}
 
-	cafes = append(cafes, 1)
 	ds, err := c.CafeRepo.GetByCafeIDs(ctx, cafes)
 	if err != nil {
 		return nil, nil, nil, err
@@ -642,9 +709,9 @@ func (c CafeHandler) Home(ctx context.Context) ([]models.Cafe, []*models.Comment
 		}
 	}
 
-	comments, err := c.CommentRepo.GetLast(ctx, 0, 5, 0)
+	reviews, err := c.Reviews.GetLatest(ctx, 5)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get home comments. error: %v", err)
+		log.GetLog().Errorf("Unable to get home reviews. error: %v", err)
 		return nil, nil, nil, err
 	}
 
@@ -673,10 +740,33 @@ func (c CafeHandler) Home(ctx context.Context) ([]models.Cafe, []*models.Comment
 
 	}
 
-	return ds, comments, events, nil
+	return ds, reviews, events, nil
+}
+
+// pay charges a reservation to the customer's wallet. Loyalty rewards are taken off the price first,
+// then a gift card covers as much of the rest as it can.
+func (c CafeHandler) pay(ctx context.Context, cafeID int32, payment *models.Transaction, checkout models.CheckoutOptions) (string, error) {
+	rule, err := c.LoyaltyRepo.GetRule(ctx, cafeID)
+	if err != nil {
+		return "", err
+	}
+
+	redeemed, err := loyaltyRedemption(rule, payment, checkout)
+	if err != nil {
+		return "", err
+	}
+
+	paid := payment.Amount
+	transactionID, err := c.LoyaltyRepo.RedeemAndPay(ctx, redeemed, rule.StampsRequired, checkout.GiftCardCode, cafeID, payment)
+	if err != nil {
+		return "", err
+	}
+
+	c.earnLoyalty(ctx, rule, payment.SenderID, paid, !checkout.UseStampReward, transactionID)
+	return transactionID, nil
 }
 
-func (c CafeHandler) ReserveEvent(ctx context.Context, eventID int32, userID int32) error {
+func (c CafeHandler) ReserveEvent(ctx context.Context, eventID int32, userID int32, checkout models.CheckoutOptions) error {
 	event, err := c.EventRepo.GetEventByID(ctx, eventID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get event by id. error: %v", err)
@@ -705,14 +795,14 @@ func (c CafeHandler) ReserveEvent(ctx context.Context, eventID int32, userID int
 		return errors.ErrEventUnreservable.Error()
 	}
 
-	transactionID, err := c.PaymentRepo.Create(ctx, &models.Transaction{
+	transactionID, err := c.pay(ctx, event.CafeID, &models.Transaction{
 		SenderID:    userID,
 		ReceiverID:  cafe.OwnerID,
 		Amount:      int64(event.Price),
 		Description: event.Description,
-		Type:        3,
+		Type:        models.Transfer,
 		CreatedAt:   time.Now().UTC(),
-	})
+	}, checkout)
 	if err != nil {
 		log.GetLog().Errorf("Unable to do transaction. error: %v", err)
 		return err
@@ -974,6 +1064,7 @@ func (c CafeHandler) EditCafe(ctx context.Context, newCafe RequestEditCafe) erro
 			return err
 		}
 	}
+	c.refreshSearchDocument(ctx, newCafe.ID)
 
 	return err
 }
@@ -1105,7 +1196,7 @@ func (c CafeHandler) GetAvailableTimeSlots(ctx context.Context, cafeID int32, da
 	return c.ReservationRepo.GetAvailableTimeSlots(ctx, cafeID, day, cafe.Capacity, cafe.OpeningTime, cafe.ClosingTime)
 }
 
-func (c CafeHandler) ReserveCafe(ctx context.Context, reservation *models.Reservation) error {
+func (c CafeHandler) ReserveCafe(ctx context.Context, reservation *models.Reservation, checkout models.CheckoutOptions) error {
 	totalPeople, err := c.ReservationRepo.CountByTime(ctx, reservation.CafeID, reservation.StartTime, reservation.EndTime)
 	if err != nil {
 		log.GetLog().Errorf("Unable to check availability. error: %v", err)
@@ -1119,18 +1210,17 @@ func (c CafeHandler) ReserveCafe(ctx context.Context, reservation *models.Reserv
 	}
 
 	if totalPeople+reservation.People > cafe.Capacity {
-		log.GetLog().Errorf("time slot is fully booked")
-		return fmt.Errorf("time slot is fully booked")
+		return errors.ErrFullyBooked.Error()
 	}
 
-	transactionID, err := c.PaymentRepo.Create(ctx, &models.Transaction{
+	transactionID, err := c.pay(ctx, reservation.CafeID, &models.Transaction{
 		SenderID:    reservation.UserID,
 		ReceiverID:  cafe.OwnerID,
 		Amount:      int64(cafe.ReservationPrice * float64(reservation.People)),
 		Description: "cafe reservation transaction",
-		Type:        3,
+		Type:        models.Transfer,
 		CreatedAt:   time.Now().UTC(),
-	})
+	}, checkout)
 	if err != nil {
 		log.GetLog().Errorf("Unable to do transaction. error: %v", err)
 		return err
@@ -1143,25 +1233,245 @@ func (c CafeHandler) ReserveCafe(ctx context.Context, reservation *models.Reserv
 		return err
 	}
 
+	if cafe.ReservationPrice > 0 {
+		c.Referrals.PaidReservation(ctx, reservation.UserID)
+	}
+
 	return nil
 }
 
+// ReserveCafeGroup holds seats for a group and splits the price between the organizer and the invited users.
+// The reservation is confirmed once everyone has paid, otherwise it is released and refunded after groupReservationHold.
+func (c CafeHandler) ReserveCafeGroup(ctx context.Context, reservation *models.Reservation, invitees []string) ([]models.ReservationShare, error) {
+	if len(invitees) == 0 || int32(len(invitees)) >= reservation.People {
+		return nil, errors.ErrInviteesInvalid.Error()
+	}
+
+	participants := []int32{reservation.UserID}
+	emails := make(map[int32]string)
+	for _, email := range invitees {
+		users, err := c.UserRepo.GetByEmail(ctx, email)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get invitee by email. error: %v", err)
+			return nil, err
+		}
+		invitee := findRecipient(users)
+		if invitee == nil {
+			return nil, errors.ErrRecipientNotFound.Error()
+		}
+		if _, ok := emails[invitee.ID]; ok || invitee.ID == reservation.UserID {
+			return nil, errors.ErrInviteesInvalid.Error()
+		}
+		emails[invitee.ID] = invitee.Email
+		participants = append(participants, invitee.ID)
+	}
+
+	totalPeople, err := c.ReservationRepo.CountByTime(ctx, reservation.CafeID, reservation.StartTime, reservation.EndTime)
+	if err != nil {
+		log.GetLog().Errorf("Unable to check availability. error: %v", err)
+		return nil, err
+	}
+
+	cafe, err := c.CafeRepo.GetByID(ctx, reservation.CafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe. error: %v", err)
+		return nil, err
+	}
+
+	if totalPeople+reservation.People > cafe.Capacity {
+		return nil, errors.ErrFullyBooked.Error()
+	}
+
+	// split evenly, the organizer covers whatever doesn't divide
+	total := int64(cafe.ReservationPrice * float64(reservation.People))
+	share := total / int64(len(participants))
+	shares := make([]models.ReservationShare, len(participants))
+	for i, userID := range participants {
+		shares[i] = models.ReservationShare{UserID: userID, Amount: share}
+	}
+	shares[0].Amount += total - share*int64(len(participants))
+
+	expiresAt := time.Now().UTC().Add(groupReservationHold)
+	reservation.ExpiresAt = &expiresAt
+	err = c.ReservationRepo.CreateGroup(ctx, reservation, shares, cafe.OwnerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to create group reservation. error: %v", err)
+		return nil, err
+	}
+
+	organizer, err := c.UserRepo.GetByID(ctx, reservation.UserID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get organizer. error: %v", err)
+		return shares, nil
+	}
+	for _, s := range shares[1:] {
+		emailBody := fmt.Sprintf(`Hello,<br><br>
+	%s %s invited you to a reservation at %s on %s.<br>
+	Your share is %d. Please pay it within %d minutes, otherwise the reservation will be cancelled.<br><br>
+
+	Yours,<br>
+	The Synapse team`, organizer.FirstName, organizer.LastName, cafe.Name, reservation.StartTime.In(utils.IranLocation).Format("2006-01-02 15:04"), s.Amount, int(groupReservationHold.Minutes()))
+		err = utils.SendEmail(emails[s.UserID], "Barista reservation invitation", emailBody)
+		if err != nil {
+			log.GetLog().Errorf("Unable to send email. error: %v", err)
+		}
+	}
+
+	return shares, nil
+}
+
+func (c CafeHandler) PayReservationShare(ctx context.Context, userID int32, reservationID int32) (bool, error) {
+	reservation, err := c.ReservationRepo.GetByID(ctx, reservationID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reservation. error: %v", err)
+		return false, err
+	}
+
+	cafe, err := c.CafeRepo.GetByID(ctx, reservation.CafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe. error: %v", err)
+		return false, err
+	}
+
+	confirmed, err := c.ReservationRepo.PayShare(ctx, reservationID, userID, cafe.OwnerID)
+	if err != nil || !confirmed {
+		return confirmed, err
+	}
+
+	// everyone in the group earns for their own share once the reservation goes through
+	rule, err := c.LoyaltyRepo.GetRule(ctx, reservation.CafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty rule. error: %v", err)
+		return true, nil
+	}
+	shares, err := c.ReservationRepo.GetShares(ctx, reservationID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reservation shares. error: %v", err)
+		return true, nil
+	}
+	for _, share := range shares {
+		c.earnLoyalty(ctx, rule, share.UserID, share.Amount, true, share.TransactionID)
+		if share.Amount > 0 {
+			c.Referrals.PaidReservation(ctx, share.UserID)
+		}
+	}
+
+	return true, nil
+}
+
+func (c CafeHandler) PendingReservationShares(ctx context.Context, userID int32) ([]models.ReservationShare, error) {
+	return c.ReservationRepo.GetPendingSharesByUserID(ctx, userID)
+}
+
+func (c CafeHandler) ReservationShares(ctx context.Context, userID int32, reservationID int32) ([]models.ReservationShare, error) {
+	shares, err := c.ReservationRepo.GetShares(ctx, reservationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		if share.UserID == userID {
+			return shares, nil
+		}
+	}
+	return nil, errors.ErrForbidden.Error()
+}
+
+func (c CafeHandler) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	return c.ReservationRepo.ReleaseExpired(ctx)
+}
+
 func (c CafeHandler) GetNearestCafes(ctx context.Context, lat float64, long float64, radius float64) ([]redis.GeoLocation, error) {
+	return c.GeoIndex.Nearest(ctx, lat, long, radius, 5)
+}
 
-	return c.Redis.GeoRadius(ctx, "locations", lat, long, &redis.GeoRadiusQuery{
-		Radius:      radius,
-		Unit:        "km",
-		WithCoord:   true,
-		WithDist:    true,
-		WithGeoHash: true,
-		Count:       5,
-		Sort:        "ASC",
-	}).Result()
+// SetCafeLocation fills in the cafe's province and city from the coordinates when they are missing,
+// and rejects coordinates outside the ones already set. Points outside known boundaries, or any point while
+// no boundaries are loaded, are accepted as is.
+func (c CafeHandler) SetCafeLocation(ctx context.Context, ownerID int32, m *models.Location) error {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		return errors.ErrCafeNotFound.Error()
+	}
+	m.CafeID = cafe.ID
+
+	var province, city int
+	if utils.AreaLookupAvailable() {
+		province, city = utils.LocateArea(m.Lat, m.Lng)
+	}
+	if (province != 0 && cafe.ContactInfo.Province != 0 && province != cafe.ContactInfo.Province) ||
+		(city != 0 && cafe.ContactInfo.City != 0 && city != cafe.ContactInfo.City) {
+		return errors.ErrLocationMismatch.Error()
+	}
+
+	err = c.LocationsRepo.SetLocation(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	if province != 0 && cafe.ContactInfo.Province == 0 {
+		err = c.CafeRepo.Update(ctx, cafe.ID, repo.UpdateCafeProvince, province)
+		if err != nil {
+			return err
+		}
+	}
+	if city != 0 && cafe.ContactInfo.City == 0 {
+		err = c.CafeRepo.Update(ctx, cafe.ID, repo.UpdateCafeCity, city)
+		if err != nil {
+			return err
+		}
+	}
 
+	if !cafe.Hidden {
+		// the periodic rebuild picks this up if redis is unavailable right now
+		c.GeoIndex.Add(ctx, m)
+	}
+	return nil
 }
 
-func (c CafeHandler) SetCafeLocation(ctx context.Context, m *models.Location) error {
-	return c.LocationsRepo.SetLocation(ctx, m)
+func (c CafeHandler) SetCafeHidden(ctx context.Context, ownerID int32, hidden bool) error {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		return errors.ErrCafeNotFound.Error()
+	}
+
+	err = c.CafeRepo.SetHidden(ctx, cafe.ID, hidden)
+	if err != nil {
+		return err
+	}
+
+	if hidden {
+		c.GeoIndex.Remove(ctx, cafe.ID)
+		return nil
+	}
+
+	location, err := c.LocationsRepo.GetCafeLocation(ctx, cafe.ID)
+	if err == nil && location.CafeID != 0 {
+		c.GeoIndex.Add(ctx, &location)
+	}
+	return nil
+}
+
+func (c CafeHandler) DeleteCafe(ctx context.Context, ownerID int32) error {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		return errors.ErrCafeNotFound.Error()
+	}
+
+	err = c.CafeRepo.DeleteByID(ctx, cafe.ID)
+	if err != nil {
+		return err
+	}
+
+	c.GeoIndex.Remove(ctx, cafe.ID)
+	return nil
+}
+
+func (c CafeHandler) RebuildGeoIndex(ctx context.Context) error {
+	locations, err := c.LocationsRepo.FindVisible(ctx)
+	if err != nil {
+		return err
+	}
+	return c.GeoIndex.Rebuild(ctx, locations)
 }
 
 func (c CafeHandler) GetCafeLocation(ctx context.Context, id int32) (models.Location, error) {
@@ -1169,20 +1479,21 @@ func (c CafeHandler) GetCafeLocation(ctx context.Context, id int32) (models.Loca
 }
 
 func (c CafeHandler) AddRating(ctx context.Context, userID, cafeID, rating int32) error {
-	return c.Rating.CreateOrUpdate(ctx, &models.Rating{
-		ID:     rand.Int31(),
-		UserID: userID,
-		CafeID: cafeID,
-		Rating: rating,
-	})
+	if rating < 1 || rating > 5 {
+		return errors.ErrReviewInvalid.Error()
+	}
+	return c.Reviews.SetRating(ctx, userID, cafeID, rating)
 }
 
 type ReservationInfo struct {
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	People    int32     `json:"people"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
+	ID           int32                    `json:"id"`
+	FirstName    string                   `json:"first_name"`
+	LastName     string                   `json:"last_name"`
+	People       int32                    `json:"people"`
+	StartTime    time.Time                `json:"start_time"`
+	EndTime      time.Time                `json:"end_time"`
+	Status       models.ReservationStatus `json:"status"`
+	Transactions []models.Transaction     `json:"transactions"`
 }
 
 func (c CafeHandler) GetCafeReservations(ctx context.Context, cafe *models.Cafe, day time.Time) ([]ReservationInfo, error) {
@@ -1201,12 +1512,21 @@ func (c CafeHandler) GetCafeReservations(ctx context.Context, cafe *models.Cafe,
 			return nil, err
 		}
 
+		transactions, err := c.ReservationRepo.GetTransactions(ctx, reservation.ID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get reservation transactions. error: %v", err)
+			return nil, err
+		}
+
 		reservationsInfo = append(reservationsInfo, ReservationInfo{
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			People:    reservation.People,
-			StartTime: reservation.StartTime,
-			EndTime:   reservation.EndTime,
+			ID:           reservation.ID,
+			FirstName:    user.FirstName,
+			LastName:     user.LastName,
+			People:       reservation.People,
+			StartTime:    reservation.StartTime,
+			EndTime:      reservation.EndTime,
+			Status:       reservation.Status,
+			Transactions: transactions,
 		})
 	}
 
@@ -1232,24 +1552,28 @@ func (c CafeHandler) AddToFavorite(ctx context.Context, userID int32, cafeID int
 		log.GetLog().Errorf("Unable to create favorite. error: %v", err)
 		return err
 	}
+	c.forgetFeed(ctx, userID)
 
 	return nil
 }
 
 func (c CafeHandler) GetRating(ctx context.Context, userID int32, cafeID int32) (float64, int32, error) {
-	cafeRating, err := c.Rating.GetCafesRating(ctx, cafeID)
+	cafeRating, err := c.Reviews.CafeRating(ctx, cafeID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get cafe rating. error: %v", err)
 		return 0, 0, err
 	}
 
-	cafeRatingCount, err := c.Rating.GetRatingByUserIDAndCafeID(ctx, userID, cafeID)
+	review, err := c.Reviews.GetByUserAndCafe(ctx, userID, cafeID)
 	if err != nil {
-		log.GetLog().Errorf("Unable to get cafe rating count. error: %v", err)
+		log.GetLog().Errorf("Unable to get user rating. error: %v", err)
 		return 0, 0, err
 	}
+	if review == nil {
+		return cafeRating, 0, nil
+	}
 
-	return cafeRating, cafeRatingCount.Rating, nil
+	return cafeRating, review.Rating, nil
 }
 
 func (c CafeHandler) RemoveFavorite(ctx context.Context, userID int32, cafeID int32) error {
@@ -1258,6 +1582,7 @@ func (c CafeHandler) RemoveFavorite(ctx context.Context, userID int32, cafeID in
 		log.GetLog().Errorf("Unable to delete favorite. error: %v", err)
 		return err
 	}
+	c.forgetFeed(ctx, userID)
 
 	return nil
 }
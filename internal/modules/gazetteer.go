@@ -0,0 +1,188 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/models"
+	"barista/pkg/repo"
+	"context"
+	"math"
+	"sort"
+)
+
+const (
+	gazetteerLimit = 20
+	// outlines that share a border overlap once their boxes are grown by about a kilometre
+	neighbourMargin = 0.01
+)
+
+type GazetteerHandler struct {
+	Suggestions repo.SuggestionsRepo
+}
+
+func gazetteerCity(city models.City, cityCounts map[int]int, withDistricts bool) models.GazetteerCity {
+	result := models.GazetteerCity{
+		City:         city,
+		ProvinceName: models.ProvinceByID[city.ProvinceID].Name,
+		Cafes:        cityCounts[city.ID],
+	}
+	if withDistricts {
+		result.Districts = models.CityDistricts[city.ID]
+	}
+	return result
+}
+
+func (g GazetteerHandler) Provinces(ctx context.Context) ([]models.GazetteerProvince, error) {
+	provinceCounts, _, err := g.Suggestions.AreaCafeCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	provinces := []models.GazetteerProvince{}
+	for _, province := range models.Provinces {
+		provinces = append(provinces, models.GazetteerProvince{Province: province, Cafes: provinceCounts[province.ID]})
+	}
+	return provinces, nil
+}
+
+func (g GazetteerHandler) ProvinceCities(ctx context.Context, provinceID int) ([]models.GazetteerCity, error) {
+	if _, ok := models.ProvinceByID[provinceID]; !ok {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	_, cityCounts, err := g.Suggestions.AreaCafeCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cities := []models.GazetteerCity{}
+	for _, city := range models.ProvinceCities[provinceID] {
+		cities = append(cities, gazetteerCity(city, cityCounts, false))
+	}
+	return cities, nil
+}
+
+func (g GazetteerHandler) City(ctx context.Context, cityID int) (*models.GazetteerCity, error) {
+	city, ok := models.CityByID[cityID]
+	if !ok {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	_, cityCounts, err := g.Suggestions.AreaCafeCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := gazetteerCity(city, cityCounts, true)
+	return &result, nil
+}
+
+// Search matches provinces and cities by the start of any word of their name, and lists those with more cafes first.
+func (g GazetteerHandler) Search(ctx context.Context, query string, limit int) ([]models.GazetteerProvince, []models.GazetteerCity, error) {
+	if limit <= 0 || limit > gazetteerLimit {
+		limit = gazetteerLimit
+	}
+
+	provinces, cities := []models.GazetteerProvince{}, []models.GazetteerCity{}
+	prefixes := suggestPrefixes(query)
+	if len(prefixes) == 0 {
+		return provinces, cities, nil
+	}
+
+	provinceCounts, cityCounts, err := g.Suggestions.AreaCafeCounts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, match := range areaIndex.lookup(prefixes) {
+		switch match.Type {
+		case models.SuggestionProvince:
+			province := models.ProvinceByID[int(match.ID)]
+			provinces = append(provinces, models.GazetteerProvince{Province: province, Cafes: provinceCounts[province.ID]})
+		case models.SuggestionCity:
+			cities = append(cities, gazetteerCity(models.CityByID[int(match.ID)], cityCounts, false))
+		}
+	}
+
+	sort.SliceStable(provinces, func(i, j int) bool { return provinces[i].Cafes > provinces[j].Cafes })
+	sort.SliceStable(cities, func(i, j int) bool { return cities[i].Cafes > cities[j].Cafes })
+	if len(provinces) > limit {
+		provinces = provinces[:limit]
+	}
+	if len(cities) > limit {
+		cities = cities[:limit]
+	}
+	return provinces, cities, nil
+}
+
+func cityBoundary(boundaries []models.Boundary, cityID int) *models.Boundary {
+	for i := range boundaries {
+		if boundaries[i].Level == models.AreaCity && boundaries[i].ID == cityID {
+			return &boundaries[i]
+		}
+	}
+	return nil
+}
+
+func boundaryCenter(b *models.Boundary) (float64, float64) {
+	return (b.MinLat + b.MaxLat) / 2, (b.MinLng + b.MaxLng) / 2
+}
+
+// neighbourCities returns the cities whose outlines touch the given city's, nearest first, and true. When
+// the city has no outline, which is every city while assets/boundaries.geojson is empty, it falls back to the
+// other cities of the same province, the ones with more cafes first, and returns false: those are cities
+// nearby in the administrative sense only and not necessarily neighbours.
+func neighbourCities(boundaries []models.Boundary, cityID int, cityCounts map[int]int) ([]models.GazetteerCity, bool) {
+	neighbours := []models.GazetteerCity{}
+	own := cityBoundary(boundaries, cityID)
+	if own == nil {
+		for _, other := range models.ProvinceCities[models.CityByID[cityID].ProvinceID] {
+			if other.ID != cityID {
+				neighbours = append(neighbours, gazetteerCity(other, cityCounts, false))
+			}
+		}
+		sort.SliceStable(neighbours, func(i, j int) bool { return neighbours[i].Cafes > neighbours[j].Cafes })
+		return neighbours, false
+	}
+
+	lat, lng := boundaryCenter(own)
+	distances := map[int]float64{}
+	for i := range boundaries {
+		b := &boundaries[i]
+		if b.Level != models.AreaCity || b.ID == cityID {
+			continue
+		}
+		if b.MinLat > own.MaxLat+neighbourMargin || b.MaxLat < own.MinLat-neighbourMargin ||
+			b.MinLng > own.MaxLng+neighbourMargin || b.MaxLng < own.MinLng-neighbourMargin {
+			continue
+		}
+		if other, ok := models.CityByID[b.ID]; ok {
+			otherLat, otherLng := boundaryCenter(b)
+			distances[other.ID] = math.Hypot(otherLat-lat, (otherLng-lng)*math.Cos(lat*math.Pi/180))
+			neighbours = append(neighbours, gazetteerCity(other, cityCounts, false))
+		}
+	}
+	sort.SliceStable(neighbours, func(i, j int) bool { return distances[neighbours[i].ID] < distances[neighbours[j].ID] })
+	return neighbours, true
+}
+
+// Neighbours lists the cities around the given one. byOutline is false when they are only the other cities
+// of its province; see neighbourCities.
+func (g GazetteerHandler) Neighbours(ctx context.Context, cityID int, limit int) (cities []models.GazetteerCity, byOutline bool, err error) {
+	if _, ok := models.CityByID[cityID]; !ok {
+		return nil, false, errors.ErrBadRequest.Error()
+	}
+	if limit <= 0 || limit > gazetteerLimit {
+		limit = gazetteerLimit
+	}
+
+	_, cityCounts, err := g.Suggestions.AreaCafeCounts(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cities, byOutline = neighbourCities(models.Boundaries, cityID, cityCounts)
+	if len(cities) > limit {
+		cities = cities[:limit]
+	}
+	return cities, byOutline, nil
+}
@@ -0,0 +1,48 @@
+package modules
+
+import (
+	"barista/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func boxBoundary(level models.AreaLevel, id int, minLng, minLat, maxLng, maxLat float64) models.Boundary {
+	ring := [][2]float64{{minLng, minLat}, {maxLng, minLat}, {maxLng, maxLat}, {minLng, maxLat}, {minLng, minLat}}
+	return models.Boundary{Level: level, ID: id, Polygons: [][][][2]float64{{ring}}, MinLat: minLat, MinLng: minLng, MaxLat: maxLat, MaxLng: maxLng}
+}
+
+func cityIDs(cities []models.GazetteerCity) []int {
+	ids := []int{}
+	for _, city := range cities {
+		ids = append(ids, city.ID)
+	}
+	return ids
+}
+
+func TestNeighbourCitiesWithoutOutlines(t *testing.T) {
+	// Qom's other cities, the ones with cafes first and the rest in gazetteer order
+	cities, byOutline := neighbourCities(nil, 917, map[int]int{342: 1, 1006: 5, 917: 40})
+	assert.False(t, byOutline)
+	assert.Equal(t, []int{1006, 342, 506, 691, 919}, cityIDs(cities))
+	assert.Equal(t, 5, cities[0].Cafes)
+	assert.Equal(t, "قم", cities[0].ProvinceName)
+}
+
+func TestNeighbourCitiesWithOutlines(t *testing.T) {
+	boundaries := []models.Boundary{
+		boxBoundary(models.AreaProvince, 19, 50, 34, 52, 35.5),
+		boxBoundary(models.AreaCity, 917, 50.8, 34.5, 51, 34.7),
+		boxBoundary(models.AreaCity, 1006, 51, 34.3, 51.3, 34.5),
+		boxBoundary(models.AreaCity, 342, 51, 34.55, 51.1, 34.65),
+		boxBoundary(models.AreaCity, 506, 50.1, 34.1, 50.2, 34.2),
+	}
+
+	cities, byOutline := neighbourCities(boundaries, 917, map[int]int{})
+	assert.True(t, byOutline)
+	assert.Equal(t, []int{342, 1006}, cityIDs(cities))
+
+	// a city without an outline of its own still falls back to its province
+	cities, byOutline = neighbourCities(boundaries, 691, map[int]int{})
+	assert.False(t, byOutline)
+	assert.Equal(t, []int{342, 506, 917, 919, 1006}, cityIDs(cities))
+}
@@ -0,0 +1,207 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	feedSectionSize    = 10
+	feedNearbyRadiusKm = 5
+	feedNearbyTTL      = 5 * time.Minute
+	feedPersonalTTL    = 15 * time.Minute
+	feedCityTTL        = 30 * time.Minute
+)
+
+func feedKey(section models.FeedSectionKey, scope any) string {
+	return fmt.Sprintf("feed:%s:%v", section, scope)
+}
+
+// feedSection serves a section from the cache, building and caching it on a miss. Redis being
+// unavailable only costs the rebuild.
+func (c CafeHandler) feedSection(ctx context.Context, key string, ttl time.Duration, build func() (*models.FeedSection, error)) (*models.FeedSection, error) {
+	section, err := c.FeedCache.Get(ctx, key)
+	if err == nil && section != nil {
+		return section, nil
+	}
+
+	section, err = build()
+	if err != nil {
+		return nil, err
+	}
+	_ = c.FeedCache.Set(ctx, key, section, ttl)
+	return section, nil
+}
+
+func (c CafeHandler) cafeFeedSection(ctx context.Context, key models.FeedSectionKey, filter *models.CafeSearchFilter) (*models.FeedSection, error) {
+	filter.Limit = feedSectionSize
+	err := prepareSearchFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.CafeRepo.SearchCafe(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FeedSection{Key: key, Title: models.FeedSectionTitles[key], Cafes: c.cafeCards(ctx, result.Cafes)}, nil
+}
+
+func (c CafeHandler) eventFeedSection(ctx context.Context, key models.FeedSectionKey, cafeIDs []int32, city int) (*models.FeedSection, error) {
+	events, err := c.EventRepo.GetUpcomingEvents(ctx, cafeIDs, city, feedSectionSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		images, err := c.ImageRepo.GetByReferenceID(ctx, event.ID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get images by event id. error: %v", err)
+			continue
+		}
+		if len(images) > 0 {
+			event.ImageID = images[0].ID
+		}
+	}
+	return &models.FeedSection{Key: key, Title: models.FeedSectionTitles[key], Events: events}, nil
+}
+
+// favoriteCity is the city most of the favorites are in, used when the user shares no location.
+func favoriteCity(favorites []models.Cafe) int {
+	counts := map[int]int{}
+	city, best := 0, 0
+	for _, cafe := range favorites {
+		if cafe.ContactInfo.City == 0 {
+			continue
+		}
+		counts[cafe.ContactInfo.City]++
+		if counts[cafe.ContactInfo.City] > best {
+			city, best = cafe.ContactInfo.City, counts[cafe.ContactInfo.City]
+		}
+	}
+	return city
+}
+
+func favoriteTaste(favorites []models.Cafe) ([]int32, []models.CafeCategory) {
+	ids := []int32{}
+	seen := map[models.CafeCategory]bool{}
+	var categories []models.CafeCategory
+	for _, cafe := range favorites {
+		ids = append(ids, cafe.ID)
+		for _, category := range cafe.Categories {
+			if _, ok := models.CafeCategoryPersians[category]; ok && !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+	}
+	return ids, categories
+}
+
+// HomeFeed builds the home page. Logged-in users with favorites get their recommendations, or cafes like
+// the ones they saved, and the events coming up at them; everyone gets nearby, trending and top rated cafes for their city, which
+// comes from the given city, then their position, then where most of their favorites are.
+func (c CafeHandler) HomeFeed(ctx context.Context, userID int32, lat *float64, lng *float64, city int) (*models.HomeFeed, error) {
+	if city != 0 {
+		if _, ok := models.CityByID[city]; !ok {
+			return nil, errors.ErrBadRequest.Error()
+		}
+	}
+	if (lat == nil) != (lng == nil) || (lat != nil && (*lat < -90 || *lat > 90 || *lng < -180 || *lng > 180)) {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	var favorites []models.Cafe
+	if userID != 0 {
+		saved, err := c.FavoriteRepo.GetFavoritesByUserID(ctx, userID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get favorites for feed. error: %v", err)
+			return nil, err
+		}
+		ids := []int32{}
+		for _, favorite := range saved {
+			ids = append(ids, favorite.CafeID)
+		}
+		favorites, err = c.CafeRepo.GetByCafeIDs(ctx, ids)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get favorite cafes for feed. error: %v", err)
+			return nil, err
+		}
+	}
+
+	if city == 0 && lat != nil && utils.AreaLookupAvailable() {
+		_, city = utils.LocateArea(*lat, *lng)
+	}
+	if city == 0 {
+		city = favoriteCity(favorites)
+	}
+
+	feed := &models.HomeFeed{City: city, Personalized: len(favorites) > 0, Sections: []models.FeedSection{}}
+	add := func(section *models.FeedSection, err error) {
+		if err != nil {
+			log.GetLog().Errorf("Unable to build feed section. error: %v", err)
+			return
+		}
+		if len(section.Cafes) > 0 || len(section.Events) > 0 {
+			feed.Sections = append(feed.Sections, *section)
+		}
+	}
+
+	if lat != nil {
+		// about a kilometre of rounding, so people close to each other share the cached section
+		scope := fmt.Sprintf("%.2f:%.2f", *lat, *lng)
+		add(c.feedSection(ctx, feedKey(models.FeedNearby, scope), feedNearbyTTL, func() (*models.FeedSection, error) {
+			return c.cafeFeedSection(ctx, models.FeedNearby, &models.CafeSearchFilter{Lat: lat, Lng: lng, RadiusKm: feedNearbyRadiusKm, Sort: models.SearchSortDistance})
+		}))
+	}
+
+	if feed.Personalized {
+		ids, categories := favoriteTaste(favorites)
+		add(c.feedSection(ctx, feedKey(models.FeedForYou, userID), feedPersonalTTL, func() (*models.FeedSection, error) {
+			recommended, err := c.RecommendationRepo.UserRecommendations(ctx, userID, feedSectionSize)
+			if err != nil {
+				return nil, err
+			}
+			if len(recommended) > 0 {
+				cards, err := c.cafeCardsByIDs(ctx, recommended)
+				return &models.FeedSection{Key: models.FeedForYou, Title: models.FeedSectionTitles[models.FeedForYou], Cafes: cards}, err
+			}
+			// the nightly model has not seen this user's favorites yet. Like its picks, these are not limited to
+			// one city, since the section is cached per user wherever they open the app.
+			if len(categories) == 0 {
+				return &models.FeedSection{Key: models.FeedForYou, Title: models.FeedSectionTitles[models.FeedForYou]}, nil
+			}
+			return c.cafeFeedSection(ctx, models.FeedForYou, &models.CafeSearchFilter{Categories: categories, ExcludeIDs: ids, Sort: models.SearchSortRating})
+		}))
+		add(c.feedSection(ctx, feedKey(models.FeedFavoriteEvents, userID), feedPersonalTTL, func() (*models.FeedSection, error) {
+			return c.eventFeedSection(ctx, models.FeedFavoriteEvents, ids, 0)
+		}))
+	}
+
+	add(c.feedSection(ctx, feedKey(models.FeedTrending, city), feedCityTTL, func() (*models.FeedSection, error) {
+		_, cards, err := c.Trending(ctx, city, nil, nil, feedSectionSize)
+		return &models.FeedSection{Key: models.FeedTrending, Title: models.FeedSectionTitles[models.FeedTrending], Cafes: cards}, err
+	}))
+	add(c.feedSection(ctx, feedKey(models.FeedTopRated, city), feedCityTTL, func() (*models.FeedSection, error) {
+		return c.cafeFeedSection(ctx, models.FeedTopRated, &models.CafeSearchFilter{City: city, Sort: models.SearchSortRating})
+	}))
+
+	if !feed.Personalized {
+		add(c.feedSection(ctx, feedKey(models.FeedUpcomingEvents, city), feedCityTTL, func() (*models.FeedSection, error) {
+			return c.eventFeedSection(ctx, models.FeedUpcomingEvents, nil, city)
+		}))
+	}
+
+	return feed, nil
+}
+
+// forgetFeed drops the sections built from a user's favorites once they change.
+func (c CafeHandler) forgetFeed(ctx context.Context, userID int32) {
+	_ = c.FeedCache.Delete(ctx, feedKey(models.FeedForYou, userID), feedKey(models.FeedFavoriteEvents, userID))
+}
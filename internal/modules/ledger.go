@@ -0,0 +1,89 @@
+package modules
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/repo"
+	"context"
+	"time"
+)
+
+const (
+	reconciliationRunsLimit = 30
+)
+
+type LedgerHandler struct {
+	LedgerRepo repo.LedgerRepo
+}
+
+func (h LedgerHandler) Reconcile(ctx context.Context) (*models.ReconciliationRun, error) {
+	run := &models.ReconciliationRun{StartedAt: time.Now().UTC()}
+
+	// collisions are logged as they happen, so only report the ones since the previous run
+	since := time.Time{}
+	lastRuns, err := h.LedgerRepo.GetRuns(ctx, 1)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get last reconciliation run. error: %v", err)
+		return nil, err
+	}
+	if len(lastRuns) > 0 {
+		since = lastRuns[0].StartedAt
+	}
+
+	run.Wallets, err = h.LedgerRepo.CountWallets(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count wallets. error: %v", err)
+		return nil, err
+	}
+
+	mismatches, err := h.LedgerRepo.GetBalanceMismatches(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get balance mismatches. error: %v", err)
+		return nil, err
+	}
+
+	collisions, err := h.LedgerRepo.GetIDCollisions(ctx, since)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get transaction id collisions. error: %v", err)
+		return nil, err
+	}
+
+	shared, err := h.LedgerRepo.GetSharedTransactions(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get shared transactions. error: %v", err)
+		return nil, err
+	}
+
+	orphans, err := h.LedgerRepo.GetOrphanedReservations(ctx)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get orphaned reservations. error: %v", err)
+		return nil, err
+	}
+
+	run.Mismatches = int32(len(mismatches))
+	run.Collisions = int32(len(collisions) + len(shared))
+	run.Orphans = int32(len(orphans))
+
+	run.Issues = append(run.Issues, mismatches...)
+	run.Issues = append(run.Issues, collisions...)
+	run.Issues = append(run.Issues, shared...)
+	run.Issues = append(run.Issues, orphans...)
+	run.FinishedAt = time.Now().UTC()
+
+	err = h.LedgerRepo.CreateRun(ctx, run)
+	if err != nil {
+		log.GetLog().Errorf("Unable to save reconciliation run. error: %v", err)
+		return nil, err
+	}
+
+	log.GetLog().Infof("Ledger reconciliation finished. wallets: %d, mismatches: %d, collisions: %d, orphans: %d", run.Wallets, run.Mismatches, run.Collisions, run.Orphans)
+	return run, nil
+}
+
+func (h LedgerHandler) GetRuns(ctx context.Context) ([]models.ReconciliationRun, error) {
+	return h.LedgerRepo.GetRuns(ctx, reconciliationRunsLimit)
+}
+
+func (h LedgerHandler) GetRun(ctx context.Context, runID int32) (*models.ReconciliationRun, error) {
+	return h.LedgerRepo.GetRunByID(ctx, runID)
+}
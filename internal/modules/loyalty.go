@@ -0,0 +1,99 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+)
+
+const (
+	loyaltyHistoryLimit = 50
+)
+
+// loyaltyRedemption works out the points and stamp reward asked for in checkout and lowers payment.Amount
+// accordingly. It never spends more points than needed to cover the price.
+func loyaltyRedemption(rule *models.LoyaltyRule, payment *models.Transaction, checkout models.CheckoutOptions) (*models.LoyaltyEntry, error) {
+	if checkout.RedeemPoints < 0 {
+		return nil, errors.ErrBadRequest.Error()
+	}
+	if checkout.RedeemPoints == 0 && !checkout.UseStampReward {
+		return nil, nil
+	}
+
+	entry := &models.LoyaltyEntry{
+		UserID: payment.SenderID,
+		CafeID: rule.CafeID,
+		Reason: models.LoyaltyRedeemed,
+	}
+
+	if checkout.UseStampReward {
+		if rule.StampsRequired <= 0 {
+			return nil, errors.ErrNoStampReward.Error()
+		}
+		discount := payment.Amount
+		if rule.StampRewardCap > 0 {
+			discount = min(discount, rule.StampRewardCap)
+		}
+		payment.Amount -= discount
+		entry.Rewards = -1
+	}
+
+	if checkout.RedeemPoints > 0 && payment.Amount > 0 {
+		if rule.PointValue <= 0 {
+			return nil, errors.ErrNotEnoughPoints.Error()
+		}
+		points := min(checkout.RedeemPoints, (payment.Amount+rule.PointValue-1)/rule.PointValue)
+		payment.Amount -= min(payment.Amount, points*rule.PointValue)
+		entry.Points = -points
+	}
+
+	if entry.Points == 0 && entry.Rewards == 0 {
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// earnLoyalty is best effort: a failure here must not undo a payment that already went through.
+func (c CafeHandler) earnLoyalty(ctx context.Context, rule *models.LoyaltyRule, userID int32, paid int64, stamp bool, transactionID string) {
+	entry := &models.LoyaltyEntry{
+		UserID:    userID,
+		CafeID:    rule.CafeID,
+		Points:    rule.PointsFor(paid),
+		Reason:    models.LoyaltyEarned,
+		Reference: transactionID,
+	}
+	if stamp && rule.StampsRequired > 0 {
+		entry.Stamps = 1
+	}
+	if entry.Points == 0 && entry.Stamps == 0 {
+		return
+	}
+
+	err := c.LoyaltyRepo.Apply(ctx, entry, rule.StampsRequired)
+	if err != nil {
+		log.GetLog().Errorf("Unable to add loyalty points. user: %d, cafe: %d, error: %v", userID, rule.CafeID, err)
+	}
+}
+
+func (c CafeHandler) GetLoyaltyRule(ctx context.Context, cafeID int32) (*models.LoyaltyRule, error) {
+	return c.LoyaltyRepo.GetRule(ctx, cafeID)
+}
+
+func (c CafeHandler) SetLoyaltyRule(ctx context.Context, rule *models.LoyaltyRule) error {
+	if rule.EarnUnit < 0 || rule.PointsPerUnit < 0 || rule.PointValue < 0 || rule.StampsRequired < 0 || rule.StampRewardCap < 0 {
+		return errors.ErrLoyaltyRuleInvalid.Error()
+	}
+	if (rule.EarnUnit == 0) != (rule.PointsPerUnit == 0) {
+		return errors.ErrLoyaltyRuleInvalid.Error()
+	}
+	return c.LoyaltyRepo.SetRule(ctx, rule)
+}
+
+func (u UserHandler) LoyaltyCards(ctx context.Context, userID int32) ([]models.LoyaltyCard, error) {
+	return u.LoyaltyRepo.GetCards(ctx, userID)
+}
+
+func (u UserHandler) LoyaltyHistory(ctx context.Context, userID int32) ([]models.LoyaltyEntry, error) {
+	return u.LoyaltyRepo.GetHistory(ctx, userID, loyaltyHistoryLimit)
+}
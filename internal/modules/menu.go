@@ -0,0 +1,391 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	menuImportLimit = 500
+	MenuFormatCSV   = "csv"
+	MenuFormatJSON  = "json"
+)
+
+// normalizeMenuOptions checks the option groups of an item priced at price and fills in what can be
+// implied: a variant group is one required choice, and a modifier group with no maximum allows every choice.
+func normalizeMenuOptions(options []models.MenuOptionGroup, price float64) error {
+	groups := map[string]bool{}
+	for i := range options {
+		group := &options[i]
+		group.Name = strings.TrimSpace(group.Name)
+		if group.Name == "" || groups[group.Name] || len(group.Choices) == 0 {
+			return errors.ErrMenuOptionsInvalid.Error()
+		}
+		groups[group.Name] = true
+
+		choices := map[string]bool{}
+		for j := range group.Choices {
+			choice := &group.Choices[j]
+			choice.Name = strings.TrimSpace(choice.Name)
+			if choice.Name == "" || choices[choice.Name] || price+choice.PriceDelta < 0 {
+				return errors.ErrMenuOptionsInvalid.Error()
+			}
+			choices[choice.Name] = true
+		}
+
+		switch group.Kind {
+		case models.MenuOptionVariant:
+			group.MinSelect, group.MaxSelect = 1, 1
+		case models.MenuOptionModifier, "":
+			group.Kind = models.MenuOptionModifier
+			if group.MaxSelect == 0 {
+				group.MaxSelect = len(group.Choices)
+			}
+			if group.Required && group.MinSelect == 0 {
+				group.MinSelect = 1
+			}
+		default:
+			return errors.ErrMenuOptionsInvalid.Error()
+		}
+
+		if group.MinSelect < 0 || group.MinSelect > group.MaxSelect || group.MaxSelect > len(group.Choices) {
+			return errors.ErrMenuOptionsInvalid.Error()
+		}
+		group.Required = group.MinSelect > 0
+	}
+	return nil
+}
+
+// normalizeDietInfo checks an item's allergens, dietary tags and nutrition, drops repeats and marks vegan
+// items vegetarian too. A tag cannot go with an allergen it rules out, like vegan with dairy.
+func normalizeDietInfo(item *models.MenuItem) error {
+	allergens := []models.Allergen{}
+	contains := map[models.Allergen]bool{}
+	for _, allergen := range item.Allergens {
+		if _, ok := models.AllergenPersians[allergen]; !ok {
+			return errors.ErrMenuDietInfoInvalid.Error()
+		}
+		if !contains[allergen] {
+			contains[allergen] = true
+			allergens = append(allergens, allergen)
+		}
+	}
+
+	dietary := []models.DietaryTag{}
+	tagged := map[models.DietaryTag]bool{}
+	add := func(tag models.DietaryTag) {
+		if !tagged[tag] {
+			tagged[tag] = true
+			dietary = append(dietary, tag)
+		}
+	}
+	for _, tag := range item.Dietary {
+		if _, ok := models.DietaryTagPersians[tag]; !ok {
+			return errors.ErrMenuDietInfoInvalid.Error()
+		}
+		add(tag)
+	}
+	if tagged[models.DietaryVegan] {
+		add(models.DietaryVegetarian)
+	}
+	for _, tag := range dietary {
+		for _, allergen := range models.DietaryConflicts[tag] {
+			if contains[allergen] {
+				return errors.ErrMenuDietInfoInvalid.Error()
+			}
+		}
+	}
+
+	if (item.Calories != nil && *item.Calories < 0) || (item.CaffeineMg != nil && *item.CaffeineMg < 0) {
+		return errors.ErrMenuDietInfoInvalid.Error()
+	}
+
+	item.Allergens, item.Dietary = allergens, dietary
+	return nil
+}
+
+// menuItemMatches reports whether the item has every tag in the filter and none of its allergens.
+func menuItemMatches(item *models.MenuItem, filter models.MenuFilter) bool {
+	for _, tag := range filter.Dietary {
+		if !slices.Contains(item.Dietary, tag) {
+			return false
+		}
+	}
+	for _, allergen := range filter.ExcludeAllergens {
+		if slices.Contains(item.Allergens, allergen) {
+			return false
+		}
+	}
+	return true
+}
+
+// menuDay is the calendar day of t in Iran, as a date the repo can store.
+func menuDay(t time.Time) time.Time {
+	t = t.In(utils.IranLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func validateMenuSchedule(schedule *models.MenuSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	if !utils.CheckCafeTimeValidity(schedule.StartHour) || !utils.CheckCafeTimeValidity(schedule.EndHour) {
+		return errors.ErrMenuScheduleInvalid.Error()
+	}
+	if schedule.StartDate != nil && schedule.EndDate != nil && menuDay(*schedule.EndDate).Before(menuDay(*schedule.StartDate)) {
+		return errors.ErrMenuScheduleInvalid.Error()
+	}
+	return nil
+}
+
+func scheduleCovers(schedule *models.MenuSchedule, at time.Time) bool {
+	if schedule == nil {
+		return true
+	}
+
+	day := menuDay(at)
+	if schedule.StartDate != nil && day.Before(menuDay(*schedule.StartDate)) {
+		return false
+	}
+	if schedule.EndDate != nil && day.After(menuDay(*schedule.EndDate)) {
+		return false
+	}
+
+	if schedule.StartHour == schedule.EndHour {
+		return true
+	}
+	hour := int8(at.In(utils.IranLocation).Hour())
+	if schedule.StartHour < schedule.EndHour {
+		return hour >= schedule.StartHour && hour < schedule.EndHour
+	}
+	return hour >= schedule.StartHour || hour < schedule.EndHour
+}
+
+// markStock fills in whether the item is sold out on day and how much of its daily stock is left.
+func markStock(item *models.MenuItem, day time.Time) {
+	item.SoldOut = item.SoldOutOn != nil && item.SoldOutOn.Equal(day)
+	if item.DailyStock == nil {
+		return
+	}
+
+	used := int32(0)
+	if item.StockDay != nil && item.StockDay.Equal(day) {
+		used = item.StockUsed
+	}
+	left := max(*item.DailyStock-used, 0)
+	item.StockLeft = &left
+	if left == 0 {
+		item.SoldOut = true
+	}
+}
+
+// managedMenuItem returns the item if it is on the menu of the cafe the manager owns.
+func (c CafeHandler) managedMenuItem(ctx context.Context, ownerID int32, itemID int32) (*models.MenuItem, error) {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
+		return nil, err
+	}
+
+	item, err := c.MenuItemRepo.GetByID(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if item.CafeID != cafe.ID {
+		return nil, errors.ErrForbidden.Error()
+	}
+	return item, nil
+}
+
+func (c CafeHandler) SetMenuAvailability(ctx context.Context, ownerID int32, availability *models.MenuAvailability) (*models.MenuItem, error) {
+	_, err := c.managedMenuItem(ctx, ownerID, availability.ItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if availability.DailyStock != nil && *availability.DailyStock < 0 {
+		return nil, errors.ErrBadRequest.Error()
+	}
+	err = validateMenuSchedule(availability.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	var soldOutOn *time.Time
+	if availability.SoldOut {
+		today := menuDay(time.Now())
+		soldOutOn = &today
+	}
+
+	err = c.MenuItemRepo.UpdateAvailability(ctx, availability, soldOutOn)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := c.MenuItemRepo.GetByID(ctx, availability.ItemID)
+	if err != nil {
+		return nil, err
+	}
+	markStock(item, menuDay(time.Now()))
+	return item, nil
+}
+
+// UseMenuStock takes sold items off today's stock.
+func (c CafeHandler) UseMenuStock(ctx context.Context, ownerID int32, itemID int32, quantity int32) error {
+	if quantity <= 0 {
+		return errors.ErrBadRequest.Error()
+	}
+
+	_, err := c.managedMenuItem(ctx, ownerID, itemID)
+	if err != nil {
+		return err
+	}
+
+	return c.MenuItemRepo.UseStock(ctx, itemID, menuDay(time.Now()), quantity)
+}
+
+func (c CafeHandler) MenuCategorySchedules(ctx context.Context, cafeID int32) (map[models.MenuItemCategory]*models.MenuSchedule, error) {
+	return c.MenuItemRepo.CategorySchedules(ctx, cafeID)
+}
+
+// SetMenuCategorySchedule schedules a whole category of the manager's menu, or clears it when schedule is nil.
+func (c CafeHandler) SetMenuCategorySchedule(ctx context.Context, ownerID int32, category models.MenuItemCategory, schedule *models.MenuSchedule) error {
+	if _, ok := models.MenuItemCategoryPersians[category]; !ok {
+		return errors.ErrBadRequest.Error()
+	}
+	err := validateMenuSchedule(schedule)
+	if err != nil {
+		return err
+	}
+
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
+		return err
+	}
+
+	return c.MenuItemRepo.SetCategorySchedule(ctx, cafe.ID, category, schedule)
+}
+
+// validateImportItem checks an imported item the way AddMenuItem would, returning what is wrong with it.
+func validateImportItem(item *models.MenuItem) string {
+	item.SKU, item.Name = strings.TrimSpace(item.SKU), strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return "name is required"
+	}
+	if _, ok := models.MenuItemCategoryPersians[item.Category]; !ok {
+		return fmt.Sprintf("invalid category %q", item.Category)
+	}
+	if !utils.CheckPriceValidity(item.Price) {
+		return "invalid price"
+	}
+	if normalizeMenuOptions(item.Options, item.Price) != nil {
+		return "invalid options"
+	}
+	if normalizeDietInfo(item) != nil {
+		return "invalid allergens, dietary tags or nutrition"
+	}
+	return ""
+}
+
+// ImportMenu loads a menu in CSV or JSON into the manager's cafe. Every item is checked first and nothing
+// is written if any of them is wrong; the report lists the problems instead.
+func (c CafeHandler) ImportMenu(ctx context.Context, ownerID int32, format string, body io.Reader, mode models.MenuImportMode, dryRun bool) (*models.MenuImportReport, error) {
+	if mode == "" {
+		mode = models.MenuImportUpsert
+	}
+	if mode != models.MenuImportUpsert && mode != models.MenuImportReplace {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
+		return nil, err
+	}
+
+	var items []models.MenuItem
+	var rows []int
+	var errs []models.MenuImportError
+	switch format {
+	case MenuFormatCSV:
+		items, rows, errs = utils.ParseMenuCSV(body)
+	case MenuFormatJSON, "":
+		err = json.NewDecoder(body).Decode(&items)
+		if err != nil {
+			return nil, errors.ErrBadRequest.Error()
+		}
+		for i := range items {
+			rows = append(rows, i+1)
+		}
+	default:
+		return nil, errors.ErrBadRequest.Error()
+	}
+	if len(items) > menuImportLimit {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	unreadable := len(errs)
+	skus := map[string]bool{}
+	for i := range items {
+		item := &items[i]
+		message := validateImportItem(item)
+		if message == "" && item.SKU != "" {
+			if skus[item.SKU] {
+				message = "duplicate sku"
+			}
+			skus[item.SKU] = true
+		}
+		if message != "" {
+			errs = append(errs, models.MenuImportError{Row: rows[i], SKU: item.SKU, Message: message})
+		}
+	}
+	slices.SortFunc(errs, func(a, b models.MenuImportError) int { return a.Row - b.Row })
+
+	if len(errs) > 0 {
+		return &models.MenuImportReport{Mode: mode, DryRun: dryRun, Total: len(items) + unreadable, Errors: errs}, nil
+	}
+
+	report, err := c.MenuItemRepo.Import(ctx, cafe.ID, items, mode == models.MenuImportReplace, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	report.Mode, report.DryRun, report.Errors = mode, dryRun, []models.MenuImportError{}
+	if report.Applied {
+		c.refreshSearchDocument(ctx, cafe.ID)
+	}
+	return report, nil
+}
+
+// ExportMenu lists every item of the manager's menu in the form ImportMenu reads.
+func (c CafeHandler) ExportMenu(ctx context.Context, ownerID int32) ([]*models.MenuItem, error) {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
+		return nil, err
+	}
+
+	items, err := c.MenuItemRepo.GetItemsByCafeID(ctx, cafe.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		var ingredients []string
+		for _, ingredient := range item.Ingredients {
+			if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+				ingredients = append(ingredients, ingredient)
+			}
+		}
+		item.Ingredients = ingredients
+	}
+	return items, nil
+}
@@ -0,0 +1,219 @@
+package modules
+
+import (
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func iranTime(day int, hour int, minute int) time.Time {
+	return time.Date(2026, time.March, day, hour, minute, 0, 0, utils.IranLocation)
+}
+
+func dayPointer(t time.Time) *time.Time {
+	return &t
+}
+
+func int32Pointer(n int32) *int32 {
+	return &n
+}
+
+func TestMenuDay(t *testing.T) {
+	// 21:00 UTC is already the next day in Iran
+	assert.Equal(t, time.Date(2026, time.March, 11, 0, 0, 0, 0, time.UTC), menuDay(time.Date(2026, time.March, 10, 21, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), menuDay(time.Date(2026, time.March, 10, 20, 29, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2026, time.March, 10, 0, 0, 0, 0, time.UTC), menuDay(iranTime(10, 0, 0)))
+}
+
+func TestScheduleCovers(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule *models.MenuSchedule
+		at       time.Time
+		covers   bool
+	}{
+		{"no schedule", nil, iranTime(10, 3, 0), true},
+		{"same start and end is all day", &models.MenuSchedule{StartHour: 8, EndHour: 8}, iranTime(10, 3, 0), true},
+		{"inside daytime window", &models.MenuSchedule{StartHour: 7, EndHour: 11}, iranTime(10, 7, 0), true},
+		{"end hour is exclusive", &models.MenuSchedule{StartHour: 7, EndHour: 11}, iranTime(10, 11, 0), false},
+		{"before daytime window", &models.MenuSchedule{StartHour: 7, EndHour: 11}, iranTime(10, 6, 59), false},
+		{"overnight before midnight", &models.MenuSchedule{StartHour: 22, EndHour: 2}, iranTime(10, 23, 30), true},
+		{"overnight after midnight", &models.MenuSchedule{StartHour: 22, EndHour: 2}, iranTime(11, 1, 59), true},
+		{"overnight outside", &models.MenuSchedule{StartHour: 22, EndHour: 2}, iranTime(10, 12, 0), false},
+		{"overnight end is exclusive", &models.MenuSchedule{StartHour: 22, EndHour: 2}, iranTime(11, 2, 0), false},
+		{"hours are taken in iran time", &models.MenuSchedule{StartHour: 7, EndHour: 11}, time.Date(2026, time.March, 10, 4, 0, 0, 0, time.UTC), true},
+		{"first day of range", &models.MenuSchedule{StartDate: dayPointer(iranTime(10, 0, 0)), EndDate: dayPointer(iranTime(12, 0, 0))}, iranTime(10, 0, 0), true},
+		{"last day of range", &models.MenuSchedule{StartDate: dayPointer(iranTime(10, 0, 0)), EndDate: dayPointer(iranTime(12, 0, 0))}, iranTime(12, 23, 59), true},
+		{"before range", &models.MenuSchedule{StartDate: dayPointer(iranTime(10, 0, 0))}, iranTime(9, 23, 59), false},
+		{"after range", &models.MenuSchedule{EndDate: dayPointer(iranTime(12, 0, 0))}, iranTime(13, 0, 0), false},
+		{"range end compared by iran day", &models.MenuSchedule{EndDate: dayPointer(iranTime(12, 0, 0))}, time.Date(2026, time.March, 12, 21, 0, 0, 0, time.UTC), false},
+		{"hours within range", &models.MenuSchedule{StartHour: 7, EndHour: 11, StartDate: dayPointer(iranTime(10, 0, 0))}, iranTime(10, 12, 0), false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.covers, scheduleCovers(test.schedule, test.at), test.name)
+	}
+}
+
+func TestValidateMenuSchedule(t *testing.T) {
+	assert.NoError(t, validateMenuSchedule(nil))
+	assert.NoError(t, validateMenuSchedule(&models.MenuSchedule{StartHour: 22, EndHour: 2}))
+	assert.NoError(t, validateMenuSchedule(&models.MenuSchedule{StartDate: dayPointer(iranTime(10, 0, 0)), EndDate: dayPointer(iranTime(10, 0, 0))}))
+	assert.Error(t, validateMenuSchedule(&models.MenuSchedule{StartHour: 25, EndHour: 2}))
+	assert.Error(t, validateMenuSchedule(&models.MenuSchedule{StartDate: dayPointer(iranTime(11, 0, 0)), EndDate: dayPointer(iranTime(10, 0, 0))}))
+}
+
+func TestMarkStock(t *testing.T) {
+	today := menuDay(iranTime(10, 9, 0))
+	yesterday := menuDay(iranTime(9, 9, 0))
+
+	tests := []struct {
+		name    string
+		item    models.MenuItem
+		soldOut bool
+		left    *int32
+	}{
+		{"no limit", models.MenuItem{}, false, nil},
+		{"sold out today", models.MenuItem{SoldOutOn: &today}, true, nil},
+		{"sold out yesterday", models.MenuItem{SoldOutOn: &yesterday}, false, nil},
+		{"stock partly used", models.MenuItem{DailyStock: int32Pointer(10), StockDay: &today, StockUsed: 4}, false, int32Pointer(6)},
+		{"stock used up", models.MenuItem{DailyStock: int32Pointer(10), StockDay: &today, StockUsed: 10}, true, int32Pointer(0)},
+		{"overused stock", models.MenuItem{DailyStock: int32Pointer(10), StockDay: &today, StockUsed: 12}, true, int32Pointer(0)},
+		{"stock rolls over", models.MenuItem{DailyStock: int32Pointer(10), StockDay: &yesterday, StockUsed: 10}, false, int32Pointer(10)},
+		{"stock never used", models.MenuItem{DailyStock: int32Pointer(3)}, false, int32Pointer(3)},
+	}
+
+	for _, test := range tests {
+		item := test.item
+		markStock(&item, today)
+		assert.Equal(t, test.soldOut, item.SoldOut, test.name)
+		assert.Equal(t, test.left, item.StockLeft, test.name)
+	}
+}
+
+func TestMarkStockAtIranMidnight(t *testing.T) {
+	item := models.MenuItem{DailyStock: int32Pointer(5), StockDay: dayPointer(menuDay(iranTime(10, 12, 0))), StockUsed: 5}
+
+	// 23:59 in Tehran is still the day the stock was used up
+	markStock(&item, menuDay(time.Date(2026, time.March, 10, 20, 29, 0, 0, time.UTC)))
+	assert.True(t, item.SoldOut)
+
+	// 00:00 in Tehran starts a new day while it is still March 10 in UTC
+	markStock(&item, menuDay(time.Date(2026, time.March, 10, 20, 30, 0, 0, time.UTC)))
+	assert.False(t, item.SoldOut)
+	assert.Equal(t, int32Pointer(5), item.StockLeft)
+}
+
+func TestNormalizeMenuOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []models.MenuOptionGroup
+		price   float64
+		want    []models.MenuOptionGroup
+		invalid bool
+	}{
+		{
+			name:    "variant is one required choice",
+			options: []models.MenuOptionGroup{{Name: " size ", Kind: models.MenuOptionVariant, MaxSelect: 3, Choices: []models.MenuOption{{Name: "small", PriceDelta: -10}, {Name: " large", PriceDelta: 20}}}},
+			price:   50,
+			want:    []models.MenuOptionGroup{{Name: "size", Kind: models.MenuOptionVariant, Required: true, MinSelect: 1, MaxSelect: 1, Choices: []models.MenuOption{{Name: "small", PriceDelta: -10}, {Name: "large", PriceDelta: 20}}}},
+		},
+		{
+			name:    "modifier defaults to every choice",
+			options: []models.MenuOptionGroup{{Name: "extras", Choices: []models.MenuOption{{Name: "shot", PriceDelta: 15}, {Name: "syrup", PriceDelta: 10}}}},
+			price:   50,
+			want:    []models.MenuOptionGroup{{Name: "extras", Kind: models.MenuOptionModifier, MaxSelect: 2, Choices: []models.MenuOption{{Name: "shot", PriceDelta: 15}, {Name: "syrup", PriceDelta: 10}}}},
+		},
+		{
+			name:    "required modifier needs one choice",
+			options: []models.MenuOptionGroup{{Name: "milk", Kind: models.MenuOptionModifier, Required: true, Choices: []models.MenuOption{{Name: "oat"}, {Name: "soy"}}}},
+			price:   50,
+			want:    []models.MenuOptionGroup{{Name: "milk", Kind: models.MenuOptionModifier, Required: true, MinSelect: 1, MaxSelect: 2, Choices: []models.MenuOption{{Name: "oat"}, {Name: "soy"}}}},
+		},
+		{
+			name:    "minimum makes a modifier required",
+			options: []models.MenuOptionGroup{{Name: "toppings", MinSelect: 2, Choices: []models.MenuOption{{Name: "a"}, {Name: "b"}, {Name: "c"}}}},
+			price:   50,
+			want:    []models.MenuOptionGroup{{Name: "toppings", Kind: models.MenuOptionModifier, Required: true, MinSelect: 2, MaxSelect: 3, Choices: []models.MenuOption{{Name: "a"}, {Name: "b"}, {Name: "c"}}}},
+		},
+		{"empty group name", []models.MenuOptionGroup{{Name: " ", Choices: []models.MenuOption{{Name: "a"}}}}, 50, nil, true},
+		{"repeated group", []models.MenuOptionGroup{{Name: "size", Choices: []models.MenuOption{{Name: "a"}}}, {Name: "size ", Choices: []models.MenuOption{{Name: "b"}}}}, 50, nil, true},
+		{"group without choices", []models.MenuOptionGroup{{Name: "size"}}, 50, nil, true},
+		{"repeated choice", []models.MenuOptionGroup{{Name: "size", Choices: []models.MenuOption{{Name: "a"}, {Name: " a"}}}}, 50, nil, true},
+		{"choice below zero price", []models.MenuOptionGroup{{Name: "size", Choices: []models.MenuOption{{Name: "small", PriceDelta: -60}}}}, 50, nil, true},
+		{"unknown kind", []models.MenuOptionGroup{{Name: "size", Kind: "combo", Choices: []models.MenuOption{{Name: "a"}}}}, 50, nil, true},
+		{"maximum above choices", []models.MenuOptionGroup{{Name: "extras", MaxSelect: 3, Choices: []models.MenuOption{{Name: "a"}, {Name: "b"}}}}, 50, nil, true},
+		{"minimum above maximum", []models.MenuOptionGroup{{Name: "extras", MinSelect: 2, MaxSelect: 1, Choices: []models.MenuOption{{Name: "a"}, {Name: "b"}}}}, 50, nil, true},
+		{"negative minimum", []models.MenuOptionGroup{{Name: "extras", MinSelect: -1, Choices: []models.MenuOption{{Name: "a"}}}}, 50, nil, true},
+	}
+
+	for _, test := range tests {
+		err := normalizeMenuOptions(test.options, test.price)
+		if test.invalid {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.want, test.options, test.name)
+	}
+}
+
+func TestNormalizeDietInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		item      models.MenuItem
+		allergens []models.Allergen
+		dietary   []models.DietaryTag
+		invalid   bool
+	}{
+		{
+			name:      "empty",
+			allergens: []models.Allergen{},
+			dietary:   []models.DietaryTag{},
+		},
+		{
+			name:      "repeats dropped",
+			item:      models.MenuItem{Allergens: []models.Allergen{models.AllergenNuts, models.AllergenGluten, models.AllergenNuts}, Dietary: []models.DietaryTag{models.DietaryHalal, models.DietaryHalal}},
+			allergens: []models.Allergen{models.AllergenNuts, models.AllergenGluten},
+			dietary:   []models.DietaryTag{models.DietaryHalal},
+		},
+		{
+			name:      "vegan is vegetarian",
+			item:      models.MenuItem{Allergens: []models.Allergen{models.AllergenSoy}, Dietary: []models.DietaryTag{models.DietaryVegan}},
+			allergens: []models.Allergen{models.AllergenSoy},
+			dietary:   []models.DietaryTag{models.DietaryVegan, models.DietaryVegetarian},
+		},
+		{
+			name:      "vegetarian already tagged",
+			item:      models.MenuItem{Dietary: []models.DietaryTag{models.DietaryVegetarian, models.DietaryVegan}},
+			allergens: []models.Allergen{},
+			dietary:   []models.DietaryTag{models.DietaryVegetarian, models.DietaryVegan},
+		},
+		{
+			name:      "vegetarian with dairy",
+			item:      models.MenuItem{Allergens: []models.Allergen{models.AllergenDairy}, Dietary: []models.DietaryTag{models.DietaryVegetarian}},
+			allergens: []models.Allergen{models.AllergenDairy},
+			dietary:   []models.DietaryTag{models.DietaryVegetarian},
+		},
+		{name: "vegan with dairy", item: models.MenuItem{Allergens: []models.Allergen{models.AllergenDairy}, Dietary: []models.DietaryTag{models.DietaryVegan}}, invalid: true},
+		{name: "gluten free with gluten", item: models.MenuItem{Allergens: []models.Allergen{models.AllergenGluten}, Dietary: []models.DietaryTag{models.DietaryGlutenFree}}, invalid: true},
+		{name: "unknown allergen", item: models.MenuItem{Allergens: []models.Allergen{"pollen"}}, invalid: true},
+		{name: "unknown tag", item: models.MenuItem{Dietary: []models.DietaryTag{"keto"}}, invalid: true},
+		{name: "negative calories", item: models.MenuItem{Calories: int32Pointer(-1)}, invalid: true},
+		{name: "negative caffeine", item: models.MenuItem{CaffeineMg: int32Pointer(-5)}, invalid: true},
+	}
+
+	for _, test := range tests {
+		item := test.item
+		err := normalizeDietInfo(&item)
+		if test.invalid {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.allergens, item.Allergens, test.name)
+		assert.Equal(t, test.dietary, item.Dietary, test.name)
+	}
+}
@@ -0,0 +1,120 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	reviewRateWindow    = time.Hour
+	reviewRateLimit     = 5
+	reportHideThreshold = 3
+	maxReportReason     = 500
+	reviewQueuePageSize = 20
+)
+
+// moderateReview decides whether a review's text can go live. Too many writes in a short time are refused
+// outright; profanity, links, phone numbers and text the user already posted elsewhere hold the review
+// for an admin.
+func (c CafeHandler) moderateReview(ctx context.Context, userID int32, cafeID int32, comment string) (models.ReviewStatus, []string, error) {
+	writes, err := c.Reviews.RecentWrites(ctx, userID, time.Now().Add(-reviewRateWindow))
+	if err != nil {
+		return "", nil, err
+	}
+	if writes >= reviewRateLimit {
+		return "", nil, errors.ErrReviewRateLimited.Error()
+	}
+
+	if comment == "" {
+		return models.ReviewPublished, nil, nil
+	}
+
+	flags := utils.ModerationFlags(comment)
+	duplicate, err := c.Reviews.HasDuplicate(ctx, userID, cafeID, comment)
+	if err != nil {
+		return "", nil, err
+	}
+	if duplicate {
+		flags = append(flags, models.ModerationDuplicate)
+	}
+
+	if len(flags) > 0 {
+		return models.ReviewPending, flags, nil
+	}
+	return models.ReviewPublished, nil, nil
+}
+
+// ReportReview lets users flag a review. Enough reports hide it until an admin looks at it.
+func (c CafeHandler) ReportReview(ctx context.Context, userID int32, reviewID int32, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if len([]rune(reason)) > maxReportReason {
+		return errors.ErrBadRequest.Error()
+	}
+
+	review, err := c.Reviews.GetByID(ctx, reviewID)
+	if err != nil {
+		return err
+	}
+	if review == nil {
+		return errors.ErrReviewNotFound.Error()
+	}
+	if review.UserID == userID {
+		return errors.ErrBadRequest.Error()
+	}
+
+	hidden, err := c.Reviews.Report(ctx, reviewID, userID, reason, reportHideThreshold)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		log.GetLog().Infof("Review hidden after reports. review: %d", reviewID)
+	}
+	return nil
+}
+
+func (c CafeHandler) ReviewQueue(ctx context.Context, page int) (*models.ReviewPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	reviews, total, err := c.Reviews.Queue(ctx, reviewQueuePageSize, (page-1)*reviewQueuePageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReviewPage{Reviews: reviews, Total: total}, nil
+}
+
+// ModerateReview publishes or rejects a review from the queue. Approved reviews are not hidden by
+// later reports.
+func (c CafeHandler) ModerateReview(ctx context.Context, reviewID int32, approve bool) error {
+	status := models.ReviewRejected
+	if approve {
+		status = models.ReviewApproved
+	}
+	return c.Reviews.SetStatus(ctx, reviewID, status)
+}
+
+func (c CafeHandler) ReviewPhotoQueue(ctx context.Context, page int) (*models.ReviewPhotoPage, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	photos, total, err := c.ReviewPhotos.Queue(ctx, reviewQueuePageSize, (page-1)*reviewQueuePageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReviewPhotoPage{Photos: photos, Total: total}, nil
+}
+
+func (c CafeHandler) ModerateReviewPhoto(ctx context.Context, imageID string, approve bool) error {
+	status := models.ReviewRejected
+	if approve {
+		status = models.ReviewApproved
+	}
+	return c.ReviewPhotos.SetStatus(ctx, imageID, status)
+}
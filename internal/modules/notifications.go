@@ -0,0 +1,22 @@
+package modules
+
+import (
+	"barista/pkg/models"
+	"context"
+)
+
+const notificationsLimit = 50
+
+func (u UserHandler) Notifications(ctx context.Context, userID int32) ([]models.Notification, int, error) {
+	notifications, err := u.NotificationRepo.GetByUserID(ctx, userID, notificationsLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unread, err := u.NotificationRepo.UnreadCount(ctx, userID)
+	return notifications, unread, err
+}
+
+func (u UserHandler) ReadNotifications(ctx context.Context, userID int32, ids []int32) error {
+	return u.NotificationRepo.MarkRead(ctx, userID, ids)
+}
@@ -1,14 +1,27 @@
 package modules
 
 import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
 	"barista/pkg/models"
 	"barista/pkg/repo"
+	"barista/pkg/utils"
 	"context"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	transferRequestTTL      = 15 * time.Minute
+	transferMessageMaxRunes = 200
 )
 
 type PaymentHandler struct {
-	PaymentRepo repo.Transaction
-	UserRepo    repo.UsersRepo
+	PaymentRepo  repo.Transaction
+	UserRepo     repo.UsersRepo
+	GiftCardRepo repo.GiftCardsRepo
+	TransferRepo repo.TransferRequestsRepo
+	CafeRepo     repo.CafesRepo
 }
 
 func (h PaymentHandler) Transfer(ctx context.Context, userID int32, r *models.RequestTransfer) error {
@@ -52,3 +65,139 @@ func (h PaymentHandler) Balance(ctx context.Context, userID int32) int64 {
 func (h PaymentHandler) TransactionsList(ctx context.Context, userID int32) ([]models.Transaction, error) {
 	return h.PaymentRepo.GetBySenderID(ctx, userID)
 }
+
+// AccountTransactions lists the money both sent and received by the user, such as incoming transfers.
+func (h PaymentHandler) AccountTransactions(ctx context.Context, userID int32) ([]models.Transaction, error) {
+	return h.PaymentRepo.GetBySenderOrReceiverID(ctx, userID)
+}
+
+// findRecipient prefers a verified customer account, since one email can hold both a customer and a manager account.
+func findRecipient(users []*models.User) *models.User {
+	var recipient *models.User
+	for _, user := range users {
+		if !user.IsVerified {
+			continue
+		}
+		if user.Role == models.UserRole {
+			return user
+		}
+		if recipient == nil {
+			recipient = user
+		}
+	}
+	return recipient
+}
+
+func (h PaymentHandler) RequestTransfer(ctx context.Context, userID int32, r *models.RequestTransferByContact) (*models.TransferRequest, error) {
+	if r.Amount <= 0 {
+		return nil, errors.ErrPriceInvalid.Error()
+	}
+	if utf8.RuneCountInString(r.Message) > transferMessageMaxRunes {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	var users []*models.User
+	var err error
+	switch {
+	case r.Email != "":
+		if !utils.CheckEmailValidity(r.Email) {
+			return nil, errors.ErrEmailInvalid.Error()
+		}
+		users, err = h.UserRepo.GetByEmail(ctx, r.Email)
+	case r.Phone != 0:
+		if !utils.CheckPhoneValidity(r.Phone) {
+			return nil, errors.ErrPhoneInvalid.Error()
+		}
+		users, err = h.UserRepo.GetByPhone(ctx, r.Phone)
+	default:
+		return nil, errors.ErrBadRequest.Error()
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to find transfer recipient. error: %v", err)
+		return nil, err
+	}
+
+	balance, err := h.UserRepo.GetBalance(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if balance < r.Amount {
+		return nil, errors.ErrNotEnoughBalance.Error()
+	}
+
+	request := &models.TransferRequest{
+		SenderID:  userID,
+		Amount:    r.Amount,
+		Message:   r.Message,
+		ExpiresAt: time.Now().UTC().Add(transferRequestTTL),
+	}
+	// a contact without an account still gets a request, one that cannot be confirmed, so the answer does not
+	// tell whether the email or phone is registered
+	if recipient := findRecipient(users); recipient != nil {
+		if recipient.ID == userID {
+			return nil, errors.ErrSelfTransfer.Error()
+		}
+		request.ReceiverID = recipient.ID
+	}
+
+	err = h.TransferRepo.Create(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func (h PaymentHandler) ConfirmTransfer(ctx context.Context, userID int32, transferID int32) (string, error) {
+	return h.TransferRepo.Confirm(ctx, transferID, userID)
+}
+
+func (h PaymentHandler) CancelTransfer(ctx context.Context, userID int32, transferID int32) error {
+	return h.TransferRepo.Cancel(ctx, transferID, userID)
+}
+
+func (h PaymentHandler) TransferRequests(ctx context.Context, userID int32) ([]models.TransferRequest, error) {
+	return h.TransferRepo.GetBySenderID(ctx, userID)
+}
+
+func (h PaymentHandler) BuyGiftCard(ctx context.Context, userID int32, r *models.RequestBuyGiftCard) (*models.GiftCard, error) {
+	if r.Amount <= 0 {
+		return nil, errors.ErrPriceInvalid.Error()
+	}
+	if r.CafeID != 0 {
+		_, err := h.CafeRepo.GetByID(ctx, r.CafeID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to get gift card cafe. error: %v", err)
+			return nil, err
+		}
+	}
+
+	card := &models.GiftCard{
+		BuyerID: userID,
+		CafeID:  r.CafeID,
+		Amount:  r.Amount,
+	}
+	err := h.GiftCardRepo.Purchase(ctx, card)
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+func (h PaymentHandler) GiftCards(ctx context.Context, userID int32) ([]models.GiftCard, error) {
+	return h.GiftCardRepo.GetByBuyerID(ctx, userID)
+}
+
+func (h PaymentHandler) GiftCardBalance(ctx context.Context, code string) (*models.GiftCard, error) {
+	card, err := h.GiftCardRepo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	// the code is the only secret, so anyone checking a balance doesn't need to see who bought it
+	card.BuyerID = 0
+	card.RedeemedBy = 0
+	return card, nil
+}
+
+func (h PaymentHandler) RedeemGiftCard(ctx context.Context, userID int32, r *models.RequestRedeemGiftCard) (int64, error) {
+	return h.GiftCardRepo.RedeemToWallet(ctx, r.Code, userID)
+}
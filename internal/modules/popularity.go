@@ -0,0 +1,98 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	popularityHalfLife = 30 * 24 * time.Hour
+	trendingHalfLife   = 3 * 24 * time.Hour
+	trendingWindow     = 14 * 24 * time.Hour
+	trendingLimit      = 10
+	trendingMaxLimit   = 50
+)
+
+// activityWeights rank how much each kind of activity says about a cafe; a view is cheap, a reservation is not.
+var activityWeights = models.ActivitySignals{Reservations: 3, Favorites: 2, Comments: 1.5, Views: 0.2}
+
+func activityScore(a models.ActivitySignals) float64 {
+	return activityWeights.Reservations*a.Reservations + activityWeights.Favorites*a.Favorites +
+		activityWeights.Comments*a.Comments + activityWeights.Views*a.Views
+}
+
+// popularityScores combines the Bayesian rating with decayed activity. Activity is taken on a log scale
+// so a busy cafe does not drown out how much people liked it.
+func popularityScores(signals []models.PopularitySignals, meanRating float64) []models.CafePopularity {
+	scores := make([]models.CafePopularity, 0, len(signals))
+	for _, s := range signals {
+		rating := utils.BayesianAverage(s.RatingSum, s.RatingCount, meanRating, models.RatingPriorWeight)
+		scores = append(scores, models.CafePopularity{
+			CafeID:     s.CafeID,
+			Rating:     rating,
+			Popularity: rating + math.Log1p(activityScore(s.Recent)),
+			Trending:   math.Log1p(activityScore(s.Trend)),
+		})
+	}
+	return scores
+}
+
+func (c CafeHandler) RecomputePopularity(ctx context.Context) error {
+	signals, mean, err := c.Popularity.Signals(ctx, popularityHalfLife, trendingHalfLife, trendingWindow)
+	if err != nil {
+		return err
+	}
+
+	return c.Popularity.Save(ctx, popularityScores(signals, mean))
+}
+
+// recordView is best effort; owners looking at their own page are not counted, and neither are anonymous
+// visitors whose address is unknown. Addresses are stored as a keyed hash that changes every day.
+func (c CafeHandler) recordView(ctx context.Context, cafe *models.Cafe, userID int32, clientIP string) {
+	if userID != 0 && userID == cafe.OwnerID {
+		return
+	}
+
+	var visitor string
+	if userID == 0 {
+		if clientIP == "" {
+			return
+		}
+		visitor = utils.VisitorHash(clientIP, time.Now())
+	}
+
+	err := c.Popularity.RecordView(ctx, cafe.ID, userID, visitor)
+	if err != nil {
+		log.GetLog().Errorf("Unable to record view. cafe: %d, error: %v", cafe.ID, err)
+	}
+}
+
+// Trending lists the cafes with the most activity lately in the given city, or the one at the given
+// position. Without either it lists trending cafes across the country.
+func (c CafeHandler) Trending(ctx context.Context, city int, lat *float64, lng *float64, limit int) (int, []models.CafeCard, error) {
+	if city != 0 {
+		if _, ok := models.CityByID[city]; !ok {
+			return 0, nil, errors.ErrBadRequest.Error()
+		}
+	} else if lat != nil && lng != nil && utils.AreaLookupAvailable() {
+		_, city = utils.LocateArea(*lat, *lng)
+	}
+	if limit <= 0 {
+		limit = trendingLimit
+	} else if limit > trendingMaxLimit {
+		limit = trendingMaxLimit
+	}
+
+	ids, err := c.Popularity.Trending(ctx, city, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	cards, err := c.cafeCardsByIDs(ctx, ids)
+	return city, cards, err
+}
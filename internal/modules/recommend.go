@@ -0,0 +1,359 @@
+package modules
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	similarCafesKept       = 20
+	similarCafesShown      = 6
+	recommendationsKept    = 20
+	recommendationsLimit   = 10
+	recommendationsEvalK   = 10
+	recommendationsMaxAge  = 24 * time.Hour
+	recommendationsHoldout = 5
+	// a rating of 4 or 5 on a held-out cafe means recommending it would have been right
+	relevantRating = 4
+	// similarities backed by only a few shared users are pulled towards zero
+	collaborativeShrinkage = 5.0
+	contentWeight          = 0.5
+)
+
+// amenityKeys maps the Persian amenity names some cafes were saved with back to their keys.
+var amenityKeys = func() map[string]string {
+	keys := map[string]string{}
+	for key, persian := range models.AmenityCategoryPersians {
+		keys[persian] = string(key)
+	}
+	return keys
+}()
+
+func normalizeAmenities(features []models.CafeFeatures) {
+	for i := range features {
+		for j, amenity := range features[i].Amenities {
+			if key, ok := amenityKeys[amenity]; ok {
+				features[i].Amenities[j] = key
+			}
+		}
+	}
+}
+
+// interactionWeight turns a rating into a signal between -1 and 1 around a neutral three stars.
+// Saving a cafe as a favorite is the strongest signal there is.
+func interactionWeight(interaction models.Interaction) float64 {
+	if interaction.Favorite || interaction.Rating == 0 {
+		return 1
+	}
+	return float64(interaction.Rating-3) / 2
+}
+
+func contentSimilarity(a, b *models.CafeFeatures) float64 {
+	score := 0.4*utils.Jaccard(a.Categories, b.Categories) + 0.3*utils.Jaccard(a.Amenities, b.Amenities)
+	if !math.IsInf(a.Price, 1) && !math.IsInf(b.Price, 1) {
+		if highest := math.Max(a.Price, b.Price); highest > 0 {
+			score += 0.15 * (1 - math.Abs(a.Price-b.Price)/highest)
+		}
+	}
+	if a.City != 0 && a.City == b.City {
+		score += 0.15
+	}
+	return score
+}
+
+// collaborativeSimilarities compares cafes by the users who rated or saved both of them.
+func collaborativeSimilarities(users map[int32]map[int32]float64) map[int32]map[int32]float64 {
+	vectors := map[int32]map[int32]float64{}
+	shared := map[[2]int32]int{}
+	for userID, cafes := range users {
+		ids := make([]int32, 0, len(cafes))
+		for cafeID, weight := range cafes {
+			if vectors[cafeID] == nil {
+				vectors[cafeID] = map[int32]float64{}
+			}
+			vectors[cafeID][userID] = weight
+			ids = append(ids, cafeID)
+		}
+		for i := range ids {
+			for j := range ids {
+				if ids[i] < ids[j] {
+					shared[[2]int32{ids[i], ids[j]}]++
+				}
+			}
+		}
+	}
+
+	similarities := map[int32]map[int32]float64{}
+	for pair, count := range shared {
+		similarity := utils.Cosine(vectors[pair[0]], vectors[pair[1]]) * float64(count) / (float64(count) + collaborativeShrinkage)
+		if similarities[pair[0]] == nil {
+			similarities[pair[0]] = map[int32]float64{}
+		}
+		similarities[pair[0]][pair[1]] = similarity
+	}
+	return similarities
+}
+
+// buildNeighbours keeps the most similar cafes of each cafe, blending content and collaborative similarity.
+func buildNeighbours(features []models.CafeFeatures, users map[int32]map[int32]float64) map[int32]map[int32]float64 {
+	collaborative := collaborativeSimilarities(users)
+
+	all := map[int32]map[int32]float64{}
+	for i := range features {
+		all[features[i].ID] = map[int32]float64{}
+	}
+	for i := range features {
+		for j := i + 1; j < len(features); j++ {
+			a, b := features[i].ID, features[j].ID
+			low, high := a, b
+			if low > high {
+				low, high = high, low
+			}
+			score := contentWeight*contentSimilarity(&features[i], &features[j]) + (1-contentWeight)*collaborative[low][high]
+			if score > 0 {
+				all[a][b], all[b][a] = score, score
+			}
+		}
+	}
+
+	neighbours := map[int32]map[int32]float64{}
+	for cafeID, scores := range all {
+		neighbours[cafeID] = map[int32]float64{}
+		for _, similarID := range utils.TopK(scores, similarCafesKept) {
+			neighbours[cafeID][similarID] = scores[similarID]
+		}
+	}
+	return neighbours
+}
+
+// recommendFor scores the cafes a user has not interacted with by how similar they are to the ones they have.
+func recommendFor(neighbours map[int32]map[int32]float64, cafes map[int32]float64) map[int32]float64 {
+	scores := map[int32]float64{}
+	for cafeID, weight := range cafes {
+		for similarID, similarity := range neighbours[cafeID] {
+			if _, seen := cafes[similarID]; !seen {
+				scores[similarID] += weight * similarity
+			}
+		}
+	}
+	for cafeID, score := range scores {
+		if score <= 0 {
+			delete(scores, cafeID)
+		}
+	}
+	return scores
+}
+
+// evaluateRecommendations holds out every fifth rating of each user, builds a model without them, and
+// measures how many of the well rated held-out cafes it would have recommended.
+func evaluateRecommendations(features []models.CafeFeatures, interactions []models.Interaction, run *models.RecommendationRun) {
+	sort.Slice(interactions, func(i, j int) bool {
+		if interactions[i].UserID != interactions[j].UserID {
+			return interactions[i].UserID < interactions[j].UserID
+		}
+		return interactions[i].CafeID < interactions[j].CafeID
+	})
+
+	train := map[int32]map[int32]float64{}
+	relevant := map[int32]map[int32]bool{}
+	rated := map[int32]int{}
+	for _, interaction := range interactions {
+		if train[interaction.UserID] == nil {
+			train[interaction.UserID] = map[int32]float64{}
+		}
+		if interaction.Rating > 0 {
+			rated[interaction.UserID]++
+			// the first rating always stays in training, so the user is not left without history
+			if rated[interaction.UserID]%recommendationsHoldout == 0 {
+				run.HeldOut++
+				if interaction.Rating >= relevantRating {
+					if relevant[interaction.UserID] == nil {
+						relevant[interaction.UserID] = map[int32]bool{}
+					}
+					relevant[interaction.UserID][interaction.CafeID] = true
+				}
+				continue
+			}
+		}
+		train[interaction.UserID][interaction.CafeID] = interactionWeight(interaction)
+	}
+
+	neighbours := buildNeighbours(features, train)
+	var precision, recall float64
+	for userID, cafes := range relevant {
+		recommended := utils.TopK(recommendFor(neighbours, train[userID]), recommendationsEvalK)
+		p, r := utils.PrecisionRecallAtK(recommended, cafes, recommendationsEvalK)
+		precision += p
+		recall += r
+	}
+
+	run.K = recommendationsEvalK
+	if len(relevant) > 0 {
+		run.PrecisionAtK = precision / float64(len(relevant))
+		run.RecallAtK = recall / float64(len(relevant))
+	}
+}
+
+// RecomputeRecommendations rebuilds similar cafes and per-user recommendations from all ratings and
+// favorites. It is too heavy for a request and runs as a scheduled job.
+func (c CafeHandler) RecomputeRecommendations(ctx context.Context) (*models.RecommendationRun, error) {
+	features, err := c.RecommendationRepo.CafeFeatures(ctx)
+	if err != nil {
+		return nil, err
+	}
+	normalizeAmenities(features)
+
+	interactions, err := c.RecommendationRepo.Interactions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.RecommendationRun{CreatedAt: time.Now(), Cafes: len(features)}
+	evaluateRecommendations(features, interactions, run)
+
+	users := map[int32]map[int32]float64{}
+	for _, interaction := range interactions {
+		if users[interaction.UserID] == nil {
+			users[interaction.UserID] = map[int32]float64{}
+		}
+		users[interaction.UserID][interaction.CafeID] = interactionWeight(interaction)
+	}
+	run.Users = len(users)
+
+	neighbours := buildNeighbours(features, users)
+	var similarities []models.CafeSimilarity
+	for cafeID, scores := range neighbours {
+		for similarID, score := range scores {
+			similarities = append(similarities, models.CafeSimilarity{CafeID: cafeID, SimilarID: similarID, Score: score})
+		}
+	}
+
+	var recommendations []models.UserRecommendation
+	covered := map[int32]bool{}
+	for userID, cafes := range users {
+		scores := recommendFor(neighbours, cafes)
+		for _, cafeID := range utils.TopK(scores, recommendationsKept) {
+			covered[cafeID] = true
+			recommendations = append(recommendations, models.UserRecommendation{UserID: userID, CafeID: cafeID, Score: scores[cafeID]})
+		}
+	}
+	if len(features) > 0 {
+		run.Coverage = float64(len(covered)) / float64(len(features))
+	}
+
+	err = c.RecommendationRepo.Save(ctx, similarities, recommendations, run)
+	if err != nil {
+		return nil, err
+	}
+
+	log.GetLog().WithField("cafes", run.Cafes).WithField("users", run.Users).WithField("held_out", run.HeldOut).
+		WithField("precision_at_k", run.PrecisionAtK).WithField("recall_at_k", run.RecallAtK).WithField("coverage", run.Coverage).
+		Info("Recomputed recommendations")
+	return run, nil
+}
+
+func (c CafeHandler) LatestRecommendationRun(ctx context.Context) (*models.RecommendationRun, error) {
+	return c.RecommendationRepo.LatestRun(ctx)
+}
+
+// RecommendationsStale tells whether the model is missing or older than a day, e.g. after downtime over the nightly run.
+func (c CafeHandler) RecommendationsStale(ctx context.Context) bool {
+	run, err := c.RecommendationRepo.LatestRun(ctx)
+	return err != nil || time.Since(run.CreatedAt) > recommendationsMaxAge
+}
+
+// cafeCardsByIDs returns the visible cafes among ids as cards, in the order given.
+func (c CafeHandler) cafeCardsByIDs(ctx context.Context, ids []int32) ([]models.CafeCard, error) {
+	cards := []models.CafeCard{}
+	if len(ids) == 0 {
+		return cards, nil
+	}
+
+	filter := &models.CafeSearchFilter{IDs: ids, Limit: len(ids)}
+	err := prepareSearchFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.CafeRepo.SearchCafe(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	cafes := map[int32]models.Cafe{}
+	for _, cafe := range result.Cafes {
+		cafes[cafe.ID] = cafe
+	}
+	ordered := []models.Cafe{}
+	for _, id := range ids {
+		if cafe, ok := cafes[id]; ok {
+			ordered = append(ordered, cafe)
+		}
+	}
+	return c.cafeCards(ctx, ordered), nil
+}
+
+// SimilarCafes serves the precomputed neighbours of a cafe. A cafe added since the last rebuild has
+// none yet and gets the best rated cafes of the same kind in its city instead.
+func (c CafeHandler) SimilarCafes(ctx context.Context, cafe *models.Cafe) ([]models.CafeCard, error) {
+	ids, err := c.RecommendationRepo.SimilarCafes(ctx, cafe.ID, similarCafesShown)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		return c.cafeCardsByIDs(ctx, ids)
+	}
+
+	_, categories := favoriteTaste([]models.Cafe{*cafe})
+	section, err := c.cafeFeedSection(ctx, models.FeedForYou, &models.CafeSearchFilter{
+		City:       cafe.ContactInfo.City,
+		Categories: categories,
+		ExcludeIDs: []int32{cafe.ID},
+		Sort:       models.SearchSortRating,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(section.Cafes) > similarCafesShown {
+		section.Cafes = section.Cafes[:similarCafesShown]
+	}
+	return section.Cafes, nil
+}
+
+// Recommendations serves a user's precomputed recommendations. Users the model has not seen yet get
+// the best rated cafes of the city they favorite most.
+func (c CafeHandler) Recommendations(ctx context.Context, userID int32, limit int) ([]models.CafeCard, error) {
+	if limit <= 0 || limit > recommendationsKept {
+		limit = recommendationsLimit
+	}
+
+	ids, err := c.RecommendationRepo.UserRecommendations(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		return c.cafeCardsByIDs(ctx, ids)
+	}
+
+	favorites, err := c.GetFavoriteList(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	excluded, _ := favoriteTaste(favorites)
+	section, err := c.cafeFeedSection(ctx, models.FeedTopRated, &models.CafeSearchFilter{
+		City:       favoriteCity(favorites),
+		ExcludeIDs: excluded,
+		Sort:       models.SearchSortRating,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(section.Cafes) > limit {
+		section.Cafes = section.Cafes[:limit]
+	}
+	return section.Cafes, nil
+}
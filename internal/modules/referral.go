@@ -0,0 +1,61 @@
+package modules
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/repo"
+	"context"
+)
+
+const (
+	referralRewardAmount = 20000
+)
+
+// ReferralHandler is shared by the user and cafe handlers, since a referral is paid out across signup, verification and the first reservation.
+type ReferralHandler struct {
+	ReferralRepo repo.ReferralsRepo
+}
+
+func (h ReferralHandler) ReferrerID(ctx context.Context, code string) (int32, error) {
+	return h.ReferralRepo.GetReferrerByCode(ctx, code)
+}
+
+// CreateCode gives the user a referral code, or returns the one they already have.
+func (h ReferralHandler) CreateCode(ctx context.Context, userID int32) (string, error) {
+	return h.ReferralRepo.GetOrCreateCode(ctx, userID)
+}
+
+func (h ReferralHandler) Refer(ctx context.Context, referrerID int32, referredID int32) {
+	referral := &models.Referral{ReferrerID: referrerID, ReferredID: referredID}
+	err := h.ReferralRepo.Create(ctx, referral)
+	if err != nil {
+		log.GetLog().Errorf("Unable to create referral. error: %v", err)
+		return
+	}
+	if referral.Status == models.ReferralRejected {
+		log.GetLog().Warnf("Referral rejected. referrer: %d, referred: %d, reason: %s", referrerID, referredID, referral.Reason)
+	}
+}
+
+func (h ReferralHandler) EmailVerified(ctx context.Context, email string) {
+	err := h.ReferralRepo.MarkVerified(ctx, email)
+	if err != nil {
+		log.GetLog().Errorf("Unable to update referral after verification. error: %v", err)
+	}
+}
+
+// PaidReservation rewards the referral of userID if this is their first paid reservation; later calls do nothing.
+func (h ReferralHandler) PaidReservation(ctx context.Context, userID int32) {
+	rewarded, err := h.ReferralRepo.Reward(ctx, userID, referralRewardAmount)
+	if err != nil {
+		log.GetLog().Errorf("Unable to reward referral. user: %d, error: %v", userID, err)
+		return
+	}
+	if rewarded {
+		log.GetLog().Infof("Referral rewarded. user: %d", userID)
+	}
+}
+
+func (h ReferralHandler) Stats(ctx context.Context, userID int32) (*models.ReferralStats, error) {
+	return h.ReferralRepo.GetStats(ctx, userID)
+}
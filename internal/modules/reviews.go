@@ -0,0 +1,211 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	reviewsPageSize    = 10
+	maxReviewsPageSize = 50
+	maxReplyLength     = 2000
+	ratingTrendWindow  = 90 * 24 * time.Hour
+	reviewPhotoLimit   = 5
+	visitorGallerySize = 30
+)
+
+func validScore(score *int32) bool {
+	return score == nil || (*score >= 1 && *score <= 5)
+}
+
+// WriteReview creates the user's review of a cafe or edits the one they already wrote; the replaced
+// version stays in the review's history.
+func (c CafeHandler) WriteReview(ctx context.Context, userID int32, review *models.Review) (*models.Review, error) {
+	review.UserID = userID
+	review.Comment = strings.TrimSpace(review.Comment)
+	if review.Rating < 1 || review.Rating > 5 ||
+		!validScore(review.Coffee) || !validScore(review.Service) || !validScore(review.Ambience) || !validScore(review.Value) {
+		return nil, errors.ErrReviewInvalid.Error()
+	}
+	if review.VisitDate != nil && review.VisitDate.After(time.Now()) {
+		return nil, errors.ErrReviewInvalid.Error()
+	}
+
+	cafe, err := c.CafeRepo.GetByID(ctx, review.CafeID)
+	if err != nil || cafe.Hidden {
+		return nil, errors.ErrCafeNotFound.Error()
+	}
+
+	review.Status, review.Flags, err = c.moderateReview(ctx, userID, review.CafeID, review.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Reviews.Upsert(ctx, review)
+	if err != nil {
+		log.GetLog().Errorf("Unable to write review. error: %v", err)
+		return nil, err
+	}
+
+	return c.Reviews.GetByUserAndCafe(ctx, userID, review.CafeID)
+}
+
+// CafeReviews pages through a cafe's reviews, newest first unless another order is asked for.
+func (c CafeHandler) CafeReviews(ctx context.Context, cafeID int32, query models.ReviewQuery) (*models.ReviewPage, error) {
+	if query.Sort == "" {
+		query.Sort = models.ReviewSortNewest
+	}
+	if query.Limit <= 0 {
+		query.Limit = reviewsPageSize
+	}
+	if query.Limit > maxReviewsPageSize {
+		query.Limit = maxReviewsPageSize
+	}
+
+	return c.Reviews.GetByCafeID(ctx, cafeID, query)
+}
+
+func (c CafeHandler) DeleteReview(ctx context.Context, userID int32, cafeID int32) error {
+	return c.Reviews.Delete(ctx, userID, cafeID)
+}
+
+// ReviewHistory lists a review's earlier versions. They never went through moderation in their final form,
+// so only the author, the reviewed cafe's manager and admins may see them.
+func (c CafeHandler) ReviewHistory(ctx context.Context, userID int32, role models.Role, reviewID int32) ([]models.ReviewEdit, error) {
+	review, err := c.Reviews.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review == nil {
+		return nil, errors.ErrReviewNotFound.Error()
+	}
+
+	allowed := review.UserID == userID || role == models.AdminRole
+	if !allowed && role == models.ManagerRole {
+		cafe, err := c.CafeRepo.GetByOwnerID(ctx, userID)
+		allowed = err == nil && cafe.ID == review.CafeID
+	}
+	if !allowed {
+		return nil, errors.ErrReviewNotFound.Error()
+	}
+	return c.Reviews.History(ctx, reviewID)
+}
+
+// ownReview finds a review of the manager's cafe.
+func (c CafeHandler) ownReview(ctx context.Context, ownerID int32, reviewID int32) (*models.Cafe, *models.Review, error) {
+	cafe, err := c.CafeRepo.GetByOwnerID(ctx, ownerID)
+	if err != nil {
+		return nil, nil, errors.ErrCafeNotFound.Error()
+	}
+
+	review, err := c.Reviews.GetByID(ctx, reviewID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if review == nil || review.CafeID != cafe.ID {
+		return nil, nil, errors.ErrReviewNotFound.Error()
+	}
+	return cafe, review, nil
+}
+
+// ReplyToReview posts the manager's public reply to a review of their cafe, replacing an earlier one,
+// and lets the reviewer know.
+func (c CafeHandler) ReplyToReview(ctx context.Context, ownerID int32, reviewID int32, reply string) (*models.Review, error) {
+	reply = strings.TrimSpace(reply)
+	if reply == "" || len([]rune(reply)) > maxReplyLength {
+		return nil, errors.ErrReviewInvalid.Error()
+	}
+
+	cafe, review, err := c.ownReview(ctx, ownerID, reviewID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.Reviews.SetReply(ctx, reviewID, &reply)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.NotificationRepo.Create(ctx, &models.Notification{
+		UserID:      review.UserID,
+		Kind:        models.NotificationReviewReply,
+		Title:       fmt.Sprintf("%s به نظر شما پاسخ داد", cafe.Name),
+		Body:        reply,
+		ReferenceID: reviewID,
+	})
+	if err != nil {
+		log.GetLog().Errorf("Unable to notify reviewer. review: %d, error: %v", reviewID, err)
+	}
+
+	return c.Reviews.GetByID(ctx, reviewID)
+}
+
+func (c CafeHandler) DeleteReply(ctx context.Context, ownerID int32, reviewID int32) error {
+	_, _, err := c.ownReview(ctx, ownerID, reviewID)
+	if err != nil {
+		return err
+	}
+	return c.Reviews.SetReply(ctx, reviewID, nil)
+}
+
+// AddReviewPhoto attaches an image uploaded through the image service to the user's review of the cafe.
+// An image that was never uploaded, or is already used anywhere else, is refused.
+func (c CafeHandler) AddReviewPhoto(ctx context.Context, userID int32, cafeID int32, imageID string) (*models.ReviewPhoto, error) {
+	review, err := c.Reviews.GetByUserAndCafe(ctx, userID, cafeID)
+	if err != nil {
+		return nil, err
+	}
+	if review == nil {
+		return nil, errors.ErrReviewNotFound.Error()
+	}
+
+	uploaded, err := c.ImageFiles.Exists(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if !uploaded {
+		return nil, errors.ErrImageNotFound.Error()
+	}
+
+	used, err := c.ImageRepo.CheckExistence(ctx, imageID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		used, err = c.ReviewPhotos.Exists(ctx, imageID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if used {
+		return nil, errors.ErrImageInUse.Error()
+	}
+
+	photo := &models.ReviewPhoto{ImageID: imageID, ReviewID: review.ID, CafeID: cafeID, UserID: userID, Status: models.ReviewPending}
+	err = c.ReviewPhotos.Add(ctx, photo, reviewPhotoLimit)
+	if err != nil {
+		return nil, err
+	}
+	return photo, nil
+}
+
+func (c CafeHandler) DeleteReviewPhoto(ctx context.Context, userID int32, imageID string) error {
+	return c.ReviewPhotos.Delete(ctx, imageID, userID)
+}
+
+// VoteReview marks a review helpful or unhelpful for the user, or clears their vote when helpful is nil,
+// and updates the score reviews are ranked by.
+func (c CafeHandler) VoteReview(ctx context.Context, userID int32, reviewID int32, helpful *bool) (*models.Review, error) {
+	err := c.Reviews.Vote(ctx, reviewID, userID, helpful, utils.WilsonScore)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Reviews.GetByID(ctx, reviewID)
+}
@@ -0,0 +1,195 @@
+package modules
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	searchPageSize       = 20
+	searchMaxPageSize    = 50
+	geoSearchMaxRadiusKm = 50
+)
+
+// searchStopwords are words that describe nearly every cafe, like "کافه" in "کافه باکارا".
+var searchStopwords = func() map[string]bool {
+	words := map[string]bool{"کافی": true, "شاپ": true, "cafe": true, "coffee": true}
+	for _, persian := range models.CafeCategoryPersians {
+		for _, word := range strings.Fields(utils.NormalizePersian(persian)) {
+			words[word] = true
+		}
+	}
+	return words
+}()
+
+// searchTerms normalizes the query and drops stopwords, unless nothing else would be left to search for.
+func searchTerms(query string) (string, []string) {
+	query = utils.NormalizePersian(query)
+	words := strings.Fields(query)
+
+	var terms []string
+	for _, word := range words {
+		if !searchStopwords[word] {
+			terms = append(terms, word)
+		}
+	}
+	if len(terms) == 0 {
+		terms = words
+	}
+	return query, terms
+}
+
+// prepareSearchFilter validates the filter and fills in what the repo needs: terms, defaults and the current hour.
+func prepareSearchFilter(filter *models.CafeSearchFilter) error {
+	filter.Query, filter.Terms = searchTerms(filter.Query)
+
+	for _, category := range filter.Categories {
+		if _, ok := models.CafeCategoryPersians[category]; !ok {
+			return errors.ErrSearchFilterInvalid.Error()
+		}
+	}
+	for _, amenity := range filter.Amenities {
+		if _, ok := models.AmenityCategoryPersians[amenity]; !ok {
+			return errors.ErrSearchFilterInvalid.Error()
+		}
+	}
+	for _, tag := range filter.Dietary {
+		if _, ok := models.DietaryTagPersians[tag]; !ok {
+			return errors.ErrSearchFilterInvalid.Error()
+		}
+	}
+
+	if filter.MinPrice < 0 || filter.MaxPrice < 0 || (filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice) {
+		return errors.ErrSearchFilterInvalid.Error()
+	}
+	if filter.MinRating < 0 || filter.MinRating > 5 {
+		return errors.ErrSearchFilterInvalid.Error()
+	}
+	if (filter.Lat == nil) != (filter.Lng == nil) {
+		return errors.ErrSearchFilterInvalid.Error()
+	}
+	if filter.Lat != nil && (*filter.Lat < -90 || *filter.Lat > 90 || *filter.Lng < -180 || *filter.Lng > 180) {
+		return errors.ErrSearchFilterInvalid.Error()
+	}
+	if filter.InMyCity {
+		if filter.Lat == nil {
+			return errors.ErrSearchFilterInvalid.Error()
+		}
+		if !utils.AreaLookupAvailable() {
+			return errors.ErrAreaLookupOff.Error()
+		}
+		_, city := utils.LocateArea(*filter.Lat, *filter.Lng)
+		if city == 0 {
+			return errors.ErrLocationUnknown.Error()
+		}
+		filter.City = city
+	}
+
+	switch filter.Sort {
+	case "":
+		filter.Sort = models.SearchSortRelevance
+	case models.SearchSortRelevance, models.SearchSortRating, models.SearchSortPrice, models.SearchSortPopularity:
+	case models.SearchSortDistance:
+		if filter.Lat == nil {
+			return errors.ErrSearchFilterInvalid.Error()
+		}
+	default:
+		return errors.ErrSearchFilterInvalid.Error()
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = searchPageSize
+	} else if filter.Limit > searchMaxPageSize {
+		filter.Limit = searchMaxPageSize
+	}
+
+	filter.Hour = time.Now().In(utils.IranLocation).Hour()
+	return nil
+}
+
+// refreshSearchDocument is best effort; a stale document only affects search until the next edit or restart.
+func (c CafeHandler) refreshSearchDocument(ctx context.Context, cafeID int32) {
+	err := c.CafeRepo.RefreshSearchDocument(ctx, cafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to refresh search document. cafe: %d, error: %v", cafeID, err)
+	}
+}
+
+// GeoSearchCafes searches around a point or inside a map viewport. Without a sort, the nearest cafes come first.
+func (c CafeHandler) GeoSearchCafes(ctx context.Context, filter *models.CafeSearchFilter) (*models.CafeCardResult, error) {
+	if filter.Bounds != nil {
+		b := filter.Bounds
+		if b.MinLat > b.MaxLat || b.MinLng > b.MaxLng || b.MinLat < -90 || b.MaxLat > 90 || b.MinLng < -180 || b.MaxLng > 180 {
+			return nil, errors.ErrSearchFilterInvalid.Error()
+		}
+		// distances in a viewport are measured from its center unless the user's position is given
+		if filter.Lat == nil && filter.Lng == nil {
+			lat, lng := (b.MinLat+b.MaxLat)/2, (b.MinLng+b.MaxLng)/2
+			filter.Lat, filter.Lng = &lat, &lng
+		}
+	} else if filter.Lat == nil || filter.RadiusKm <= 0 || filter.RadiusKm > geoSearchMaxRadiusKm {
+		return nil, errors.ErrSearchFilterInvalid.Error()
+	}
+	if filter.Sort == "" {
+		filter.Sort = models.SearchSortDistance
+	}
+
+	err := prepareSearchFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.CafeRepo.SearchCafe(ctx, filter)
+	if err != nil {
+		log.GetLog().Errorf("Unable to geo search cafes. error: %v", err)
+		return nil, err
+	}
+
+	return &models.CafeCardResult{Cafes: c.cafeCards(ctx, result.Cafes), Total: result.Total, NextCursor: result.NextCursor}, nil
+}
+
+// cafeCards turns search results into cards, looking up the ratings and photos of all of them at once.
+// Either lookup failing only leaves those fields empty.
+func (c CafeHandler) cafeCards(ctx context.Context, cafes []models.Cafe) []models.CafeCard {
+	cards := []models.CafeCard{}
+	if len(cafes) == 0 {
+		return cards
+	}
+
+	ids := make([]int32, 0, len(cafes))
+	for _, cafe := range cafes {
+		ids = append(ids, cafe.ID)
+	}
+	ratings, err := c.Reviews.CafeRatings(ctx, ids)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe ratings. error: %v", err)
+	}
+	photos, err := c.ImageRepo.GetLatestByReferenceIDs(ctx, ids)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe images. error: %v", err)
+	}
+
+	for _, cafe := range cafes {
+		cards = append(cards, models.CafeCard{
+			ID:               cafe.ID,
+			Name:             cafe.Name,
+			Categories:       cafe.Categories,
+			Amenities:        cafe.Amenities,
+			OpeningTime:      cafe.OpeningTime,
+			ClosingTime:      cafe.ClosingTime,
+			ReservationPrice: cafe.ReservationPrice,
+			Province:         cafe.ContactInfo.Province,
+			City:             cafe.ContactInfo.City,
+			Location:         cafe.Location,
+			Distance:         cafe.Distance,
+			Rating:           ratings[cafe.ID],
+			Photo:            photos[cafe.ID],
+		})
+	}
+	return cards
+}
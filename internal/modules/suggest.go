@@ -0,0 +1,210 @@
+package modules
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"barista/pkg/utils"
+	"context"
+	"sort"
+	"strings"
+)
+
+const (
+	suggestLimit    = 10
+	suggestMaxLimit = 20
+)
+
+// suggestQuotas caps each kind of suggestion, in the order they are listed to the user.
+var suggestQuotas = []struct {
+	Type  models.SuggestionType
+	Quota int
+}{
+	{models.SuggestionRecent, 3},
+	{models.SuggestionCafe, 5},
+	{models.SuggestionCategory, 2},
+	{models.SuggestionCity, 3},
+	{models.SuggestionProvince, 2},
+	{models.SuggestionMenuItem, 3},
+}
+
+type prefixKey struct {
+	key   string
+	entry int
+}
+
+// prefixIndex answers prefix lookups over a fixed list with a binary search. Every word of an
+// entry is indexed, so typing "غربی" also finds "آذربایجان غربی".
+type prefixIndex struct {
+	entries []models.Suggestion
+	keys    []prefixKey
+}
+
+func (p *prefixIndex) add(suggestion models.Suggestion, names ...string) {
+	p.entries = append(p.entries, suggestion)
+	for _, name := range names {
+		words := strings.Fields(utils.NormalizePersian(name))
+		for i := range words {
+			p.keys = append(p.keys, prefixKey{key: strings.Join(words[i:], " "), entry: len(p.entries) - 1})
+		}
+	}
+}
+
+func (p *prefixIndex) build() *prefixIndex {
+	sort.Slice(p.keys, func(i, j int) bool { return p.keys[i].key < p.keys[j].key })
+	return p
+}
+
+func (p *prefixIndex) lookup(prefixes []string) []models.Suggestion {
+	seen := map[int]bool{}
+	var found []models.Suggestion
+	for _, prefix := range prefixes {
+		i := sort.Search(len(p.keys), func(i int) bool { return p.keys[i].key >= prefix })
+		for ; i < len(p.keys) && strings.HasPrefix(p.keys[i].key, prefix); i++ {
+			if !seen[p.keys[i].entry] {
+				seen[p.keys[i].entry] = true
+				found = append(found, p.entries[p.keys[i].entry])
+			}
+		}
+	}
+	return found
+}
+
+var areaIndex = func() *prefixIndex {
+	index := &prefixIndex{}
+	for _, province := range models.Provinces {
+		index.add(models.Suggestion{Type: models.SuggestionProvince, Text: province.Name, ID: int32(province.ID)}, province.Name)
+	}
+	for _, city := range models.Cities {
+		index.add(models.Suggestion{Type: models.SuggestionCity, Text: city.Name, ID: int32(city.ID)}, city.Name)
+	}
+	// some categories share a Persian name, so suggest each name once under the first key in order
+	categories := []string{}
+	for category := range models.CafeCategoryPersians {
+		categories = append(categories, string(category))
+	}
+	sort.Strings(categories)
+	names := map[string][]string{}
+	for _, category := range categories {
+		persian := models.CafeCategoryPersians[models.CafeCategory(category)]
+		names[persian] = append(names[persian], category)
+	}
+	for persian, keys := range names {
+		aliases := []string{persian}
+		for _, key := range keys {
+			aliases = append(aliases, strings.ReplaceAll(key, "_", " "))
+		}
+		index.add(models.Suggestion{Type: models.SuggestionCategory, Text: persian, Key: keys[0]}, aliases...)
+	}
+	return index.build()
+}()
+
+// suggestPrefixes returns the normalized query, plus its Persian reading when it looks like it was typed on an English layout.
+// The layout is read from the raw input, since normalizing lowercases shifted keys like C (ژ).
+func suggestPrefixes(query string) []string {
+	persian, changed := utils.PersianFromLatinKeyboard(query)
+	query = utils.NormalizePersian(query)
+	if query == "" {
+		return nil
+	}
+
+	prefixes := []string{query}
+	if changed {
+		prefixes = append(prefixes, utils.NormalizePersian(persian))
+	}
+	return prefixes
+}
+
+func (c CafeHandler) Suggest(ctx context.Context, userID int32, query string, lat *float64, lng *float64, limit int) ([]models.Suggestion, error) {
+	if limit <= 0 {
+		limit = suggestLimit
+	} else if limit > suggestMaxLimit {
+		limit = suggestMaxLimit
+	}
+
+	prefixes := suggestPrefixes(query)
+	groups := map[models.SuggestionType][]models.Suggestion{}
+
+	if userID != 0 {
+		recent, err := c.Suggestions.GetRecentSearches(ctx, userID, prefixes, suggestQuotas[0].Quota)
+		if err != nil {
+			return nil, err
+		}
+		for _, search := range recent {
+			groups[models.SuggestionRecent] = append(groups[models.SuggestionRecent], models.Suggestion{Type: models.SuggestionRecent, Text: search.Query})
+		}
+	}
+
+	if len(prefixes) > 0 {
+		cafes, err := c.Suggestions.Cafes(ctx, prefixes, lat, lng, limit)
+		if err != nil {
+			return nil, err
+		}
+		groups[models.SuggestionCafe] = cafes
+
+		menuItems, err := c.Suggestions.MenuItems(ctx, prefixes, limit)
+		if err != nil {
+			return nil, err
+		}
+		groups[models.SuggestionMenuItem] = menuItems
+
+		provinceCounts, cityCounts, err := c.Suggestions.AreaCafeCounts(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, suggestion := range areaIndex.lookup(prefixes) {
+			switch suggestion.Type {
+			case models.SuggestionProvince:
+				suggestion.Count = provinceCounts[int(suggestion.ID)]
+			case models.SuggestionCity:
+				suggestion.Count = cityCounts[int(suggestion.ID)]
+			}
+			groups[suggestion.Type] = append(groups[suggestion.Type], suggestion)
+		}
+		// areas with more cafes first, then the shorter and so closer match
+		for _, t := range []models.SuggestionType{models.SuggestionCity, models.SuggestionProvince, models.SuggestionCategory} {
+			group := groups[t]
+			sort.SliceStable(group, func(i, j int) bool {
+				if group[i].Count != group[j].Count {
+					return group[i].Count > group[j].Count
+				}
+				return len(group[i].Text) < len(group[j].Text)
+			})
+		}
+	}
+
+	suggestions := []models.Suggestion{}
+	for _, q := range suggestQuotas {
+		group := groups[q.Type]
+		if len(group) > q.Quota {
+			group = group[:q.Quota]
+		}
+		for _, suggestion := range group {
+			if len(suggestions) == limit {
+				return suggestions, nil
+			}
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, nil
+}
+
+// rememberSearch is best effort; failing to record history must not fail the search itself.
+func (c CafeHandler) rememberSearch(ctx context.Context, userID int32, query string) {
+	normalized := utils.NormalizePersian(query)
+	if userID == 0 || normalized == "" {
+		return
+	}
+
+	err := c.Suggestions.AddRecentSearch(ctx, userID, strings.TrimSpace(query), normalized)
+	if err != nil {
+		log.GetLog().Errorf("Unable to remember search. user: %d, error: %v", userID, err)
+	}
+}
+
+func (c CafeHandler) RecentSearches(ctx context.Context, userID int32) ([]models.RecentSearch, error) {
+	return c.Suggestions.GetRecentSearches(ctx, userID, nil, suggestMaxLimit)
+}
+
+func (c CafeHandler) ClearRecentSearches(ctx context.Context, userID int32) error {
+	return c.Suggestions.ClearRecentSearches(ctx, userID)
+}
@@ -0,0 +1,25 @@
+package modules
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSuggestPrefixes(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"persian as typed", "کافه", []string{"کافه"}},
+		{"english layout", ";hti", []string{";hti", "کافه"}},
+		{"shifted key read before lowercasing", "Cvf", []string{"cvf", "ژرب"}},
+		{"blank", "  ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, suggestPrefixes(tt.query))
+		})
+	}
+}
@@ -18,11 +18,14 @@ import (
 )
 
 type UserHandler struct {
-	UserRepo        repo.UsersRepo
-	TokenRepo       repo.TokensRepo
-	ReservationRepo repo.ReservationRepo
-	CafeRepo        repo.CafesRepo
-	Postgres        *pgxpool.Pool
+	UserRepo         repo.UsersRepo
+	TokenRepo        repo.TokensRepo
+	ReservationRepo  repo.ReservationRepo
+	CafeRepo         repo.CafesRepo
+	LoyaltyRepo      repo.LoyaltyRepo
+	NotificationRepo repo.NotificationsRepo
+	Referrals        ReferralHandler
+	Postgres         *pgxpool.Pool
 }
 
 const (
@@ -52,6 +55,15 @@ func (u UserHandler) SignUp(ctx context.Context, user *models.User) error {
 		return errors.ErrLastNameInvalid.Error()
 	}
 
+	var referrerID int32
+	if user.ReferralCode != "" {
+		var err error
+		referrerID, err = u.Referrals.ReferrerID(ctx, user.ReferralCode)
+		if err != nil {
+			return err
+		}
+	}
+
 	hashedPassword, err := utils.HashPassword(user.Password)
 	if err != nil {
 		log.GetLog().Errorf("Unable to hash password. error: %v", err)
@@ -68,6 +80,16 @@ func (u UserHandler) SignUp(ctx context.Context, user *models.User) error {
 		return errors.ErrEmailExists.Error()
 	}
 
+	if referrerID != 0 {
+		u.Referrals.Refer(ctx, referrerID, user.ID)
+	}
+
+	// best effort: a user left without a code can ask for one later
+	_, err = u.Referrals.CreateCode(ctx, user.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to create referral code. user: %d, error: %v", user.ID, err)
+	}
+
 	encryptedEmail, err := utils.Encrypt(user.Email)
 	if err == nil {
 		emailBody := fmt.Sprintf(`Hello %s,<br><br>
@@ -143,6 +165,8 @@ func (u UserHandler) VerifyEmail(ctx context.Context, email string) error {
 		return err
 	}
 
+	u.Referrals.EmailVerified(ctx, decryptedEmail)
+
 	return nil
 }
 
@@ -197,6 +221,14 @@ func (u UserHandler) ForgetPassword(ctx context.Context, user *models.User) erro
 	return nil
 }
 
+func (u UserHandler) CreateReferralCode(ctx context.Context, userID int32) (string, error) {
+	return u.Referrals.CreateCode(ctx, userID)
+}
+
+func (u UserHandler) ReferralStats(ctx context.Context, userID int32) (*models.ReferralStats, error) {
+	return u.Referrals.Stats(ctx, userID)
+}
+
 func (u UserHandler) UserProfile(ctx context.Context, userID string) (*models.User, error) {
 	user_id, err := strconv.Atoi(userID)
 	if err != nil {
@@ -340,14 +372,20 @@ func (u UserHandler) ManagerAgreement(ctx context.Context, userID int32, nationa
 }
 
 type UserReservation struct {
-	CafeID           int32     `json:"cafe_id"`
-	CafeName         string    `json:"cafe_name"`
-	StartTime        time.Time `json:"start_time"`
-	EndTime          time.Time `json:"end_time"`
-	People           int32     `json:"people"`
-	ReservationPrice float64   `json:"reservation_price"`
+	ID               int32                    `json:"id"`
+	CafeID           int32                    `json:"cafe_id"`
+	CafeName         string                   `json:"cafe_name"`
+	StartTime        time.Time                `json:"start_time"`
+	EndTime          time.Time                `json:"end_time"`
+	People           int32                    `json:"people"`
+	ReservationPrice float64                  `json:"reservation_price"`
+	Status           models.ReservationStatus `json:"status"`
+	ExpiresAt        *time.Time               `json:"expires_at,omitempty"`
+	Invited          bool                     `json:"invited"`
 }
 
+// UserReservations lists the user's reservations on the given day, including group reservations they were
+// invited to.
 func (u UserHandler) UserReservations(ctx context.Context, userID int32, day time.Time) ([]UserReservation, error) {
 	reservations, err := u.ReservationRepo.GetByDateUserID(ctx, userID, day, day.Add(time.Hour*24))
 	if err != nil {
@@ -365,12 +403,16 @@ func (u UserHandler) UserReservations(ctx context.Context, userID int32, day tim
 		}
 
 		userReservations = append(userReservations, UserReservation{
+			ID:               reservation.ID,
 			CafeID:           reservation.CafeID,
 			CafeName:         cafe.Name,
 			StartTime:        reservation.StartTime,
 			EndTime:          reservation.EndTime,
 			People:           reservation.People,
 			ReservationPrice: cafe.ReservationPrice,
+			Status:           reservation.Status,
+			ExpiresAt:        reservation.ExpiresAt,
+			Invited:          reservation.UserID != userID,
 		})
 	}
 
@@ -98,8 +98,13 @@ func Run() {
 	tokenRepo := repo.NewTokenRepoImp(postgres)
 	cafeRepo := repo.NewCafeRepoImp(postgres)
 	paymentRepo := repo.NewTransactionImp(postgres)
+	giftCardRepo := repo.NewGiftCardsRepoImp(postgres)
+	transferRepo := repo.NewTransferRequestsRepoImp(postgres)
 	reservationRepo := repo.NewReservationRepoImp(postgres)
-	UserHandler := modules.UserHandler{UserRepo: userRepo, TokenRepo: tokenRepo, ReservationRepo: reservationRepo, CafeRepo: cafeRepo, Postgres: postgres}
+	loyaltyRepo := repo.NewLoyaltyRepoImp(postgres)
+	referralHandler := modules.ReferralHandler{ReferralRepo: repo.NewReferralsRepoImp(postgres)}
+	notificationRepo := repo.NewNotificationsRepoImp(postgres)
+	UserHandler := modules.UserHandler{UserRepo: userRepo, TokenRepo: tokenRepo, ReservationRepo: reservationRepo, CafeRepo: cafeRepo, LoyaltyRepo: loyaltyRepo, NotificationRepo: notificationRepo, Referrals: referralHandler, Postgres: postgres}
 	userHttpHandler := http.User{Handler: &UserHandler}
 
 	user := apiV1.Group("/user")
@@ -114,79 +119,126 @@ func Run() {
 	user.Handle(string(models.PATCH), "edit-profile", authMiddleware.IsAuthorized, userHttpHandler.EditProfile)
 	user.Handle(string(models.POST), "manager-agreement", authMiddleware.IsAuthorized, userHttpHandler.ManagerAgreement)
 	user.Handle(string(models.GET), "user-reservations", authMiddleware.IsAuthorized, userHttpHandler.UserReservations)
+	user.Handle(string(models.GET), "loyalty-history", authMiddleware.IsAuthorized, userHttpHandler.LoyaltyHistory)
+	user.Handle(string(models.GET), "notifications", authMiddleware.IsAuthorized, userHttpHandler.Notifications)
+	user.Handle(string(models.POST), "read-notifications", authMiddleware.IsAuthorized, userHttpHandler.ReadNotifications)
+	user.Handle(string(models.POST), "referral-code", authMiddleware.IsAuthorized, userHttpHandler.CreateReferralCode)
 
 	imageRepo := repo.NewImageRepoImp(postgres)
-	ratingRepo := repo.NewRatingsRepoImp(postgres)
-	commentRepo := repo.NewCommentsRepoImp(postgres)
+	reviewsRepo := repo.NewReviewsRepoImp(postgres)
 	eventRepo := repo.NewEventRepoImp(postgres)
 	menuItemRepo := repo.NewMenuItemRepoImp(postgres)
+	if err := cafeRepo.RebuildSearchDocuments(context.Background()); err != nil {
+		log.GetLog().Errorf("Unable to rebuild search documents. error: %v", err)
+	}
 	locationRepo := repo.NewLocationsRepoImp(postgres)
+	suggestionsRepo := repo.NewSuggestionsRepoImp(postgres)
 	favoriteRepo := repo.NewFavoritesRepoImp(postgres)
 
 	cafeHandler := modules.CafeHandler{
-		CafeRepo:        cafeRepo,
-		Rating:          ratingRepo,
-		CommentRepo:     commentRepo,
-		ImageRepo:       imageRepo,
-		EventRepo:       eventRepo,
-		UserRepo:        userRepo,
-		ReservationRepo: reservationRepo,
-		MenuItemRepo:    menuItemRepo,
-		PaymentRepo:     paymentRepo,
-		FavoriteRepo:    favoriteRepo,
-		LocationsRepo:   locationRepo,
-		Redis:           rdb,
+		CafeRepo:           cafeRepo,
+		Reviews:            reviewsRepo,
+		ReviewPhotos:       repo.NewReviewPhotosRepoImp(postgres),
+		NotificationRepo:   notificationRepo,
+		ImageRepo:          imageRepo,
+		ImageFiles:         repo.NewImageFilesRepoImp(mongoDb, *mongoDbOpt.Name),
+		EventRepo:          eventRepo,
+		UserRepo:           userRepo,
+		ReservationRepo:    reservationRepo,
+		MenuItemRepo:       menuItemRepo,
+		PaymentRepo:        paymentRepo,
+		FavoriteRepo:       favoriteRepo,
+		LoyaltyRepo:        loyaltyRepo,
+		Referrals:          referralHandler,
+		LocationsRepo:      locationRepo,
+		GeoIndex:           repo.NewGeoIndexRepoImp(rdb),
+		Suggestions:        suggestionsRepo,
+		FeedCache:          repo.NewFeedCacheRepoImp(rdb),
+		RecommendationRepo: repo.NewRecommendationsRepoImp(postgres),
+		Popularity:         repo.NewPopularityRepoImp(postgres),
+		Redis:              rdb,
 	}
-	cafeHttpHandler := http.Cafe{Handler: &cafeHandler, Rating: ratingRepo, ImageRepo: imageRepo, FirstSearch: atomic2.NewBool(true)}
+	cafeHttpHandler := http.Cafe{Handler: &cafeHandler, ImageRepo: imageRepo, FirstSearch: atomic2.NewBool(true)}
 
-	newTicker := time.NewTicker(1 * time.Minute)
-	go func() {
-		for {
-			select {
-			case <-newTicker.C:
-				if err := rdb.FlushDB(context.Background()).Err(); err != nil {
-					log.GetLog().Errorf("Unable to flush redis db. error: %v", err)
-				}
-				locations, err := locationRepo.FindAll(context.Background())
-				if err != nil {
-					log.GetLog().Errorf("Unable to get locations. error: %v", err)
-				}
-
-				for _, location := range locations {
-					if err := rdb.GeoAdd(context.Background(), "locations", &redis.GeoLocation{
-						Name:      cast.ToString(location.CafeID),
-						Longitude: location.Lng,
-						Latitude:  location.Lat,
-					}).Err(); err != nil {
-						log.GetLog().Errorf("Unable to add location to redis. error: %v", err)
-					}
-				}
-			}
+	runEvery("release-expired-reservations", time.Minute, time.Minute, func(ctx context.Context) {
+		if _, err := cafeHandler.ReleaseExpiredReservations(ctx); err != nil {
+			log.GetLog().Errorf("Unable to release expired reservations. error: %v", err)
+		}
+	})
+
+	if err := cafeHandler.RebuildGeoIndex(context.Background()); err != nil {
+		log.GetLog().Errorf("Unable to rebuild geo index. error: %v", err)
+	}
+	runEvery("rebuild-geo-index", time.Hour, time.Minute, func(ctx context.Context) {
+		if err := cafeHandler.RebuildGeoIndex(ctx); err != nil {
+			log.GetLog().Errorf("Unable to rebuild geo index. error: %v", err)
 		}
+	})
+
+	recomputePopularity := func(ctx context.Context) {
+		if err := cafeHandler.RecomputePopularity(ctx); err != nil {
+			log.GetLog().Errorf("Unable to recompute popularity. error: %v", err)
+		}
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		recomputePopularity(ctx)
 	}()
+	runEvery("recompute-popularity", 30*time.Minute, 5*time.Minute, recomputePopularity)
+
+	recomputeRecommendations := func(ctx context.Context) {
+		if _, err := cafeHandler.RecomputeRecommendations(ctx); err != nil {
+			log.GetLog().Errorf("Unable to recompute recommendations. error: %v", err)
+		}
+	}
+	if cafeHandler.RecommendationsStale(context.Background()) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			defer cancel()
+			recomputeRecommendations(ctx)
+		}()
+	}
+	runDaily("recompute-recommendations", 4, 10*time.Minute, recomputeRecommendations)
 
 	cafe := apiV1.Group("/cafe")
 	cafe.Handle(string(models.POST), "create", authMiddleware.IsAuthorized, cafeHttpHandler.Create)
-	cafe.Handle(string(models.POST), "search-cafe", cafeHttpHandler.SearchCafe)
+	cafe.Handle(string(models.POST), "search-cafe", authMiddleware.OptionalAuth, cafeHttpHandler.SearchCafe)
 	cafe.Handle(string(models.GET), "public-cafe", authMiddleware.OptionalAuth, cafeHttpHandler.PublicCafeProfile)
 	cafe.Handle(string(models.POST), "add-comment", authMiddleware.IsAuthorized, cafeHttpHandler.AddComment)
 	//cafe.Handle(string(models.GET), "get-comments", cafeHttpHandler.GetComments)
 	cafe.Handle(string(models.POST), "create-event", authMiddleware.IsAuthorized, cafeHttpHandler.CreateEvent)
 	cafe.Handle(string(models.POST), "add-menu-item", authMiddleware.IsAuthorized, cafeHttpHandler.AddMenuItem)
 	cafe.Handle(string(models.GET), "home", cafeHttpHandler.Home)
+	cafe.Handle(string(models.GET), "home-feed", authMiddleware.OptionalAuth, cafeHttpHandler.HomeFeed)
+	cafe.Handle(string(models.GET), "recommendations", authMiddleware.IsAuthorized, cafeHttpHandler.Recommendations)
+	cafe.Handle(string(models.GET), "trending", cafeHttpHandler.Trending)
 	cafe.Handle(string(models.GET), "private-menu", authMiddleware.IsAuthorized, cafeHttpHandler.PrivateMenu)
 	cafe.Handle(string(models.GET), "public-menu", cafeHttpHandler.PublicMenu)
 	cafe.Handle(string(models.PATCH), "edit-menu-item", authMiddleware.IsAuthorized, cafeHttpHandler.EditMenuItem)
 	cafe.Handle(string(models.DELETE), "delete-menu-item", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteMenuItem)
+	cafe.Handle(string(models.POST), "menu-item-availability", authMiddleware.IsAuthorized, cafeHttpHandler.SetMenuAvailability)
+	cafe.Handle(string(models.POST), "menu-item-sold", authMiddleware.IsAuthorized, cafeHttpHandler.MenuItemSold)
+	cafe.Handle(string(models.POST), "menu-category-schedule", authMiddleware.IsAuthorized, cafeHttpHandler.SetMenuCategorySchedule)
+	cafe.Handle(string(models.POST), "import-menu", authMiddleware.IsAuthorized, cafeHttpHandler.ImportMenu)
+	cafe.Handle(string(models.GET), "export-menu", authMiddleware.IsAuthorized, cafeHttpHandler.ExportMenu)
 	cafe.Handle(string(models.POST), "reserve-event", authMiddleware.IsAuthorized, cafeHttpHandler.ReserveEvent)
 	cafe.Handle(string(models.GET), "private-cafe", authMiddleware.IsAuthorized, cafeHttpHandler.PrivateCafe)
 	cafe.Handle(string(models.PATCH), "edit-cafe", authMiddleware.IsAuthorized, cafeHttpHandler.EditCafe)
+	cafe.Handle(string(models.PATCH), "hide-cafe", authMiddleware.IsAuthorized, cafeHttpHandler.HideCafe)
+	cafe.Handle(string(models.DELETE), "delete-cafe", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteCafe)
 	cafe.Handle(string(models.PATCH), "edit-event", authMiddleware.IsAuthorized, cafeHttpHandler.EditEvent)
 	cafe.Handle(string(models.DELETE), "delete-event", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteEvent)
 	cafe.Handle(string(models.GET), "fully-booked-days", cafeHttpHandler.GetFullyBookedDays)
 	cafe.Handle(string(models.GET), "time-slots", cafeHttpHandler.GetTimeSlots)
 	cafe.Handle(string(models.POST), "reserve-cafe", authMiddleware.IsAuthorized, cafeHttpHandler.ReserveCafe)
 	cafe.Handle(string(models.GET), "cafe-reservations", authMiddleware.IsAuthorized, cafeHttpHandler.GetCafeReservations)
+	cafe.Handle(string(models.POST), "reserve-cafe-group", authMiddleware.IsAuthorized, cafeHttpHandler.ReserveCafeGroup)
+	cafe.Handle(string(models.POST), "pay-reservation-share", authMiddleware.IsAuthorized, cafeHttpHandler.PayReservationShare)
+	cafe.Handle(string(models.GET), "pending-reservation-shares", authMiddleware.IsAuthorized, cafeHttpHandler.PendingReservationShares)
+	cafe.Handle(string(models.GET), "reservation-shares", authMiddleware.IsAuthorized, cafeHttpHandler.ReservationShares)
+	cafe.Handle(string(models.GET), "loyalty-rule", cafeHttpHandler.GetLoyaltyRule)
+	cafe.Handle(string(models.POST), "set-loyalty-rule", authMiddleware.IsAuthorized, cafeHttpHandler.SetLoyaltyRule)
 	cafe.Handle(string(models.POST), "add-to-favorite", authMiddleware.IsAuthorized, cafeHttpHandler.AddToFavorite)
 	cafe.Handle(string(models.DELETE), "remove-favorite", authMiddleware.IsAuthorized, cafeHttpHandler.RemoveFavorite)
 	cafe.Handle(string(models.GET), "get-favorite-list", authMiddleware.IsAuthorized, cafeHttpHandler.GetFavoriteList)
@@ -195,10 +247,28 @@ func Run() {
 	cafe.Handle(string(models.POST), "add-rating", authMiddleware.IsAuthorized, cafeHttpHandler.AddRating)
 	cafe.Handle(string(models.GET), "get-cafe-rating", authMiddleware.IsAuthorized, cafeHttpHandler.GetRating)
 
+	//reviews
+	cafe.Handle(string(models.POST), "write-review", authMiddleware.IsAuthorized, cafeHttpHandler.WriteReview)
+	cafe.Handle(string(models.GET), "reviews", cafeHttpHandler.GetReviews)
+	cafe.Handle(string(models.DELETE), "delete-review", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteReview)
+	cafe.Handle(string(models.GET), "review-history", authMiddleware.IsAuthorized, cafeHttpHandler.ReviewHistory)
+	cafe.Handle(string(models.POST), "reply-review", authMiddleware.IsAuthorized, cafeHttpHandler.ReplyToReview)
+	cafe.Handle(string(models.DELETE), "delete-reply", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteReply)
+	cafe.Handle(string(models.POST), "report-review", authMiddleware.IsAuthorized, cafeHttpHandler.ReportReview)
+	cafe.Handle(string(models.POST), "vote-review", authMiddleware.IsAuthorized, cafeHttpHandler.VoteReview)
+	cafe.Handle(string(models.POST), "add-review-photo", authMiddleware.IsAuthorized, cafeHttpHandler.AddReviewPhoto)
+	cafe.Handle(string(models.DELETE), "delete-review-photo", authMiddleware.IsAuthorized, cafeHttpHandler.DeleteReviewPhoto)
+
 	// location
 	cafe.Handle(string(models.POST), "get-nearest-cafes", cafeHttpHandler.GetNearestCafes)
+	cafe.Handle(string(models.POST), "geo-search", cafeHttpHandler.GeoSearchCafes)
 	cafe.Handle(string(models.POST), "get-cafe-location", cafeHttpHandler.GetCafeLocation)
-	cafe.Handle(string(models.POST), "set-location", cafeHttpHandler.SetCafeLocation)
+	cafe.Handle(string(models.POST), "set-location", authMiddleware.IsAuthorized, cafeHttpHandler.SetCafeLocation)
+
+	search := apiV1.Group("/search")
+	search.Handle(string(models.GET), "suggest", authMiddleware.OptionalAuth, cafeHttpHandler.Suggest)
+	search.Handle(string(models.GET), "recent-searches", authMiddleware.IsAuthorized, cafeHttpHandler.RecentSearches)
+	search.Handle(string(models.DELETE), "clear-recent-searches", authMiddleware.IsAuthorized, cafeHttpHandler.ClearRecentSearches)
 
 	imageHandler := http.ImageHandler{MongoDb: mongoDb, MongoOpt: mongoDbOpt, ImageRepo: imageRepo}
 	image := apiV1.Group("/image")
@@ -206,20 +276,60 @@ func Run() {
 	image.Handle(string(models.GET), "download", imageHandler.DownloadImage)
 	image.Handle(string(models.POST), "submit", imageHandler.SubmitImage)
 
-	paymentHandler := modules.PaymentHandler{PaymentRepo: paymentRepo, UserRepo: userRepo}
+	paymentHandler := modules.PaymentHandler{PaymentRepo: paymentRepo, UserRepo: userRepo, GiftCardRepo: giftCardRepo, TransferRepo: transferRepo, CafeRepo: cafeRepo}
 	paymentHttpHandler := http.Payment{Handler: &paymentHandler}
 	payment := apiV1.Group("/payment")
 	payment.Handle(string(models.POST), "transfer", authMiddleware.IsAuthorized, paymentHttpHandler.Transfer)
 	payment.Handle(string(models.GET), "transactions-list", authMiddleware.IsAuthorized, paymentHttpHandler.TransactionsList)
+	payment.Handle(string(models.GET), "account-transactions", authMiddleware.IsAuthorized, paymentHttpHandler.AccountTransactions)
 	payment.Handle(string(models.POST), "deposit", authMiddleware.IsAuthorized, paymentHttpHandler.Deposit)
 	payment.Handle(string(models.POST), "withdraw", authMiddleware.IsAuthorized, paymentHttpHandler.Withdraw)
 	payment.Handle(string(models.GET), "balance", authMiddleware.IsAuthorized, paymentHttpHandler.Balance)
+	payment.Handle(string(models.POST), "request-transfer", authMiddleware.IsAuthorized, paymentHttpHandler.RequestTransfer)
+	payment.Handle(string(models.POST), "confirm-transfer", authMiddleware.IsAuthorized, paymentHttpHandler.ConfirmTransfer)
+	payment.Handle(string(models.POST), "cancel-transfer", authMiddleware.IsAuthorized, paymentHttpHandler.CancelTransfer)
+	payment.Handle(string(models.GET), "transfer-requests", authMiddleware.IsAuthorized, paymentHttpHandler.TransferRequests)
+
+	// gift cards
+	payment.Handle(string(models.POST), "buy-gift-card", authMiddleware.IsAuthorized, paymentHttpHandler.BuyGiftCard)
+	payment.Handle(string(models.GET), "gift-cards", authMiddleware.IsAuthorized, paymentHttpHandler.GiftCards)
+	payment.Handle(string(models.GET), "gift-card-balance", authMiddleware.IsAuthorized, paymentHttpHandler.GiftCardBalance)
+	payment.Handle(string(models.POST), "redeem-gift-card", authMiddleware.IsAuthorized, paymentHttpHandler.RedeemGiftCard)
 
-	publicHandler := http.PublicHandler{}
+	publicHandler := http.PublicHandler{Gazetteer: &modules.GazetteerHandler{Suggestions: suggestionsRepo}}
 	public := apiV1.Group("/public")
-	public.StaticFile("/province", "./assets/ostan.json")
+	public.Handle(string(models.GET), "/province", publicHandler.GetProvinces)
 	public.Handle(string(models.GET), "health", publicHandler.HealthCheck)
 	public.Handle(string(models.GET), "/cities", publicHandler.GetCities)
+	// reverse geocoding needs the boundary outlines in assets/boundaries.geojson
+	if utils.AreaLookupAvailable() {
+		public.Handle(string(models.GET), "/locate", publicHandler.Locate)
+	}
+	public.Handle(string(models.GET), "/gazetteer/provinces", publicHandler.GazetteerProvinces)
+	public.Handle(string(models.GET), "/gazetteer/cities", publicHandler.GazetteerCities)
+	public.Handle(string(models.GET), "/gazetteer/city", publicHandler.GazetteerCity)
+	public.Handle(string(models.GET), "/gazetteer/search", publicHandler.GazetteerSearch)
+	public.Handle(string(models.GET), "/gazetteer/neighbours", publicHandler.GazetteerNeighbours)
+
+	ledgerRepo := repo.NewLedgerRepoImp(postgres)
+	ledgerHandler := modules.LedgerHandler{LedgerRepo: ledgerRepo}
+	runDaily("ledger-reconciliation", 3, time.Hour, func(ctx context.Context) {
+		if _, err := ledgerHandler.Reconcile(ctx); err != nil {
+			log.GetLog().Errorf("Unable to reconcile ledger. error: %v", err)
+		}
+	})
+
+	adminHttpHandler := http.Admin{Ledger: &ledgerHandler, Cafe: &cafeHandler}
+	admin := apiV1.Group("/admin")
+	admin.Handle(string(models.POST), "run-reconciliation", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.RunReconciliation)
+	admin.Handle(string(models.GET), "reconciliation-runs", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ReconciliationRuns)
+	admin.Handle(string(models.GET), "reconciliation-report", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ReconciliationReport)
+	admin.Handle(string(models.POST), "run-recommendations", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.RunRecommendations)
+	admin.Handle(string(models.GET), "recommendation-run", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.RecommendationRun)
+	admin.Handle(string(models.GET), "review-queue", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ReviewQueue)
+	admin.Handle(string(models.POST), "moderate-review", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ModerateReview)
+	admin.Handle(string(models.GET), "review-photo-queue", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ReviewPhotoQueue)
+	admin.Handle(string(models.POST), "moderate-review-photo", authMiddleware.IsAuthorized, authMiddleware.IsAdmin, adminHttpHandler.ModerateReviewPhoto)
 
 	service.Run(":8080")
 }
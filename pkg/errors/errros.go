@@ -3,27 +3,61 @@ package errors
 import "errors"
 
 var (
-	ErrorUserNotFound    = StringError{Msg: "کاربر یافت نشد"}
-	ErrEmailInvalid      = StringError{Msg: "ایمیل نامعتبر است"}
-	ErrPhoneInvalid      = StringError{Msg: "شماره تلفن نامعتبر است"}
-	ErrFirstNameInvalid  = StringError{Msg: "نام نامعتبر است"}
-	ErrLastNameInvalid   = StringError{Msg: "نام خانوادگی نامعتبر است"}
-	ErrPasswordIncorrect = StringError{Msg: "رمز عبور اشتباه است"}
-	ErrPasswordNotMatch  = StringError{Msg: "رمز عبور ها مطابقت ندارند"}
-	ErrEmailExists       = StringError{Msg: "این ایمیل وجود دارد"}
-	ErrStartTimeInvalid  = StringError{Msg: "زمان شروع نامعتبر است"}
-	ErrEndTimeInvalid    = StringError{Msg: "زمان پایان نامعتبر است"}
-	ErrImageInvalid      = StringError{Msg: "تصویر نامعتبر است"}
-	ErrForbidden         = StringError{Msg: "دسترسی غیر مجاز"}
-	ErrUnableToGetUser   = StringError{Msg: "خطایی در گرفتن اطلاعات کاربر رخ داده است"}
-	ErrBadRequest        = StringError{Msg: "درخواست نامعتبر است"}
-	ErrDidntLogin        = StringError{Msg: "شما وارد نشده اید"}
-	ErrInternalError     = StringError{Msg: "خطای داخلی"}
-	ErrNotEnoughBalance  = StringError{Msg: "موجودی کافی نیست"}
-	ErrCapacityInvalid   = StringError{Msg: "ظرفیت وارد شده نامعتبر است"}
-	ErrPriceInvalid      = StringError{Msg: "مبلغ وارد شده نامعتبر است"}
-	ErrEventReserved     = StringError{Msg: "شما قبلا این رویداد را رزرو کرده اید"}
-	ErrEventUnreservable = StringError{Msg: "رویداد قابل رزرو نیست"}
+	ErrorUserNotFound      = StringError{Msg: "کاربر یافت نشد"}
+	ErrEmailInvalid        = StringError{Msg: "ایمیل نامعتبر است"}
+	ErrPhoneInvalid        = StringError{Msg: "شماره تلفن نامعتبر است"}
+	ErrFirstNameInvalid    = StringError{Msg: "نام نامعتبر است"}
+	ErrLastNameInvalid     = StringError{Msg: "نام خانوادگی نامعتبر است"}
+	ErrPasswordIncorrect   = StringError{Msg: "رمز عبور اشتباه است"}
+	ErrPasswordNotMatch    = StringError{Msg: "رمز عبور ها مطابقت ندارند"}
+	ErrEmailExists         = StringError{Msg: "این ایمیل وجود دارد"}
+	ErrStartTimeInvalid    = StringError{Msg: "زمان شروع نامعتبر است"}
+	ErrEndTimeInvalid      = StringError{Msg: "زمان پایان نامعتبر است"}
+	ErrImageInvalid        = StringError{Msg: "تصویر نامعتبر است"}
+	ErrForbidden           = StringError{Msg: "دسترسی غیر مجاز"}
+	ErrUnableToGetUser     = StringError{Msg: "خطایی در گرفتن اطلاعات کاربر رخ داده است"}
+	ErrBadRequest          = StringError{Msg: "درخواست نامعتبر است"}
+	ErrDidntLogin          = StringError{Msg: "شما وارد نشده اید"}
+	ErrInternalError       = StringError{Msg: "خطای داخلی"}
+	ErrNotEnoughBalance    = StringError{Msg: "موجودی کافی نیست"}
+	ErrCapacityInvalid     = StringError{Msg: "ظرفیت وارد شده نامعتبر است"}
+	ErrPriceInvalid        = StringError{Msg: "مبلغ وارد شده نامعتبر است"}
+	ErrEventReserved       = StringError{Msg: "شما قبلا این رویداد را رزرو کرده اید"}
+	ErrEventUnreservable   = StringError{Msg: "رویداد قابل رزرو نیست"}
+	ErrGiftCardNotFound    = StringError{Msg: "کارت هدیه یافت نشد"}
+	ErrGiftCardEmpty       = StringError{Msg: "موجودی کارت هدیه تمام شده است"}
+	ErrGiftCardScope       = StringError{Msg: "این کارت هدیه برای این کافه قابل استفاده نیست"}
+	ErrRecipientNotFound   = StringError{Msg: "گیرنده یافت نشد"}
+	ErrSelfTransfer        = StringError{Msg: "امکان انتقال به حساب خود وجود ندارد"}
+	ErrTransferInvalid     = StringError{Msg: "درخواست انتقال معتبر نیست یا منقضی شده است"}
+	ErrReservationExpired  = StringError{Msg: "مهلت پرداخت این رزرو به پایان رسیده است"}
+	ErrShareInvalid        = StringError{Msg: "سهمی برای پرداخت یافت نشد"}
+	ErrInviteesInvalid     = StringError{Msg: "فهرست دعوت شدگان نامعتبر است"}
+	ErrFullyBooked         = StringError{Msg: "ظرفیت این بازه زمانی تکمیل است"}
+	ErrNotEnoughPoints     = StringError{Msg: "امتیاز کافی نیست"}
+	ErrNoStampReward       = StringError{Msg: "جایزه کارت مهر در دسترس نیست"}
+	ErrLoyaltyRuleInvalid  = StringError{Msg: "قوانین باشگاه مشتریان نامعتبر است"}
+	ErrReferralCodeInvalid = StringError{Msg: "کد معرف نامعتبر است"}
+	ErrSearchFilterInvalid = StringError{Msg: "فیلتر جستجو نامعتبر است"}
+	ErrSearchCursorInvalid = StringError{Msg: "صفحه درخواستی نامعتبر است"}
+	ErrCafeNotFound        = StringError{Msg: "کافه یافت نشد"}
+	ErrCafeHasHistory      = StringError{Msg: "این کافه سابقه فعالیت دارد، به جای حذف آن را پنهان کنید"}
+	ErrLocationMismatch    = StringError{Msg: "موقعیت انتخاب شده با استان و شهر کافه مطابقت ندارد"}
+	ErrLocationUnknown     = StringError{Msg: "شهر شما از روی موقعیت مشخص نشد"}
+	ErrAreaLookupOff       = StringError{Msg: "جستجو بر اساس شهر محل شما فعلا در دسترس نیست"}
+	ErrReviewInvalid       = StringError{Msg: "نظر نامعتبر است"}
+	ErrReviewNotFound      = StringError{Msg: "نظر یافت نشد"}
+	ErrReviewPhotoLimit    = StringError{Msg: "حداکثر تعداد عکس برای این نظر ثبت شده است"}
+	ErrReviewPhotoNotFound = StringError{Msg: "عکس یافت نشد"}
+	ErrReviewPhotoRejected = StringError{Msg: "عکس رد شده قابل حذف نیست"}
+	ErrImageNotFound       = StringError{Msg: "تصویر بارگذاری نشده است"}
+	ErrImageInUse          = StringError{Msg: "این تصویر قبلا استفاده شده است"}
+	ErrReviewRateLimited   = StringError{Msg: "تعداد نظرات شما بیش از حد مجاز است، کمی بعد دوباره تلاش کنید"}
+	ErrReviewSelfVote      = StringError{Msg: "نمی‌توانید به نظر خودتان رأی دهید"}
+	ErrMenuOptionsInvalid  = StringError{Msg: "گزینه‌های آیتم منو نامعتبر است"}
+	ErrMenuDietInfoInvalid = StringError{Msg: "اطلاعات تغذیه‌ای آیتم منو نامعتبر است"}
+	ErrMenuScheduleInvalid = StringError{Msg: "زمان‌بندی منو نامعتبر است"}
+	ErrMenuItemSoldOut     = StringError{Msg: "موجودی این آیتم تمام شده است"}
 )
 
 type StringError struct {
@@ -33,3 +67,8 @@ type StringError struct {
 func (e *StringError) Error() error {
 	return errors.New(e.Msg)
 }
+
+// Is reports whether err was made by this StringError's Error.
+func (e *StringError) Is(err error) bool {
+	return err != nil && err.Error() == e.Msg
+}
@@ -1,7 +1,9 @@
 package middlewares
 
 import (
+	"barista/pkg/errors"
 	"barista/pkg/log"
+	"barista/pkg/models"
 	"barista/pkg/utils"
 	"net/http"
 
@@ -62,3 +64,13 @@ func (a AuthMiddleware) OptionalAuth(c *gin.Context) {
     c.Set("claims", claims)
     c.Next()
 }
+
+func (a AuthMiddleware) IsAdmin(c *gin.Context) {
+	role, exists := c.Get("role")
+	if !exists || role.(int32) != int32(models.AdminRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": errors.ErrForbidden.Error().Error()})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
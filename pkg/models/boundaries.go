@@ -0,0 +1,125 @@
+package models
+
+import (
+	"barista/assets"
+	"barista/pkg/log"
+	"encoding/json"
+	"fmt"
+)
+
+type AreaLevel string
+
+const (
+	AreaProvince AreaLevel = "province"
+	AreaCity     AreaLevel = "city"
+)
+
+// Boundary is the outline of a province or city. Points are GeoJSON [lng, lat] pairs, and each polygon is
+// an outer ring followed by its holes.
+type Boundary struct {
+	Level    AreaLevel
+	ID       int
+	Polygons [][][][2]float64
+	MinLat   float64
+	MinLng   float64
+	MaxLat   float64
+	MaxLng   float64
+}
+
+// Boundaries is loaded from a GeoJSON FeatureCollection whose features carry "level" and "id" properties,
+// the ids being the same as in ostan.json and shahr.json.
+var Boundaries = []Boundary{}
+
+type boundaryFeature struct {
+	Properties struct {
+		Level AreaLevel `json:"level"`
+		ID    int       `json:"id"`
+	} `json:"properties"`
+	Geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+}
+
+func parseBoundary(feature boundaryFeature) (Boundary, error) {
+	boundary := Boundary{Level: feature.Properties.Level, ID: feature.Properties.ID}
+
+	switch feature.Geometry.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+			return boundary, err
+		}
+		boundary.Polygons = [][][][2]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(feature.Geometry.Coordinates, &boundary.Polygons); err != nil {
+			return boundary, err
+		}
+	default:
+		return boundary, fmt.Errorf("unsupported geometry %q for %s %d", feature.Geometry.Type, boundary.Level, boundary.ID)
+	}
+
+	boundary.MinLat, boundary.MinLng, boundary.MaxLat, boundary.MaxLng = 90, 180, -90, -180
+	for _, polygon := range boundary.Polygons {
+		if len(polygon) == 0 {
+			continue
+		}
+		for _, point := range polygon[0] {
+			boundary.MinLng, boundary.MaxLng = min(boundary.MinLng, point[0]), max(boundary.MaxLng, point[0])
+			boundary.MinLat, boundary.MaxLat = min(boundary.MinLat, point[1]), max(boundary.MaxLat, point[1])
+		}
+	}
+	return boundary, nil
+}
+
+func checkBoundaryID(boundary Boundary) error {
+	switch boundary.Level {
+	case AreaProvince:
+		if _, ok := ProvinceByID[boundary.ID]; !ok {
+			return fmt.Errorf("unknown province %d", boundary.ID)
+		}
+	case AreaCity:
+		if _, ok := CityByID[boundary.ID]; !ok {
+			return fmt.Errorf("unknown city %d", boundary.ID)
+		}
+	default:
+		return fmt.Errorf("unknown level %q for boundary %d", boundary.Level, boundary.ID)
+	}
+	return nil
+}
+
+// loadBoundaries runs after provinces and cities are loaded, so outlines whose ids are not in the gazetteer
+// can be rejected instead of resolving to areas that do not exist.
+func loadBoundaries() {
+	file, err := assets.Files.Open("boundaries.geojson")
+	if err != nil {
+		panic(err)
+	}
+
+	defer file.Close()
+
+	var collection struct {
+		Features []boundaryFeature `json:"features"`
+	}
+	err = json.NewDecoder(file).Decode(&collection)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, feature := range collection.Features {
+		boundary, err := parseBoundary(feature)
+		if err == nil {
+			err = checkBoundaryID(boundary)
+		}
+		if err != nil {
+			log.GetLog().WithError(err).Error("Skipping boundary")
+			continue
+		}
+		Boundaries = append(Boundaries, boundary)
+	}
+	if len(Boundaries) == 0 {
+		log.GetLog().Warn("No province or city boundaries loaded, locations will not be resolved to areas")
+		return
+	}
+	log.GetLog().WithField("count", len(Boundaries)).Info("Boundaries loaded successfully")
+}
@@ -8,7 +8,7 @@ type Cafe struct {
 	OpeningTime      int8              `json:"opening_time"`
 	ClosingTime      int8              `json:"closing_time"`
 	Menus            []MenuItem        `json:"menus"`
-	Comments         []Comment         `json:"comments"`
+	Reviews          []Review          `json:"reviews"`
 	Rating           float64           `json:"rating"`
 	Images           []string          `json:"photos"`
 	Events           []Event           `json:"events"`
@@ -19,6 +19,8 @@ type Cafe struct {
 	Amenities        []AmenityCategory `json:"amenities"`
 	ReservationPrice float64           `json:"reservation_price"`
 	Location         Location          `json:"location"`
+	Distance         *float64          `json:"distance,omitempty"`
+	Hidden           bool              `json:"hidden"`
 }
 
 type ContactInfo struct {
@@ -50,6 +50,64 @@ var MenuItemCategoryPersians = map[MenuItemCategory]string{
 	MenuItemCategoryDrink:     "نوشیدنی",
 }
 
+// Allergen is something in a menu item people may need to avoid.
+type Allergen string
+
+const (
+	AllergenGluten    Allergen = "gluten"
+	AllergenDairy     Allergen = "dairy"
+	AllergenEggs      Allergen = "eggs"
+	AllergenNuts      Allergen = "nuts"
+	AllergenPeanuts   Allergen = "peanuts"
+	AllergenSoy       Allergen = "soy"
+	AllergenSesame    Allergen = "sesame"
+	AllergenFish      Allergen = "fish"
+	AllergenShellfish Allergen = "shellfish"
+)
+
+var AllergenPersians = map[Allergen]string{
+	AllergenGluten:    "گلوتن",
+	AllergenDairy:     "لبنیات",
+	AllergenEggs:      "تخم مرغ",
+	AllergenNuts:      "مغزها",
+	AllergenPeanuts:   "بادام زمینی",
+	AllergenSoy:       "سویا",
+	AllergenSesame:    "کنجد",
+	AllergenFish:      "ماهی",
+	AllergenShellfish: "سخت پوستان",
+}
+
+type DietaryTag string
+
+const (
+	DietaryVegan      DietaryTag = "vegan"
+	DietaryVegetarian DietaryTag = "vegetarian"
+	DietaryHalal      DietaryTag = "halal"
+	DietarySugarFree  DietaryTag = "sugar_free"
+	DietaryGlutenFree DietaryTag = "gluten_free"
+)
+
+var DietaryTagPersians = map[DietaryTag]string{
+	DietaryVegan:      "وگان",
+	DietaryVegetarian: "گیاهی",
+	DietaryHalal:      "حلال",
+	DietarySugarFree:  "بدون قند",
+	DietaryGlutenFree: "بدون گلوتن",
+}
+
+// DietaryTagAmenities pairs tags with the amenity a cafe can list for them, so a cafe that lists the amenity
+// counts as serving such food even before its menu is tagged.
+var DietaryTagAmenities = map[DietaryTag]AmenityCategory{
+	DietaryVegan:      AmenityCategoryVeganOptions,
+	DietaryVegetarian: AmenityCategoryVegetarianOptions,
+}
+
+// DietaryConflicts lists the allergens an item with the tag cannot contain.
+var DietaryConflicts = map[DietaryTag][]Allergen{
+	DietaryVegan:      {AllergenDairy, AllergenEggs, AllergenFish, AllergenShellfish},
+	DietaryGlutenFree: {AllergenGluten},
+}
+
 type AmenityCategory string
 
 const (
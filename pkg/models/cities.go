@@ -1,9 +1,9 @@
 package models
 
 import (
+	"barista/assets"
 	"barista/pkg/log"
 	"encoding/json"
-	"os"
 )
 
 type Province struct {
@@ -17,12 +17,23 @@ type City struct {
 	ProvinceID int    `json:"ostan"`
 }
 
+// District is a neighbourhood or municipal district of a city, loaded from the optional districts.json.
+type District struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	CityID int    `json:"city"`
+}
+
 var Provinces = []Province{}
 var Cities = []City{}
+var Districts = []District{}
 var ProvinceCities = map[int][]City{}
+var ProvinceByID = map[int]Province{}
+var CityByID = map[int]City{}
+var CityDistricts = map[int][]District{}
 
 func init() {
-	file, err := os.Open("assets/ostan.json")
+	file, err := assets.Files.Open("ostan.json")
 	if err != nil {
 		panic(err)
 	}
@@ -35,7 +46,7 @@ func init() {
 		panic(err)
 	}
 
-	file, err = os.Open("assets/shahr.json")
+	file, err = assets.Files.Open("shahr.json")
 	if err != nil {
 		panic(err)
 	}
@@ -48,9 +59,31 @@ func init() {
 		panic(err)
 	}
 
+	file, err = assets.Files.Open("districts.json")
+	if err != nil {
+		panic(err)
+	}
+
+	defer file.Close()
+
+	decoder = json.NewDecoder(file)
+	err = decoder.Decode(&Districts)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, province := range Provinces {
+		ProvinceByID[province.ID] = province
+	}
 	for _, city := range Cities {
 		ProvinceCities[city.ProvinceID] = append(ProvinceCities[city.ProvinceID], city)
+		CityByID[city.ID] = city
+	}
+	for _, district := range Districts {
+		CityDistricts[district.CityID] = append(CityDistricts[district.CityID], district)
 	}
 	log.GetLog().Info("Provinces loaded successfully")
 	log.GetLog().Info("Cities loaded successfully")
+
+	loadBoundaries()
 }
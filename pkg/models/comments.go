@@ -1,11 +0,0 @@
-package models
-
-import "time"
-
-type Comment struct {
-	ID      int32     `json:"id"`
-	UserID  int32     `json:"user_id"`
-	CafeID  int32     `json:"cafe_id"`
-	Comment string    `json:"comment"`
-	Date    time.Time `json:"date"`
-}
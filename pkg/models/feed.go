@@ -0,0 +1,35 @@
+package models
+
+type FeedSectionKey string
+
+const (
+	FeedNearby         FeedSectionKey = "nearby"
+	FeedForYou         FeedSectionKey = "for_you"
+	FeedFavoriteEvents FeedSectionKey = "favorite_events"
+	FeedTrending       FeedSectionKey = "trending"
+	FeedTopRated       FeedSectionKey = "top_rated"
+	FeedUpcomingEvents FeedSectionKey = "upcoming_events"
+)
+
+var FeedSectionTitles = map[FeedSectionKey]string{
+	FeedNearby:         "نزدیک شما",
+	FeedForYou:         "پیشنهاد برای شما",
+	FeedFavoriteEvents: "رویدادهای کافه‌های مورد علاقه",
+	FeedTrending:       "پرطرفدار در شهر شما",
+	FeedTopRated:       "بالاترین امتیازها",
+	FeedUpcomingEvents: "رویدادهای پیش رو",
+}
+
+// FeedSection holds either cafes or events, depending on its key.
+type FeedSection struct {
+	Key    FeedSectionKey `json:"key"`
+	Title  string         `json:"title"`
+	Cafes  []CafeCard     `json:"cafes,omitempty"`
+	Events []*Event       `json:"events,omitempty"`
+}
+
+type HomeFeed struct {
+	City         int           `json:"city,omitempty"`
+	Personalized bool          `json:"personalized"`
+	Sections     []FeedSection `json:"sections"`
+}
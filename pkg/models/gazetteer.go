@@ -0,0 +1,13 @@
+package models
+
+type GazetteerProvince struct {
+	Province
+	Cafes int `json:"cafes"`
+}
+
+type GazetteerCity struct {
+	City
+	ProvinceName string     `json:"province_name"`
+	Cafes        int        `json:"cafes"`
+	Districts    []District `json:"districts,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type GiftCard struct {
+	Code       string    `json:"code"`
+	BuyerID    int32     `json:"buyer_id"`
+	CafeID     int32     `json:"cafe_id"`
+	Amount     int64     `json:"amount"`
+	Balance    int64     `json:"balance"`
+	RedeemedBy int32     `json:"redeemed_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type TransferStatus string
+
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferConfirmed TransferStatus = "confirmed"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+type TransferRequest struct {
+	ID            int32          `json:"id"`
+	SenderID      int32          `json:"sender_id"`
+	ReceiverID    int32          `json:"-"`
+	Amount        int64          `json:"amount"`
+	Message       string         `json:"message"`
+	Status        TransferStatus `json:"status"`
+	TransactionID string         `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+}
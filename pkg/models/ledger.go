@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type LedgerIssueType string
+
+const (
+	LedgerIssueBalanceMismatch          LedgerIssueType = "balance_mismatch"
+	LedgerIssueTransactionCollision     LedgerIssueType = "transaction_collision"
+	LedgerIssueSharedTransaction        LedgerIssueType = "shared_transaction"
+	LedgerIssueOrphanedReservation      LedgerIssueType = "orphaned_reservation"
+	LedgerIssueOrphanedEventReservation LedgerIssueType = "orphaned_event_reservation"
+)
+
+type LedgerIssue struct {
+	ID        int32           `json:"id"`
+	RunID     int32           `json:"run_id"`
+	Type      LedgerIssueType `json:"type"`
+	UserID    int32           `json:"user_id"`
+	Reference string          `json:"reference"`
+	Expected  int64           `json:"expected"`
+	Actual    int64           `json:"actual"`
+	Detail    string          `json:"detail"`
+}
+
+type ReconciliationRun struct {
+	ID         int32         `json:"id"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Wallets    int32         `json:"wallets"`
+	Mismatches int32         `json:"mismatches"`
+	Collisions int32         `json:"collisions"`
+	Orphans    int32         `json:"orphans"`
+	Issues     []LedgerIssue `json:"issues,omitempty"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// LoyaltyRule is configured by a cafe's manager. Points are earned per EarnUnit paid and are only spendable at the same cafe.
+type LoyaltyRule struct {
+	CafeID         int32 `json:"cafe_id"`
+	EarnUnit       int64 `json:"earn_unit"`
+	PointsPerUnit  int64 `json:"points_per_unit"`
+	PointValue     int64 `json:"point_value"`
+	StampsRequired int32 `json:"stamps_required"`
+	StampRewardCap int64 `json:"stamp_reward_cap"`
+}
+
+func (r LoyaltyRule) PointsFor(amount int64) int64 {
+	if r.EarnUnit <= 0 || r.PointsPerUnit <= 0 || amount <= 0 {
+		return 0
+	}
+	return amount / r.EarnUnit * r.PointsPerUnit
+}
+
+type LoyaltyCard struct {
+	UserID         int32  `json:"user_id"`
+	CafeID         int32  `json:"cafe_id"`
+	CafeName       string `json:"cafe_name"`
+	Points         int64  `json:"points"`
+	Stamps         int32  `json:"stamps"`
+	Rewards        int32  `json:"rewards"`
+	StampsRequired int32  `json:"stamps_required"`
+}
+
+type LoyaltyReason string
+
+const (
+	LoyaltyEarned   LoyaltyReason = "earned"
+	LoyaltyRedeemed LoyaltyReason = "redeemed"
+)
+
+// LoyaltyEntry is one change to a loyalty card; negative values are spent.
+type LoyaltyEntry struct {
+	ID        int32         `json:"id"`
+	UserID    int32         `json:"user_id"`
+	CafeID    int32         `json:"cafe_id"`
+	Points    int64         `json:"points"`
+	Stamps    int32         `json:"stamps"`
+	Rewards   int32         `json:"rewards"`
+	Reason    LoyaltyReason `json:"reason"`
+	Reference string        `json:"reference"`
+	CreatedAt time.Time     `json:"created_at"`
+}
@@ -1,11 +1,132 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 type MenuItem struct {
-	ID          int32            `json:"id"`
-	CafeID      int32            `json:"cafe_id"`
-	Name        string           `json:"name"`
-	Price       float64          `json:"price"`
-	Category    MenuItemCategory `json:"category"`
-	Ingredients []string         `json:"ingredients"`
-	ImageID     string           `json:"image_id"`
+	ID          int32             `json:"id"`
+	CafeID      int32             `json:"cafe_id"`
+	SKU         string            `json:"sku,omitempty"`
+	Name        string            `json:"name"`
+	Price       float64           `json:"price"`
+	Category    MenuItemCategory  `json:"category"`
+	Ingredients []string          `json:"ingredients"`
+	ImageID     string            `json:"image_id"`
+	Options     []MenuOptionGroup `json:"options"`
+	Allergens   []Allergen        `json:"allergens"`
+	Dietary     []DietaryTag      `json:"dietary"`
+	Calories    *int32            `json:"calories,omitempty"`
+	CaffeineMg  *int32            `json:"caffeine_mg,omitempty"`
+	Hidden      bool              `json:"hidden"`
+	SoldOut     bool              `json:"sold_out"`
+	DailyStock  *int32            `json:"daily_stock,omitempty"`
+	StockLeft   *int32            `json:"stock_left,omitempty"`
+	Schedule    *MenuSchedule     `json:"schedule,omitempty"`
+	SoldOutOn   *time.Time        `json:"-"`
+	StockDay    *time.Time        `json:"-"`
+	StockUsed   int32             `json:"-"`
+}
+
+// MenuSchedule limits an item or a category to certain hours of the day and to a range of dates, both ends
+// included. Equal hours mean all day and a start hour after the end hour runs past midnight, as with cafe
+// opening hours. Dates are taken in Iran time.
+type MenuSchedule struct {
+	StartHour int8       `json:"start_hour"`
+	EndHour   int8       `json:"end_hour"`
+	StartDate *time.Time `json:"start_date,omitempty"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+}
+
+// MenuAvailability replaces how an item is offered, so every field has to be sent: a nil DailyStock means
+// no limit and a nil Schedule means always. SoldOut applies to the current day only.
+type MenuAvailability struct {
+	ItemID     int32         `json:"item"`
+	Hidden     bool          `json:"hidden"`
+	SoldOut    bool          `json:"sold_out"`
+	DailyStock *int32        `json:"daily_stock"`
+	Schedule   *MenuSchedule `json:"schedule"`
+}
+
+var menuAvailabilityFields = []string{"item", "hidden", "sold_out", "daily_stock", "schedule"}
+
+// UnmarshalJSON refuses a body that leaves out any field, null included, since replacing would clear it.
+func (a *MenuAvailability) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	for _, field := range menuAvailabilityFields {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("menu availability is missing %s", field)
+		}
+	}
+
+	type availability MenuAvailability
+	return json.Unmarshal(data, (*availability)(a))
+}
+
+// MenuFilter narrows a menu to items with all of Dietary and none of ExcludeAllergens that can be ordered
+// at At, or now when it is zero. All keeps hidden, sold out and out of schedule items for the manager.
+type MenuFilter struct {
+	Dietary          []DietaryTag
+	ExcludeAllergens []Allergen
+	At               time.Time
+	All              bool
+}
+
+// MenuOptionKind tells a size or milk choice, which picks one version of the item, from add-ons.
+type MenuOptionKind string
+
+const (
+	MenuOptionVariant  MenuOptionKind = "variant"
+	MenuOptionModifier MenuOptionKind = "modifier"
+)
+
+// MenuOptionGroup is a set of choices offered with an item, like sizes or extra shots. A customer picks
+// between MinSelect and MaxSelect of them; a required group needs at least one. A variant group is
+// always exactly one choice.
+type MenuOptionGroup struct {
+	Name      string         `json:"name"`
+	Kind      MenuOptionKind `json:"kind"`
+	Required  bool           `json:"required"`
+	MinSelect int            `json:"min_select"`
+	MaxSelect int            `json:"max_select"`
+	Choices   []MenuOption   `json:"choices"`
+}
+
+// MenuOption is one choice in a group; PriceDelta is added to the item's price and may be negative.
+type MenuOption struct {
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+type MenuImportMode string
+
+const (
+	MenuImportUpsert  MenuImportMode = "upsert"
+	MenuImportReplace MenuImportMode = "replace"
+)
+
+// MenuImportError points at a bad item by its line in a CSV file, or its position in a JSON list.
+type MenuImportError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku,omitempty"`
+	Message string `json:"message"`
+}
+
+// MenuImportReport tells what an import did, or would do in a dry run. Nothing is written when there
+// are errors. Deleted counts items a replace removed because the file does not have them.
+type MenuImportReport struct {
+	Mode    MenuImportMode    `json:"mode"`
+	DryRun  bool              `json:"dry_run"`
+	Applied bool              `json:"applied"`
+	Total   int               `json:"total"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Deleted int               `json:"deleted"`
+	Errors  []MenuImportError `json:"errors"`
 }
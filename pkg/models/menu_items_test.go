@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMenuAvailabilityUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"every field", `{"item": 3, "hidden": false, "sold_out": true, "daily_stock": 20, "schedule": null}`, false},
+		{"nulls clear the limits", `{"item": 3, "hidden": true, "sold_out": false, "daily_stock": null, "schedule": null}`, false},
+		{"missing stock", `{"item": 3, "hidden": false, "sold_out": true, "schedule": null}`, true},
+		{"only sold out", `{"item": 3, "sold_out": true}`, true},
+		{"not an object", `[]`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var availability MenuAvailability
+			err := json.Unmarshal([]byte(tt.body), &availability)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int32(3), availability.ItemID)
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type NotificationKind string
+
+const (
+	NotificationReviewReply NotificationKind = "review_reply"
+)
+
+// Notification is an in-app message for a user. ReferenceID points at what it is about, e.g. the review for a reply.
+type Notification struct {
+	ID          int32            `json:"id"`
+	UserID      int32            `json:"user_id"`
+	Kind        NotificationKind `json:"kind"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body"`
+	ReferenceID int32            `json:"reference_id"`
+	Read        bool             `json:"read"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
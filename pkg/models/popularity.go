@@ -0,0 +1,28 @@
+package models
+
+// RatingPriorWeight is how many ratings of the overall mean every cafe starts with.
+const RatingPriorWeight = 10
+
+// ActivitySignals are time-decayed counts, so a reservation from last month weighs less than one from today.
+type ActivitySignals struct {
+	Reservations float64
+	Favorites    float64
+	Comments     float64
+	Views        float64
+}
+
+type PopularitySignals struct {
+	CafeID      int32
+	RatingSum   float64
+	RatingCount int
+	// Recent decays slowly over all history, Trend quickly over the last two weeks
+	Recent ActivitySignals
+	Trend  ActivitySignals
+}
+
+type CafePopularity struct {
+	CafeID     int32
+	Rating     float64
+	Popularity float64
+	Trending   float64
+}
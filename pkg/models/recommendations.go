@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// CafeFeatures is what content similarity compares. Price is +Inf when the cafe has no price at all.
+type CafeFeatures struct {
+	ID         int32
+	City       int
+	Price      float64
+	Categories []string
+	Amenities  []string
+}
+
+// Interaction is one user's signal about a cafe; Rating is 0 when they only saved it as a favorite.
+type Interaction struct {
+	UserID   int32
+	CafeID   int32
+	Rating   int32
+	Favorite bool
+}
+
+type CafeSimilarity struct {
+	CafeID    int32
+	SimilarID int32
+	Score     float64
+}
+
+type UserRecommendation struct {
+	UserID int32
+	CafeID int32
+	Score  float64
+}
+
+// RecommendationRun records one offline rebuild and how well its model predicted held-out ratings.
+type RecommendationRun struct {
+	ID           int32     `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Cafes        int       `json:"cafes"`
+	Users        int       `json:"users"`
+	HeldOut      int       `json:"held_out"`
+	K            int       `json:"k"`
+	PrecisionAtK float64   `json:"precision_at_k"`
+	RecallAtK    float64   `json:"recall_at_k"`
+	Coverage     float64   `json:"coverage"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type ReferralStatus string
+
+const (
+	ReferralPending  ReferralStatus = "pending"
+	ReferralVerified ReferralStatus = "verified"
+	ReferralRewarded ReferralStatus = "rewarded"
+	ReferralRejected ReferralStatus = "rejected"
+)
+
+type Referral struct {
+	ReferrerID int32          `json:"referrer_id"`
+	ReferredID int32          `json:"referred_id"`
+	Status     ReferralStatus `json:"status"`
+	Reason     string         `json:"reason,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type ReferralStats struct {
+	Code     string `json:"code"`
+	Invited  int32  `json:"invited"`
+	Verified int32  `json:"verified"`
+	Rewarded int32  `json:"rewarded"`
+	Rejected int32  `json:"rejected"`
+	Earned   int64  `json:"earned"`
+}
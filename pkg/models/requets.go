@@ -13,3 +13,23 @@ type RequestWithdraw struct {
 type RequestDeposit struct {
 	Amount int64 `json:"amount"`
 }
+
+type RequestTransferByContact struct {
+	Email   string `json:"email"`
+	Phone   int64  `json:"phone"`
+	Amount  int64  `json:"amount"`
+	Message string `json:"message"`
+}
+
+type RequestTransferAction struct {
+	TransferID int32 `json:"transfer_id"`
+}
+
+type RequestBuyGiftCard struct {
+	Amount int64 `json:"amount"`
+	CafeID int32 `json:"cafe_id"`
+}
+
+type RequestRedeemGiftCard struct {
+	Code string `json:"code"`
+}
@@ -2,12 +2,47 @@ package models
 
 import "time"
 
+type ReservationStatus string
+
+const (
+	ReservationPending   ReservationStatus = "pending"
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationReleased  ReservationStatus = "released"
+)
+
 type Reservation struct {
-	ID            int32     `json:"id"`
-	UserID        int32     `json:"user_id"`
-	CafeID        int32     `json:"cafe_id"`
-	TransactionID string    `json:"transaction_id"`
-	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:"end_time"`
-	People        int32     `json:"people"`
+	ID            int32             `json:"id"`
+	UserID        int32             `json:"user_id"`
+	CafeID        int32             `json:"cafe_id"`
+	TransactionID string            `json:"transaction_id"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       time.Time         `json:"end_time"`
+	People        int32             `json:"people"`
+	Status        ReservationStatus `json:"status"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"`
+}
+
+// CheckoutOptions carries the optional ways a customer pays for a reservation besides their wallet.
+type CheckoutOptions struct {
+	GiftCardCode   string `json:"gift_card_code"`
+	RedeemPoints   int64  `json:"redeem_points"`
+	UseStampReward bool   `json:"use_stamp_reward"`
+}
+
+type ShareStatus string
+
+const (
+	SharePending   ShareStatus = "pending"
+	SharePaid      ShareStatus = "paid"
+	ShareRefunded  ShareStatus = "refunded"
+	ShareCancelled ShareStatus = "cancelled"
+)
+
+type ReservationShare struct {
+	ID            int32       `json:"id"`
+	ReservationID int32       `json:"reservation_id"`
+	UserID        int32       `json:"user_id"`
+	Amount        int64       `json:"amount"`
+	Status        ShareStatus `json:"status"`
+	TransactionID string      `json:"transaction_id,omitempty"`
 }
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// ReviewStatus is where a review's text stands in moderation. Pending and hidden reviews wait in the admin
+// queue; approved ones were cleared by an admin and are no longer hidden by reports.
+type ReviewStatus string
+
+const (
+	ReviewPublished ReviewStatus = "published"
+	ReviewPending   ReviewStatus = "pending"
+	ReviewHidden    ReviewStatus = "hidden"
+	ReviewApproved  ReviewStatus = "approved"
+	ReviewRejected  ReviewStatus = "rejected"
+)
+
+// ReviewEditLocked are the statuses an author cannot lift by editing; only an admin can.
+var ReviewEditLocked = []ReviewStatus{ReviewHidden, ReviewRejected}
+
+// AfterEdit is the status a review in status s takes when its author saves text that moderation judged
+// moderated. Reviews hidden by reports or rejected by an admin stay that way.
+func (s ReviewStatus) AfterEdit(moderated ReviewStatus) ReviewStatus {
+	if slices.Contains(ReviewEditLocked, s) {
+		return s
+	}
+	return moderated
+}
+
+// reasons a review is held for moderation
+const (
+	ModerationProfanity = "profanity"
+	ModerationLink      = "link"
+	ModerationPhone     = "phone"
+	ModerationDuplicate = "duplicate"
+)
+
+// Review is a user's single review of a cafe. Rating is 0 only for comments migrated without stars,
+// and the sub-scores are optional. Verified is set when the user has been to the cafe with a reservation
+// or an event ticket.
+type Review struct {
+	ID        int32        `json:"id"`
+	UserID    int32        `json:"user_id"`
+	CafeID    int32        `json:"cafe_id"`
+	Rating    int32        `json:"rating"`
+	Comment   string       `json:"comment"`
+	Coffee    *int32       `json:"coffee,omitempty"`
+	Service   *int32       `json:"service,omitempty"`
+	Ambience  *int32       `json:"ambience,omitempty"`
+	Value     *int32       `json:"value,omitempty"`
+	VisitDate *time.Time   `json:"visit_date,omitempty"`
+	Verified  bool         `json:"verified"`
+	FirstName string       `json:"first_name"`
+	LastName  string       `json:"last_name"`
+	Reply     *ReviewReply `json:"reply,omitempty"`
+	Edits     int32        `json:"edits"`
+	Status    ReviewStatus `json:"status"`
+	Flags     []string     `json:"flags,omitempty"`
+	Reports   int32        `json:"reports,omitempty"`
+	Photos    []string     `json:"photos"`
+	Helpful   int32        `json:"helpful"`
+	Unhelpful int32        `json:"unhelpful"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// ReviewReply is the cafe manager's public answer to a review; there is at most one per review.
+type ReviewReply struct {
+	Comment   string    `json:"comment"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReviewEdit is an earlier version of a review, kept when the user changes it.
+type ReviewEdit struct {
+	Rating    int32      `json:"rating"`
+	Comment   string     `json:"comment"`
+	Coffee    *int32     `json:"coffee,omitempty"`
+	Service   *int32     `json:"service,omitempty"`
+	Ambience  *int32     `json:"ambience,omitempty"`
+	Value     *int32     `json:"value,omitempty"`
+	VisitDate *time.Time `json:"visit_date,omitempty"`
+	EditedAt  time.Time  `json:"edited_at"`
+}
+
+// ReviewSort is an order a cafe's reviews can be listed in. Verified lists only verified visits, newest first.
+type ReviewSort string
+
+const (
+	ReviewSortNewest   ReviewSort = "newest"
+	ReviewSortHelpful  ReviewSort = "helpful"
+	ReviewSortHighest  ReviewSort = "highest"
+	ReviewSortLowest   ReviewSort = "lowest"
+	ReviewSortVerified ReviewSort = "verified"
+)
+
+// ReviewQuery selects a page of a cafe's reviews. Cursor is the Next of the previous page, empty for the first.
+type ReviewQuery struct {
+	Sort        ReviewSort
+	WithComment bool
+	Cursor      string
+	Limit       int
+}
+
+// ReviewPage is one page of reviews; Next is empty on the last page.
+type ReviewPage struct {
+	Reviews []*Review `json:"reviews"`
+	Total   int       `json:"total"`
+	Next    string    `json:"next,omitempty"`
+}
+
+// RatingStats summarizes a cafe's ratings. Histogram[0] counts one-star ratings; an aspect average is
+// nil until someone has scored it.
+type RatingStats struct {
+	Average   float64            `json:"average"`
+	Count     int32              `json:"count"`
+	Histogram [5]int32           `json:"histogram"`
+	Aspects   AspectAverages     `json:"aspects"`
+	Trend     []RatingTrendPoint `json:"trend"`
+}
+
+type AspectAverages struct {
+	Coffee   *float64 `json:"coffee"`
+	Service  *float64 `json:"service"`
+	Ambience *float64 `json:"ambience"`
+	Value    *float64 `json:"value"`
+}
+
+// RatingTrendPoint covers the ratings given in the week starting on Week.
+type RatingTrendPoint struct {
+	Week    time.Time `json:"week"`
+	Count   int32     `json:"count"`
+	Average float64   `json:"average"`
+}
+
+// ReviewPhoto is a visitor's photo attached to their review, kept apart from the images the cafe posts.
+// Photos are pending until an admin approves them.
+type ReviewPhoto struct {
+	ImageID   string       `json:"image_id"`
+	ReviewID  int32        `json:"review_id"`
+	CafeID    int32        `json:"cafe_id"`
+	UserID    int32        `json:"user_id"`
+	FirstName string       `json:"first_name"`
+	Status    ReviewStatus `json:"status"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type ReviewPhotoPage struct {
+	Photos []ReviewPhoto `json:"photos"`
+	Total  int           `json:"total"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReviewStatusAfterEdit(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    ReviewStatus
+		moderated ReviewStatus
+		want      ReviewStatus
+	}{
+		{"published stays published", ReviewPublished, ReviewPublished, ReviewPublished},
+		{"published edit held", ReviewPublished, ReviewPending, ReviewPending},
+		{"pending edit cleared", ReviewPending, ReviewPublished, ReviewPublished},
+		{"approved edit held again", ReviewApproved, ReviewPending, ReviewPending},
+		{"hidden by reports stays hidden after a clean edit", ReviewHidden, ReviewPublished, ReviewHidden},
+		{"hidden stays hidden after a flagged edit", ReviewHidden, ReviewPending, ReviewHidden},
+		{"rejected stays rejected after a clean edit", ReviewRejected, ReviewPublished, ReviewRejected},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, test.status.AfterEdit(test.moderated), test.name)
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import "time"
+
+type SearchSort string
+
+const (
+	SearchSortRelevance  SearchSort = "relevance"
+	SearchSortRating     SearchSort = "rating"
+	SearchSortDistance   SearchSort = "distance"
+	SearchSortPrice      SearchSort = "price"
+	SearchSortPopularity SearchSort = "popularity"
+)
+
+// CafeSearchFilter is built by the cafe handler; Terms and Query are already Persian-normalized.
+type CafeSearchFilter struct {
+	Query      string            `json:"query"`
+	Terms      []string          `json:"-"`
+	Province   int               `json:"province"`
+	City       int               `json:"city"`
+	Categories []CafeCategory    `json:"categories"`
+	Amenities  []AmenityCategory `json:"amenities"`
+	Dietary    []DietaryTag      `json:"dietary"`
+	MinPrice   float64           `json:"min_price"`
+	MaxPrice   float64           `json:"max_price"`
+	MinRating  float64           `json:"min_rating"`
+	OpenNow    bool              `json:"open_now"`
+	Hour       int               `json:"-"`
+	HasEvents  bool              `json:"has_events"`
+	Lat        *float64          `json:"lat"`
+	Lng        *float64          `json:"lng"`
+	InMyCity   bool              `json:"in_my_city"`
+	RadiusKm   float64           `json:"radius"`
+	Bounds     *GeoBounds        `json:"bounds"`
+	Sort       SearchSort        `json:"sort"`
+	Cursor     string            `json:"cursor"`
+	Limit      int               `json:"limit"`
+	UserID     int32             `json:"-"`
+	IDs        []int32           `json:"-"`
+	ExcludeIDs []int32           `json:"-"`
+}
+
+type GeoBounds struct {
+	MinLat float64 `json:"min_lat"`
+	MinLng float64 `json:"min_lng"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLng float64 `json:"max_lng"`
+}
+
+type CafeSearchResult struct {
+	Cafes      []Cafe `json:"cafes"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// CafeCard is the compact form of a cafe used on the map and in result lists.
+type CafeCard struct {
+	ID               int32             `json:"id"`
+	Name             string            `json:"name"`
+	Rating           float64           `json:"rating"`
+	Photo            string            `json:"photo,omitempty"`
+	Categories       []CafeCategory    `json:"categories"`
+	Amenities        []AmenityCategory `json:"amenities"`
+	OpeningTime      int8              `json:"opening_time"`
+	ClosingTime      int8              `json:"closing_time"`
+	ReservationPrice float64           `json:"reservation_price"`
+	Province         int               `json:"province"`
+	City             int               `json:"city"`
+	Location         Location          `json:"location"`
+	Distance         *float64          `json:"distance,omitempty"`
+}
+
+type CafeCardResult struct {
+	Cafes      []CafeCard `json:"cafes"`
+	Total      int        `json:"total"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+type SuggestionType string
+
+const (
+	SuggestionRecent   SuggestionType = "recent"
+	SuggestionCafe     SuggestionType = "cafe"
+	SuggestionCategory SuggestionType = "category"
+	SuggestionCity     SuggestionType = "city"
+	SuggestionProvince SuggestionType = "province"
+	SuggestionMenuItem SuggestionType = "menu_item"
+)
+
+// Suggestion is one typeahead entry. ID is set for cafes, cities and provinces, Key for categories.
+type Suggestion struct {
+	Type     SuggestionType `json:"type"`
+	Text     string         `json:"text"`
+	ID       int32          `json:"id,omitempty"`
+	Key      string         `json:"key,omitempty"`
+	Count    int            `json:"count,omitempty"`
+	Distance *float64       `json:"distance,omitempty"`
+}
+
+type RecentSearch struct {
+	Query      string    `json:"query"`
+	SearchedAt time.Time `json:"searched_at"`
+}
@@ -9,8 +9,34 @@ const (
 	Deposit
 	Withdraw
 	Transfer
+	GiftCardPurchase
+	GiftCardRedeem
+	ReservationHold
+	ReservationPayout
+	Refund
+	ReferralReward
 )
 
+var TransactionTypes = []TransactionType{Deposit, Withdraw, Transfer, GiftCardPurchase, GiftCardRedeem, ReservationHold, ReservationPayout, Refund, ReferralReward}
+
+// DebitsSender reports whether a transaction of this type is taken from the sender's wallet.
+func (t TransactionType) DebitsSender() bool {
+	switch t {
+	case Deposit, GiftCardRedeem, ReservationPayout, Refund, ReferralReward:
+		return false
+	}
+	return true
+}
+
+// CreditsReceiver reports whether a transaction of this type is added to the receiver's wallet.
+func (t TransactionType) CreditsReceiver() bool {
+	switch t {
+	case Withdraw, GiftCardPurchase, ReservationHold:
+		return false
+	}
+	return true
+}
+
 type Transaction struct {
 	ID          string          `json:"id"`
 	SenderID    int32           `json:"sender_id"`
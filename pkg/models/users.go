@@ -44,4 +44,6 @@ type User struct {
 	BankAccount string `json:"bank_account"`
 	Balance     int64  `json:"balance"`
 	IsVerified  bool   `json:"is_verified"`
+
+	ReferralCode string `json:"referral_code,omitempty"`
 }
@@ -0,0 +1,274 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// lower than the pg_trgm default of 0.6 so a typo or two in a short Persian word still matches
+const searchWordSimilarity = 0.4
+
+const cafeSearchDocument = `persian_normalize(concat_ws(' ', name, description, address,
+	(SELECT string_agg(concat_ws(' ', m.name, m.ingredients), ' ') FROM menu_items m WHERE m.cafe_id = cafes.id)))`
+
+const (
+	cafeRatingExpr = `COALESCE((SELECT s.rating_sum::FLOAT / NULLIF(s.ratings, 0) FROM cafe_rating_stats s WHERE s.cafe_id = cafes.id), 0)`
+	// the cheapest way into the cafe, either a table reservation or a menu item
+	cafePriceExpr = `COALESCE(LEAST(reservation_price, (SELECT MIN(m.price) FROM menu_items m WHERE m.cafe_id = cafes.id AND NOT m.hidden)), 'Infinity')`
+	// scores of the periodic popularity job, so cafes created since its last run start at zero
+	cafePopularityExpr = `COALESCE((SELECT p.popularity FROM cafe_popularity p WHERE p.cafe_id = cafes.id), 0)`
+)
+
+// cafes without ratings rank at the prior mean
+var cafeRankedRatingExpr = `COALESCE((SELECT ` + bayesianRatingExpr + ` FROM cafe_rating_stats s WHERE s.cafe_id = cafes.id), ` + ratingMeanExpr + `)`
+
+func (c *CafesRepoImp) RefreshSearchDocument(ctx context.Context, id int32) error {
+	_, err := c.postgres.Exec(ctx, "UPDATE cafes SET search_document = "+cafeSearchDocument+" WHERE id = $1", id)
+	if err != nil {
+		log.GetLog().Errorf("Unable to refresh cafe search document. error: %v", err)
+	}
+	return err
+}
+
+func (c *CafesRepoImp) RebuildSearchDocuments(ctx context.Context) error {
+	_, err := c.postgres.Exec(ctx, "UPDATE cafes SET search_document = "+cafeSearchDocument)
+	if err != nil {
+		log.GetLog().Errorf("Unable to rebuild cafe search documents. error: %v", err)
+	}
+	return err
+}
+
+type searchCursor struct {
+	sort  models.SearchSort
+	value float64
+	id    int32
+}
+
+func (s searchCursor) encode() string {
+	raw := fmt.Sprintf("%s:%s:%d", s.sort, strconv.FormatFloat(s.value, 'g', -1, 64), s.id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string, sort models.SearchSort) (*searchCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.ErrSearchCursorInvalid.Error()
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || models.SearchSort(parts[0]) != sort {
+		return nil, errors.ErrSearchCursorInvalid.Error()
+	}
+
+	value, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return nil, errors.ErrSearchCursorInvalid.Error()
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 32)
+	if err != nil {
+		return nil, errors.ErrSearchCursorInvalid.Error()
+	}
+
+	return &searchCursor{sort: sort, value: value, id: int32(id)}, nil
+}
+
+// SearchCafe pages with a keyset on (sort_key, id), so results stay stable while cafes are added or edited.
+func (c *CafesRepoImp) SearchCafe(ctx context.Context, filter *models.CafeSearchFilter) (result *models.CafeSearchResult, e error) {
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{"NOT cafes.hidden"}
+	rank := []string{"0"}
+	for _, term := range filter.Terms {
+		placeholder := arg(term)
+		where = append(where, placeholder+" <% search_document")
+		rank = append(rank, "word_similarity("+placeholder+", search_document)")
+	}
+	// only bound when ranking by relevance, since Postgres rejects parameters it cannot see
+	if filter.Query != "" && filter.Sort == models.SearchSortRelevance {
+		rank = append(rank, "2 * similarity(persian_normalize(name), "+arg(filter.Query)+")")
+	}
+	if filter.Province != 0 {
+		where = append(where, "province = "+arg(filter.Province))
+	}
+	if filter.City != 0 {
+		where = append(where, "city = "+arg(filter.City))
+	}
+	if len(filter.Categories) > 0 {
+		categories := []string{}
+		for _, category := range filter.Categories {
+			categories = append(categories, string(category))
+		}
+		where = append(where, "EXISTS (SELECT 1 FROM unnest(string_to_array(categories, ',')) c WHERE btrim(c) = ANY("+arg(categories)+"))")
+	}
+	// amenities are stored either as keys or by their Persian name, and every requested one must be present
+	for _, amenity := range filter.Amenities {
+		names := []string{string(amenity), models.AmenityCategoryPersians[amenity]}
+		where = append(where, "EXISTS (SELECT 1 FROM unnest(string_to_array(amenities, ',')) a WHERE btrim(a) = ANY("+arg(names)+"))")
+	}
+	// a cafe serves a diet when one of its menu items is tagged with it, or when it lists the matching amenity
+	for _, tag := range filter.Dietary {
+		condition := "EXISTS (SELECT 1 FROM menu_items m WHERE m.cafe_id = cafes.id AND NOT m.hidden AND " + arg(string(tag)) + " = ANY(m.dietary))"
+		if amenity, ok := models.DietaryTagAmenities[tag]; ok {
+			names := []string{string(amenity), models.AmenityCategoryPersians[amenity]}
+			condition += " OR EXISTS (SELECT 1 FROM unnest(string_to_array(amenities, ',')) a WHERE btrim(a) = ANY(" + arg(names) + "))"
+		}
+		where = append(where, "("+condition+")")
+	}
+	if filter.MinPrice > 0 {
+		where = append(where, cafePriceExpr+" >= "+arg(filter.MinPrice))
+	}
+	if filter.MaxPrice > 0 {
+		where = append(where, cafePriceExpr+" <= "+arg(filter.MaxPrice))
+	}
+	if filter.MinRating > 0 {
+		where = append(where, cafeRatingExpr+" >= "+arg(filter.MinRating))
+	}
+	if filter.OpenNow {
+		hour := arg(filter.Hour)
+		where = append(where, fmt.Sprintf(`CASE WHEN opening_time <= closing_time
+			THEN %[1]s >= opening_time AND %[1]s < closing_time
+			ELSE %[1]s >= opening_time OR %[1]s < closing_time END`, hour))
+	}
+	if len(filter.IDs) > 0 {
+		where = append(where, "cafes.id = ANY("+arg(filter.IDs)+")")
+	}
+	if len(filter.ExcludeIDs) > 0 {
+		where = append(where, "NOT (cafes.id = ANY("+arg(filter.ExcludeIDs)+"))")
+	}
+	if filter.HasEvents {
+		where = append(where, "EXISTS (SELECT 1 FROM events e WHERE e.cafe_id = cafes.id AND e.end_time > NOW())")
+	}
+
+	distance := "NULL::FLOAT"
+	if filter.Lat != nil && filter.Lng != nil {
+		distance = fmt.Sprintf("haversine_km(%s, %s, l.latitude, l.longitude)", arg(*filter.Lat), arg(*filter.Lng))
+	}
+	if filter.RadiusKm > 0 {
+		where = append(where, distance+" <= "+arg(filter.RadiusKm))
+	}
+	if filter.Bounds != nil {
+		where = append(where, fmt.Sprintf("l.latitude BETWEEN %s AND %s AND l.longitude BETWEEN %s AND %s",
+			arg(filter.Bounds.MinLat), arg(filter.Bounds.MaxLat), arg(filter.Bounds.MinLng), arg(filter.Bounds.MaxLng)))
+	}
+
+	sortKey, descending := strings.Join(rank, " + "), true
+	switch filter.Sort {
+	case models.SearchSortRating:
+		sortKey = cafeRankedRatingExpr
+	case models.SearchSortPopularity:
+		sortKey = cafePopularityExpr
+	case models.SearchSortPrice:
+		sortKey, descending = cafePriceExpr, false
+	case models.SearchSortDistance:
+		sortKey, descending = "COALESCE("+distance+", 'Infinity')", false
+	}
+
+	filtered := fmt.Sprintf(`SELECT cafes.id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price,
+			l.latitude, l.longitude, %s AS distance, (%s)::FLOAT AS sort_key
+		FROM cafes LEFT JOIN locations l ON l.id = cafes.id
+		WHERE %s`, distance, sortKey, strings.Join(where, " AND "))
+	countArgs := len(args)
+
+	query := "SELECT * FROM (" + filtered + ") s"
+	if filter.Cursor != "" {
+		cursor, err := decodeSearchCursor(filter.Cursor, filter.Sort)
+		if err != nil {
+			return nil, err
+		}
+		comparison := ">"
+		if descending {
+			comparison = "<"
+		}
+		value, id := arg(cursor.value), arg(cursor.id)
+		query += fmt.Sprintf(" WHERE sort_key %s %s OR (sort_key = %s AND id > %s)", comparison, value, value, id)
+	}
+	if descending {
+		query += " ORDER BY sort_key DESC, id"
+	} else {
+		query += " ORDER BY sort_key, id"
+	}
+	// one extra row tells us whether there is a next page
+	query += " LIMIT " + arg(filter.Limit+1)
+
+	// the threshold is per session, so set it inside a transaction to keep it off other pooled queries
+	tx, e := c.postgres.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if e != nil {
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, e = tx.Exec(ctx, fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %v", searchWordSimilarity))
+	if e != nil {
+		log.GetLog().Errorf("Unable to set search threshold. error: %v", e)
+		return
+	}
+
+	result = &models.CafeSearchResult{}
+	e = tx.QueryRow(ctx, "SELECT COUNT(*) FROM ("+filtered+") s", args[:countArgs]...).Scan(&result.Total)
+	if e != nil {
+		log.GetLog().Errorf("Unable to count cafe search results. error: %v", e)
+		return nil, e
+	}
+
+	rows, e := tx.Query(ctx, query, args...)
+	if e != nil {
+		log.GetLog().Errorf("Unable to search cafe. error: %v", e)
+		return nil, e
+	}
+	defer rows.Close()
+
+	var last searchCursor
+	for rows.Next() {
+		var cafe models.Cafe
+		categories := ""
+		amenities := ""
+		var lat, lng *float64
+		var sortValue float64
+		e = rows.Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice, &lat, &lng, &cafe.Distance, &sortValue)
+		if e != nil {
+			log.GetLog().Errorf("Unable to scan cafe. error: %v", e)
+			return nil, e
+		}
+
+		if len(result.Cafes) == filter.Limit {
+			result.NextCursor = last.encode()
+			break
+		}
+
+		for _, category := range strings.Split(categories, ",") {
+			cafe.Categories = append(cafe.Categories, models.CafeCategory(strings.TrimSpace(category)))
+		}
+
+		for _, amenity := range strings.Split(amenities, ",") {
+			cafe.Amenities = append(cafe.Amenities, models.AmenityCategory(strings.TrimSpace(amenity)))
+		}
+
+		if lat != nil && lng != nil {
+			cafe.Location = models.Location{CafeID: cafe.ID, Lat: *lat, Lng: *lng}
+		}
+
+		if cafe.Distance != nil {
+			rounded := math.Round(*cafe.Distance*100) / 100
+			cafe.Distance = &rounded
+		}
+
+		last = searchCursor{sort: filter.Sort, value: sortValue, id: cafe.ID}
+		result.Cafes = append(result.Cafes, cafe)
+	}
+
+	return result, rows.Err()
+}
@@ -1,12 +1,15 @@
 package repo
 
 import (
+	"barista/pkg/errors"
 	"barista/pkg/log"
 	"barista/pkg/models"
 	"context"
+	stderrors "errors"
 	"math/rand"
 	"strings"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/cast"
 )
@@ -37,10 +40,14 @@ const (
 type CafesRepo interface {
 	Create(ctx context.Context, cafe *models.Cafe) (int32, error)
 	GetByID(ctx context.Context, id int32) (*models.Cafe, error)
-	SearchCafe(ctx context.Context, name string, address string, location string, category string) ([]models.Cafe, error)
+	SearchCafe(ctx context.Context, filter *models.CafeSearchFilter) (*models.CafeSearchResult, error)
+	RefreshSearchDocument(ctx context.Context, id int32) error
+	RebuildSearchDocuments(ctx context.Context) error
 	GetByCafeIDs(ctx context.Context, ids []int32) ([]models.Cafe, error)
 	GetByOwnerID(ctx context.Context, id int32) (*models.Cafe, error)
 	Update(ctx context.Context, id int32, updateCafeType UpdateCafeType, value interface{}) error
+	SetHidden(ctx context.Context, id int32, hidden bool) error
+	DeleteByID(ctx context.Context, id int32) error
 }
 
 type CafesRepoImp struct {
@@ -96,6 +103,55 @@ func NewCafeRepoImp(postgres *pgxpool.Pool) *CafesRepoImp {
 		log.GetLog().Errorf("Unable to insert cafes. error: %v", err)
 	}
 
+	_, err = postgres.Exec(context.Background(), `CREATE EXTENSION IF NOT EXISTS pg_trgm`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("extension", "pg_trgm").Fatal("Unable to create extension")
+	}
+
+	// same folding as utils.NormalizePersian, so indexed documents and search terms agree
+	_, err = postgres.Exec(context.Background(),
+		`CREATE OR REPLACE FUNCTION persian_normalize(input TEXT) RETURNS TEXT
+		LANGUAGE SQL IMMUTABLE AS $$
+			SELECT btrim(regexp_replace(regexp_replace(
+				translate(lower(COALESCE(input, '')),
+					'يىئكةۀأإآٱؤ۰۱۲۳۴۵۶۷۸۹٠١٢٣٤٥٦٧٨٩' || E'\u200C',
+					'یییکههااااو01234567890123456789 '),
+				E'[\u064B-\u0652\u0670\u0640\u200B\u200D\uFEFF]', '', 'g'),
+				E'\\s+', ' ', 'g'))
+		$$`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("function", "persian_normalize").Fatal("Unable to create function")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE cafes ADD COLUMN IF NOT EXISTS search_document TEXT DEFAULT ''`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafes").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE cafes ADD COLUMN IF NOT EXISTS hidden BOOLEAN DEFAULT false`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafes").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE INDEX IF NOT EXISTS cafes_search_document_trgm ON cafes USING GIN (search_document gin_trgm_ops)`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafes").Fatal("Unable to create index")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE OR REPLACE FUNCTION haversine_km(lat1 FLOAT, lng1 FLOAT, lat2 FLOAT, lng2 FLOAT) RETURNS FLOAT
+		LANGUAGE SQL IMMUTABLE AS $$
+			SELECT 6371 * 2 * asin(LEAST(1, sqrt(
+				power(sin(radians(lat2 - lat1) / 2), 2) +
+				cos(radians(lat1)) * cos(radians(lat2)) * power(sin(radians(lng2 - lng1) / 2), 2))))
+		$$`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("function", "haversine_km").Fatal("Unable to create function")
+	}
+
 	return &CafesRepoImp{postgres: postgres}
 }
 
@@ -129,7 +185,7 @@ func (c *CafesRepoImp) Create(ctx context.Context, cafe *models.Cafe) (int32, er
 func (c *CafesRepoImp) GetByID(ctx context.Context, id int32) (*models.Cafe, error) {
 	var cafe models.Cafe
 	var categories, amenities string
-	err := c.postgres.QueryRow(ctx, "SELECT id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price FROM cafes WHERE id = $1", id).Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice)
+	err := c.postgres.QueryRow(ctx, "SELECT id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price, hidden FROM cafes WHERE id = $1", id).Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice, &cafe.Hidden)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get cafe by id. error: %v", err)
 	}
@@ -153,57 +209,6 @@ func (c *CafesRepoImp) GetByID(ctx context.Context, id int32) (*models.Cafe, err
 	return &cafe, err
 }
 
-func (c *CafesRepoImp) SearchCafe(ctx context.Context, name string, province string, city string, category string) ([]models.Cafe, error) {
-	var cafes []models.Cafe
-	list := []string{}
-	query := "SELECT id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price FROM cafes"
-
-	if name != "" {
-		list = append(list, "name LIKE '%"+name+"%'")
-	}
-	if province != "" {
-		list = append(list, "province = '"+province+"'")
-	}
-	if city != "" {
-		list = append(list, "city = '"+city+"'")
-	}
-	if category != "" {
-		list = append(list, "categories LIKE '%"+category+"%'")
-	}
-
-	if len(list) > 0 {
-		query += " WHERE " + strings.Join(list, " AND ")
-
-	}
-
-	rows, err := c.postgres.Query(ctx, query)
-	if err != nil {
-		log.GetLog().Errorf("Unable to search cafe. error: %v", err)
-	}
-
-	for rows.Next() {
-		var cafe models.Cafe
-		categories := ""
-		amenities := ""
-		err = rows.Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice)
-		if err != nil {
-			log.GetLog().Errorf("Unable to scan cafe. error: %v", err)
-		}
-
-		for _, category := range strings.Split(categories, ",") {
-			cafe.Categories = append(cafe.Categories, models.CafeCategory(strings.TrimSpace(category)))
-		}
-
-		for _, amenity := range strings.Split(amenities, ",") {
-			cafe.Amenities = append(cafe.Amenities, models.AmenityCategory(strings.TrimSpace(amenity)))
-		}
-
-		cafes = append(cafes, cafe)
-	}
-
-	return cafes, err
-}
-
 func (c *CafesRepoImp) GetByCafeIDs(ctx context.Context, ids []int32) ([]models.Cafe, error) {
 	var cafes []models.Cafe
 	listIds := []string{}
@@ -251,7 +256,7 @@ func (c *CafesRepoImp) GetByOwnerID(ctx context.Context, id int32) (*models.Cafe
 	var cafe models.Cafe
 	categories := ""
 	amenities := ""
-	err := c.postgres.QueryRow(ctx, `SELECT id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price FROM cafes WHERE owner_id = $1`, id).Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice)
+	err := c.postgres.QueryRow(ctx, `SELECT id, owner_id, name, description, opening_time, closing_time, capacity, phone_number, email, province, city, address, location, categories, amenities, reservation_price, hidden FROM cafes WHERE owner_id = $1`, id).Scan(&cafe.ID, &cafe.OwnerID, &cafe.Name, &cafe.Description, &cafe.OpeningTime, &cafe.ClosingTime, &cafe.Capacity, &cafe.ContactInfo.Phone, &cafe.ContactInfo.Email, &cafe.ContactInfo.Province, &cafe.ContactInfo.City, &cafe.ContactInfo.Address, &cafe.ContactInfo.Location, &categories, &amenities, &cafe.ReservationPrice, &cafe.Hidden)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get cafe by owner id. error: %v", err)
 	}
@@ -301,14 +306,46 @@ func (c *CafesRepoImp) Update(ctx context.Context, id int32, updateCafeType Upda
 	return nil
 }
 
-func (c *CafesRepoImp) DeleteByID(ctx context.Context, id int32) error {
-	_, err := c.postgres.Exec(ctx,
-		`DELETE FROM cafes
-		WHERE id = $1`, id)
+// foreignKeyViolation is the Postgres SQLSTATE for a row that is still referenced elsewhere.
+const foreignKeyViolation = "23503"
+
+func (c *CafesRepoImp) SetHidden(ctx context.Context, id int32, hidden bool) error {
+	_, err := c.postgres.Exec(ctx, "UPDATE cafes SET hidden = $1 WHERE id = $2", hidden, id)
 	if err != nil {
-		log.GetLog().Errorf("Unable to delete cafe. error: %v", err)
-		return err
+		log.GetLog().Errorf("Unable to set cafe hidden. error: %v", err)
 	}
-
 	return err
 }
+
+// DeleteByID removes a cafe together with its menu, location and favorites. Cafes that have
+// reservations, reviews or money history cannot be deleted and should be hidden instead.
+func (c *CafesRepoImp) DeleteByID(ctx context.Context, id int32) (e error) {
+	tx, e := c.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	for _, query := range []string{
+		"DELETE FROM favorites WHERE cafe_id = $1",
+		"DELETE FROM menu_items WHERE cafe_id = $1",
+		"DELETE FROM locations WHERE id = $1",
+		"DELETE FROM cafes WHERE id = $1",
+	} {
+		_, e = tx.Exec(ctx, query, id)
+		if e != nil {
+			var pgErr *pgconn.PgError
+			if stderrors.As(e, &pgErr) && pgErr.Code == foreignKeyViolation {
+				return errors.ErrCafeHasHistory.Error()
+			}
+			log.GetLog().Errorf("Unable to delete cafe. error: %v", e)
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -28,6 +28,7 @@ type EventRepo interface {
 	GetEventsByCafeID(ctx context.Context, cafeID int32) ([]*models.Event, error)
 	GetEventsByUserID(ctx context.Context, userID int32) ([]*models.Event, error)
 	GetAllEventsNearestStartTime(ctx context.Context, limit int32) ([]*models.Event, error)
+	GetUpcomingEvents(ctx context.Context, cafeIDs []int32, city int, limit int32) ([]*models.Event, error)
 	UpdateEvent(ctx context.Context, id int32, updateEventType UpdateEventType, value interface{}) error
 	DeleteByID(ctx context.Context, id int32) error
 }
@@ -171,6 +172,33 @@ func (c *EventRepoImp) GetAllEventsNearestStartTime(ctx context.Context, limit i
 	return events, nil
 }
 
+// GetUpcomingEvents lists events that have not started yet, soonest first. An empty cafeIDs or a zero city leaves that filter out.
+func (c *EventRepoImp) GetUpcomingEvents(ctx context.Context, cafeIDs []int32, city int, limit int32) ([]*models.Event, error) {
+	rows, err := c.postgres.Query(ctx, `SELECT e.id, e.cafe_id, cafes.name, e.name, e.description, e.start_time, e.end_time, e.price, e.capacity, e.current_attendees, e.reservable
+		FROM events e JOIN cafes ON cafes.id = e.cafe_id
+		WHERE e.start_time > NOW() AND NOT cafes.hidden
+			AND (COALESCE(cardinality($1::INTEGER[]), 0) = 0 OR e.cafe_id = ANY($1))
+			AND ($2 = 0 OR cafes.city = $2)
+		ORDER BY e.start_time ASC LIMIT $3`, cafeIDs, city, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get upcoming events. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*models.Event{}
+	for rows.Next() {
+		var event models.Event
+		err = rows.Scan(&event.ID, &event.CafeID, &event.CafeName, &event.Name, &event.Description, &event.StartTime, &event.EndTime, &event.Price, &event.Capacity, &event.CurrentAttendees, &event.Reservable)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan event. error: %v", err)
+			return nil, err
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
 func (c *EventRepoImp) UpdateEvent(ctx context.Context, id int32, updateEventType UpdateEventType, value interface{}) error {
 	columnName := string(updateEventType)
 
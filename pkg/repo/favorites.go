@@ -36,6 +36,19 @@ func NewFavoritesRepoImp(postgres *pgxpool.Pool) *FavoritesRepoImp {
 		log.GetLog().WithError(err).WithField("table", "favorites").Fatal("Unable to create table")
 	}
 
+	// favorites made before the column existed stay NULL rather than all looking like they were made today
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE favorites ADD COLUMN IF NOT EXISTS created_at TIMESTAMP`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "favorites").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE favorites ALTER COLUMN created_at SET DEFAULT NOW()`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "favorites").Fatal("Unable to alter table")
+	}
+
 	return &FavoritesRepoImp{postgres: postgres}
 }
 
@@ -0,0 +1,67 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FeedCacheRepo keeps built home feed sections in Redis. Get returns nil without an error on a miss.
+type FeedCacheRepo interface {
+	Get(ctx context.Context, key string) (*models.FeedSection, error)
+	Set(ctx context.Context, key string, section *models.FeedSection, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type FeedCacheRepoImp struct {
+	redis *redis.Client
+}
+
+func NewFeedCacheRepoImp(redis *redis.Client) *FeedCacheRepoImp {
+	return &FeedCacheRepoImp{redis: redis}
+}
+
+func (f *FeedCacheRepoImp) Get(ctx context.Context, key string) (*models.FeedSection, error) {
+	data, err := f.redis.Get(ctx, key).Bytes()
+	if stderrors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get feed section. key: %s, error: %v", key, err)
+		return nil, err
+	}
+
+	var section models.FeedSection
+	err = json.Unmarshal(data, &section)
+	if err != nil {
+		log.GetLog().Errorf("Unable to decode feed section. key: %s, error: %v", key, err)
+		return nil, err
+	}
+	return &section, nil
+}
+
+func (f *FeedCacheRepoImp) Set(ctx context.Context, key string, section *models.FeedSection, ttl time.Duration) error {
+	data, err := json.Marshal(section)
+	if err != nil {
+		return err
+	}
+
+	err = f.redis.Set(ctx, key, data, ttl).Err()
+	if err != nil {
+		log.GetLog().Errorf("Unable to cache feed section. key: %s, error: %v", key, err)
+	}
+	return err
+}
+
+func (f *FeedCacheRepoImp) Delete(ctx context.Context, keys ...string) error {
+	err := f.redis.Del(ctx, keys...).Err()
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete feed sections. error: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cast"
+)
+
+const (
+	geoIndexKey = "locations"
+	// an abandoned rebuild key cleans itself up
+	geoIndexRebuildTTL   = 10 * time.Minute
+	geoIndexRebuildBatch = 500
+)
+
+// GeoIndexRepo keeps visible cafe locations in a Redis geo set for nearest-cafe lookups.
+// Postgres stays the source of truth; Rebuild recovers from any drift.
+type GeoIndexRepo interface {
+	Add(ctx context.Context, location *models.Location) error
+	Remove(ctx context.Context, cafeID int32) error
+	Rebuild(ctx context.Context, locations []*models.Location) error
+	Nearest(ctx context.Context, lat float64, lng float64, radius float64, count int) ([]redis.GeoLocation, error)
+}
+
+type GeoIndexRepoImp struct {
+	redis *redis.Client
+}
+
+func NewGeoIndexRepoImp(redis *redis.Client) *GeoIndexRepoImp {
+	return &GeoIndexRepoImp{redis: redis}
+}
+
+func geoMember(location *models.Location) *redis.GeoLocation {
+	return &redis.GeoLocation{
+		Name:      cast.ToString(location.CafeID),
+		Longitude: location.Lng,
+		Latitude:  location.Lat,
+	}
+}
+
+func (g *GeoIndexRepoImp) Add(ctx context.Context, location *models.Location) error {
+	err := g.redis.GeoAdd(ctx, geoIndexKey, geoMember(location)).Err()
+	if err != nil {
+		log.GetLog().Errorf("Unable to add location to geo index. error: %v", err)
+	}
+	return err
+}
+
+func (g *GeoIndexRepoImp) Remove(ctx context.Context, cafeID int32) error {
+	err := g.redis.ZRem(ctx, geoIndexKey, cast.ToString(cafeID)).Err()
+	if err != nil {
+		log.GetLog().Errorf("Unable to remove location from geo index. error: %v", err)
+	}
+	return err
+}
+
+// Rebuild fills a temporary key and swaps it in with RENAME, so readers never see a partial index.
+// A location added while a rebuild is running may be dropped until the next rebuild.
+func (g *GeoIndexRepoImp) Rebuild(ctx context.Context, locations []*models.Location) error {
+	if len(locations) == 0 {
+		err := g.redis.Del(ctx, geoIndexKey).Err()
+		if err != nil {
+			log.GetLog().Errorf("Unable to clear geo index. error: %v", err)
+		}
+		return err
+	}
+
+	tmp := fmt.Sprintf("%s:rebuild:%d", geoIndexKey, rand.Int31())
+	for start := 0; start < len(locations); start += geoIndexRebuildBatch {
+		end := min(start+geoIndexRebuildBatch, len(locations))
+		members := make([]*redis.GeoLocation, 0, end-start)
+		for _, location := range locations[start:end] {
+			members = append(members, geoMember(location))
+		}
+
+		_, err := g.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.GeoAdd(ctx, tmp, members...)
+			pipe.Expire(ctx, tmp, geoIndexRebuildTTL)
+			return nil
+		})
+		if err != nil {
+			log.GetLog().Errorf("Unable to fill geo index. error: %v", err)
+			g.redis.Del(ctx, tmp)
+			return err
+		}
+	}
+
+	// RENAME carries the TTL over, so clear it in the same transaction
+	_, err := g.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Rename(ctx, tmp, geoIndexKey)
+		pipe.Persist(ctx, geoIndexKey)
+		return nil
+	})
+	if err != nil {
+		log.GetLog().Errorf("Unable to swap geo index. error: %v", err)
+		g.redis.Del(ctx, tmp)
+	}
+	return err
+}
+
+func (g *GeoIndexRepoImp) Nearest(ctx context.Context, lat float64, lng float64, radius float64, count int) ([]redis.GeoLocation, error) {
+	return g.redis.GeoRadius(ctx, geoIndexKey, lng, lat, &redis.GeoRadiusQuery{
+		Radius:      radius,
+		Unit:        "km",
+		WithCoord:   true,
+		WithDist:    true,
+		WithGeoHash: true,
+		Count:       count,
+		Sort:        "ASC",
+	}).Result()
+}
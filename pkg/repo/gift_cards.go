@@ -0,0 +1,205 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"crypto/rand"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GiftCardsRepo interface {
+	Purchase(ctx context.Context, card *models.GiftCard) error
+	GetByCode(ctx context.Context, code string) (*models.GiftCard, error)
+	GetByBuyerID(ctx context.Context, buyerID int32) ([]models.GiftCard, error)
+	RedeemToWallet(ctx context.Context, code string, userID int32) (amount int64, e error)
+}
+
+type GiftCardsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewGiftCardsRepoImp(postgres *pgxpool.Pool) *GiftCardsRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS gift_cards (
+			code TEXT PRIMARY KEY,
+			buyer_id INT,
+			cafe_id INT DEFAULT 0,
+			amount BIGINT,
+			balance BIGINT,
+			redeemed_by INT DEFAULT 0,
+			transaction_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "gift_cards").Fatal("Unable to create table")
+	}
+
+	return &GiftCardsRepoImp{postgres: postgres}
+}
+
+const (
+	giftCardCharset     = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardGroups      = 4
+	giftCardGroupLength = 4
+)
+
+// newGiftCardCode uses crypto/rand since anyone holding a code can spend it.
+func newGiftCardCode() (string, error) {
+	groups := make([]string, giftCardGroups)
+	for i := range groups {
+		group := make([]byte, giftCardGroupLength)
+		for j := range group {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(giftCardCharset))))
+			if err != nil {
+				return "", err
+			}
+			group[j] = giftCardCharset[n.Int64()]
+		}
+		groups[i] = string(group)
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (g *GiftCardsRepoImp) Purchase(ctx context.Context, card *models.GiftCard) (e error) {
+	tx, e := g.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	transaction := &models.Transaction{
+		SenderID:    card.BuyerID,
+		ReceiverID:  card.BuyerID,
+		Amount:      card.Amount,
+		Description: "gift card purchase",
+		Type:        models.GiftCardPurchase,
+	}
+	e = createTransaction(ctx, tx, transaction)
+	if e != nil {
+		return
+	}
+
+	card.Code, e = newGiftCardCode()
+	if e != nil {
+		return
+	}
+	card.Balance = card.Amount
+
+	e = tx.QueryRow(ctx, "INSERT INTO gift_cards (code, buyer_id, cafe_id, amount, balance, transaction_id) VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at", card.Code, card.BuyerID, card.CafeID, card.Amount, card.Balance, transaction.ID).Scan(&card.CreatedAt)
+	if e != nil {
+		log.GetLog().Errorf("Unable to insert gift card. error: %v", e)
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (g *GiftCardsRepoImp) GetByCode(ctx context.Context, code string) (*models.GiftCard, error) {
+	var card models.GiftCard
+	err := g.postgres.QueryRow(ctx, "SELECT code, buyer_id, cafe_id, amount, balance, redeemed_by, created_at FROM gift_cards WHERE code = $1", normalizeGiftCardCode(code)).Scan(&card.Code, &card.BuyerID, &card.CafeID, &card.Amount, &card.Balance, &card.RedeemedBy, &card.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, errors.ErrGiftCardNotFound.Error()
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get gift card by code. error: %v", err)
+		return nil, err
+	}
+	return &card, nil
+}
+
+func (g *GiftCardsRepoImp) GetByBuyerID(ctx context.Context, buyerID int32) ([]models.GiftCard, error) {
+	rows, err := g.postgres.Query(ctx, "SELECT code, buyer_id, cafe_id, amount, balance, redeemed_by, created_at FROM gift_cards WHERE buyer_id = $1 ORDER BY created_at DESC", buyerID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get gift cards by buyer id. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []models.GiftCard
+	for rows.Next() {
+		var card models.GiftCard
+		err = rows.Scan(&card.Code, &card.BuyerID, &card.CafeID, &card.Amount, &card.Balance, &card.RedeemedBy, &card.CreatedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan gift card. error: %v", err)
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, nil
+}
+
+// redeemGiftCard spends up to maxAmount of the card on behalf of userID and credits receiverID.
+// cafeID is the cafe being paid, or 0 when redeeming into a wallet.
+func redeemGiftCard(ctx context.Context, tx pgx.Tx, code string, userID int32, cafeID int32, receiverID int32, maxAmount int64, description string) (int64, string, error) {
+	var cardCafeID int32
+	var balance int64
+	err := tx.QueryRow(ctx, "SELECT cafe_id, balance FROM gift_cards WHERE code = $1 FOR UPDATE", normalizeGiftCardCode(code)).Scan(&cardCafeID, &balance)
+	if err == pgx.ErrNoRows {
+		return 0, "", errors.ErrGiftCardNotFound.Error()
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	if cardCafeID != 0 && cardCafeID != cafeID {
+		return 0, "", errors.ErrGiftCardScope.Error()
+	}
+	if balance <= 0 {
+		return 0, "", errors.ErrGiftCardEmpty.Error()
+	}
+
+	amount := min(balance, maxAmount)
+	_, err = tx.Exec(ctx, "UPDATE gift_cards SET balance = balance - $1, redeemed_by = $2 WHERE code = $3", amount, userID, normalizeGiftCardCode(code))
+	if err != nil {
+		return 0, "", err
+	}
+
+	transaction := &models.Transaction{
+		SenderID:    userID,
+		ReceiverID:  receiverID,
+		Amount:      amount,
+		Description: description,
+		Type:        models.GiftCardRedeem,
+	}
+	err = createTransaction(ctx, tx, transaction)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return amount, transaction.ID, nil
+}
+
+func (g *GiftCardsRepoImp) RedeemToWallet(ctx context.Context, code string, userID int32) (amount int64, e error) {
+	tx, e := g.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	amount, _, e = redeemGiftCard(ctx, tx, code, userID, 0, userID, math.MaxInt64, "gift card redeemed to wallet")
+	if e != nil {
+		return
+	}
+
+	e = tx.Commit(ctx)
+	return
+}
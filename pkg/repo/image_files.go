@@ -0,0 +1,38 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ImageFilesRepo looks at the files uploaded to the image service, which live in GridFS.
+type ImageFilesRepo interface {
+	Exists(ctx context.Context, imageID string) (bool, error)
+}
+
+type ImageFilesRepoImp struct {
+	files *mongo.Collection
+}
+
+func NewImageFilesRepoImp(mongoDb *mongo.Client, bucket string) *ImageFilesRepoImp {
+	return &ImageFilesRepoImp{files: mongoDb.Database("image-server").Collection(bucket + ".files")}
+}
+
+func (r *ImageFilesRepoImp) Exists(ctx context.Context, imageID string) (bool, error) {
+	id, err := primitive.ObjectIDFromHex(imageID)
+	if err != nil {
+		return false, nil
+	}
+
+	count, err := r.files.CountDocuments(ctx, bson.M{"_id": id}, options.Count().SetLimit(1))
+	if err != nil {
+		log.GetLog().Errorf("Unable to check image file existence. error: %v", err)
+		return false, err
+	}
+	return count > 0, nil
+}
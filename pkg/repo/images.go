@@ -15,6 +15,7 @@ type ImageRepo interface {
 	DeleteByID(ctx context.Context, id string) error
 	DeleteByReferenceID(ctx context.Context, referenceID int32) error
 	GetMainImage(ctx context.Context, referenceID int32) (string, error)
+	GetLatestByReferenceIDs(ctx context.Context, referenceIDs []int32) (map[int32]string, error)
 }
 
 type ImageRepoImp struct {
@@ -120,6 +121,33 @@ func (r *ImageRepoImp) GetMainImage(ctx context.Context, referenceID int32) (str
 	return imageID, nil
 }
 
+// GetLatestByReferenceIDs maps each reference to its newest image, skipping references without one.
+func (r *ImageRepoImp) GetLatestByReferenceIDs(ctx context.Context, referenceIDs []int32) (map[int32]string, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT DISTINCT ON (reference_id) reference_id, id
+		FROM images
+		WHERE reference_id = ANY($1)
+		ORDER BY reference_id, create_at DESC`, referenceIDs)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get latest images. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	images := map[int32]string{}
+	for rows.Next() {
+		var referenceID int32
+		var imageID string
+		err = rows.Scan(&referenceID, &imageID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan image. error: %v", err)
+			return nil, err
+		}
+		images[referenceID] = imageID
+	}
+	return images, rows.Err()
+}
+
 func (r *ImageRepoImp) GetOrdered(ctx context.Context, referenceID int32) ([]*models.Image, error) {
 	var images []*models.Image
 	rows, err := r.postgres.Query(ctx,
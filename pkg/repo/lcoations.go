@@ -10,6 +10,7 @@ import (
 type LocationsRepo interface {
 	SetLocation(ctx context.Context, location *models.Location) error
 	FindAll(ctx context.Context) ([]*models.Location, error)
+	FindVisible(ctx context.Context) ([]*models.Location, error)
 	GetCafeLocation(ctx context.Context, id int32) (models.Location, error)
 }
 
@@ -66,6 +67,27 @@ func (r *LocationsRepoImp) FindAll(ctx context.Context) ([]*models.Location, err
 	return locations, err
 }
 
+func (r *LocationsRepoImp) FindVisible(ctx context.Context) ([]*models.Location, error) {
+	rows, err := r.postgres.Query(ctx, "SELECT l.id, l.latitude, l.longitude FROM locations l JOIN cafes c ON c.id = l.id WHERE NOT c.hidden")
+	if err != nil {
+		log.GetLog().Errorf("Unable to get visible locations. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var locations []*models.Location
+	for rows.Next() {
+		var location models.Location
+		err = rows.Scan(&location.CafeID, &location.Lat, &location.Lng)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan location. error: %v", err)
+			return nil, err
+		}
+		locations = append(locations, &location)
+	}
+	return locations, rows.Err()
+}
+
 func (r *LocationsRepoImp) GetCafeLocation(ctx context.Context, id int32) (models.Location, error) {
 	var location models.Location
 	err := r.postgres.QueryRow(ctx, "SELECT id, latitude, longitude FROM locations where id=$1", id).Scan(&location.CafeID, &location.Lat, &location.Lng)
@@ -0,0 +1,315 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LedgerRepo interface {
+	CountWallets(ctx context.Context) (int32, error)
+	GetBalanceMismatches(ctx context.Context) ([]models.LedgerIssue, error)
+	GetIDCollisions(ctx context.Context, since time.Time) ([]models.LedgerIssue, error)
+	GetSharedTransactions(ctx context.Context) ([]models.LedgerIssue, error)
+	GetOrphanedReservations(ctx context.Context) ([]models.LedgerIssue, error)
+	CreateRun(ctx context.Context, run *models.ReconciliationRun) error
+	GetRuns(ctx context.Context, limit int32) ([]models.ReconciliationRun, error)
+	GetRunByID(ctx context.Context, id int32) (*models.ReconciliationRun, error)
+}
+
+type LedgerRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewLedgerRepoImp(postgres *pgxpool.Pool) *LedgerRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS reconciliation_runs (
+			id INTEGER PRIMARY KEY,
+			started_at TIMESTAMP,
+			finished_at TIMESTAMP,
+			wallets INTEGER,
+			mismatches INTEGER,
+			collisions INTEGER,
+			orphans INTEGER
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reconciliation_runs").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS reconciliation_issues (
+			id INTEGER PRIMARY KEY,
+			run_id INTEGER,
+			issue_type TEXT,
+			user_id INTEGER,
+			reference TEXT,
+			expected BIGINT,
+			actual BIGINT,
+			detail TEXT,
+			FOREIGN KEY (run_id) REFERENCES reconciliation_runs(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reconciliation_issues").Fatal("Unable to create table")
+	}
+
+	return &LedgerRepoImp{postgres: postgres}
+}
+
+func transactionTypesWhere(match func(models.TransactionType) bool) []int32 {
+	var types []int32
+	for _, t := range models.TransactionTypes {
+		if match(t) {
+			types = append(types, int32(t))
+		}
+	}
+	return types
+}
+
+func (r *LedgerRepoImp) CountWallets(ctx context.Context) (int32, error) {
+	var count int32
+	err := r.postgres.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&count)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count wallets. error: %v", err)
+	}
+	return count, err
+}
+
+func (r *LedgerRepoImp) GetBalanceMismatches(ctx context.Context) ([]models.LedgerIssue, error) {
+	credits := transactionTypesWhere(models.TransactionType.CreditsReceiver)
+	debits := transactionTypesWhere(models.TransactionType.DebitsSender)
+
+	rows, err := r.postgres.Query(ctx,
+		`SELECT id, expected, balance
+		FROM (
+			SELECT u.id, COALESCE(u.balance, 0) AS balance,
+				COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.receiver_id = u.id AND t.transaction_type = ANY($1)), 0)
+				- COALESCE((SELECT SUM(t.amount) FROM transactions t WHERE t.sender_id = u.id AND t.transaction_type = ANY($2)), 0) AS expected
+			FROM users u
+		) wallets
+		WHERE expected <> balance
+		ORDER BY id`, credits, debits)
+	if err != nil {
+		log.GetLog().Errorf("Unable to recompute wallets. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIssue
+	for rows.Next() {
+		issue := models.LedgerIssue{Type: models.LedgerIssueBalanceMismatch}
+		err = rows.Scan(&issue.UserID, &issue.Expected, &issue.Actual)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan wallet. error: %v", err)
+			return nil, err
+		}
+		issue.Detail = fmt.Sprintf("wallet is off by %d", issue.Actual-issue.Expected)
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (r *LedgerRepoImp) GetIDCollisions(ctx context.Context, since time.Time) ([]models.LedgerIssue, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT id, COUNT(*)
+		FROM transaction_collisions
+		WHERE created_at >= $1
+		GROUP BY id
+		ORDER BY id`, since)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get transaction id collisions. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIssue
+	for rows.Next() {
+		issue := models.LedgerIssue{Type: models.LedgerIssueTransactionCollision}
+		err = rows.Scan(&issue.Reference, &issue.Actual)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan transaction id collision. error: %v", err)
+			return nil, err
+		}
+		issue.Detail = "generated transaction id already existed"
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (r *LedgerRepoImp) GetSharedTransactions(ctx context.Context) ([]models.LedgerIssue, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT transaction_id, COUNT(*)
+		FROM (
+			SELECT transaction_id FROM reservations
+			UNION ALL
+			SELECT transaction_id FROM event_reservations
+		) paid
+		WHERE transaction_id IS NOT NULL AND transaction_id <> ''
+		GROUP BY transaction_id
+		HAVING COUNT(*) > 1
+		ORDER BY transaction_id`)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get shared transactions. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIssue
+	for rows.Next() {
+		issue := models.LedgerIssue{Type: models.LedgerIssueSharedTransaction, Expected: 1}
+		err = rows.Scan(&issue.Reference, &issue.Actual)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan shared transaction. error: %v", err)
+			return nil, err
+		}
+		issue.Detail = "one transaction pays for several reservations"
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (r *LedgerRepoImp) GetOrphanedReservations(ctx context.Context) ([]models.LedgerIssue, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT 'reservation', r.user_id, r.id::TEXT, COALESCE(r.transaction_id, ''), t.id IS NULL
+		FROM reservations r
+		LEFT JOIN transactions t ON t.id = r.transaction_id
+		WHERE t.id IS NULL OR t.sender_id <> r.user_id
+		UNION ALL
+		SELECT 'event', er.user_id, er.event_id::TEXT, COALESCE(er.transaction_id, ''), t.id IS NULL
+		FROM event_reservations er
+		LEFT JOIN transactions t ON t.id = er.transaction_id
+		WHERE t.id IS NULL OR t.sender_id <> er.user_id`)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get orphaned reservations. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var issues []models.LedgerIssue
+	for rows.Next() {
+		var kind, reservationID, transactionID string
+		var missing bool
+		issue := models.LedgerIssue{}
+		err = rows.Scan(&kind, &issue.UserID, &reservationID, &transactionID, &missing)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan orphaned reservation. error: %v", err)
+			return nil, err
+		}
+
+		issue.Type = models.LedgerIssueOrphanedReservation
+		if kind == "event" {
+			issue.Type = models.LedgerIssueOrphanedEventReservation
+		}
+		issue.Reference = reservationID
+		if missing {
+			issue.Detail = "no payment found"
+		} else {
+			issue.Detail = fmt.Sprintf("payment %s was not made by the reserving user", transactionID)
+		}
+		issues = append(issues, issue)
+	}
+	return issues, rows.Err()
+}
+
+func (r *LedgerRepoImp) CreateRun(ctx context.Context, run *models.ReconciliationRun) (e error) {
+	tx, e := r.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	run.ID = rand.Int31()
+	_, e = tx.Exec(ctx,
+		`INSERT INTO reconciliation_runs (id, started_at, finished_at, wallets, mismatches, collisions, orphans)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		run.ID, run.StartedAt, run.FinishedAt, run.Wallets, run.Mismatches, run.Collisions, run.Orphans)
+	if e != nil {
+		log.GetLog().Errorf("Unable to insert reconciliation run. error: %v", e)
+		return
+	}
+
+	for i := range run.Issues {
+		run.Issues[i].ID = rand.Int31()
+		run.Issues[i].RunID = run.ID
+		issue := run.Issues[i]
+		_, e = tx.Exec(ctx,
+			`INSERT INTO reconciliation_issues (id, run_id, issue_type, user_id, reference, expected, actual, detail)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			issue.ID, issue.RunID, issue.Type, issue.UserID, issue.Reference, issue.Expected, issue.Actual, issue.Detail)
+		if e != nil {
+			log.GetLog().Errorf("Unable to insert reconciliation issue. error: %v", e)
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *LedgerRepoImp) GetRuns(ctx context.Context, limit int32) ([]models.ReconciliationRun, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT id, started_at, finished_at, wallets, mismatches, collisions, orphans
+		FROM reconciliation_runs
+		ORDER BY started_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reconciliation runs. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.ReconciliationRun{}
+	for rows.Next() {
+		var run models.ReconciliationRun
+		err = rows.Scan(&run.ID, &run.StartedAt, &run.FinishedAt, &run.Wallets, &run.Mismatches, &run.Collisions, &run.Orphans)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan reconciliation run. error: %v", err)
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+func (r *LedgerRepoImp) GetRunByID(ctx context.Context, id int32) (*models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	err := r.postgres.QueryRow(ctx,
+		`SELECT id, started_at, finished_at, wallets, mismatches, collisions, orphans
+		FROM reconciliation_runs
+		WHERE id = $1`, id).Scan(&run.ID, &run.StartedAt, &run.FinishedAt, &run.Wallets, &run.Mismatches, &run.Collisions, &run.Orphans)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reconciliation run by id. error: %v", err)
+		return nil, err
+	}
+
+	rows, err := r.postgres.Query(ctx,
+		`SELECT id, run_id, issue_type, user_id, reference, expected, actual, detail
+		FROM reconciliation_issues
+		WHERE run_id = $1
+		ORDER BY issue_type, user_id`, id)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reconciliation issues. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Issues = []models.LedgerIssue{}
+	for rows.Next() {
+		var issue models.LedgerIssue
+		err = rows.Scan(&issue.ID, &issue.RunID, &issue.Type, &issue.UserID, &issue.Reference, &issue.Expected, &issue.Actual, &issue.Detail)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan reconciliation issue. error: %v", err)
+			return nil, err
+		}
+		run.Issues = append(run.Issues, issue)
+	}
+	return &run, rows.Err()
+}
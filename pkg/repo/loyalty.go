@@ -0,0 +1,251 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"math/rand"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type LoyaltyRepo interface {
+	GetRule(ctx context.Context, cafeID int32) (*models.LoyaltyRule, error)
+	SetRule(ctx context.Context, rule *models.LoyaltyRule) error
+	Apply(ctx context.Context, entry *models.LoyaltyEntry, stampsRequired int32) error
+	RedeemAndPay(ctx context.Context, redeemed *models.LoyaltyEntry, stampsRequired int32, giftCardCode string, cafeID int32, payment *models.Transaction) (transactionID string, e error)
+	GetCards(ctx context.Context, userID int32) ([]models.LoyaltyCard, error)
+	GetHistory(ctx context.Context, userID int32, limit int32) ([]models.LoyaltyEntry, error)
+}
+
+type LoyaltyRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewLoyaltyRepoImp(postgres *pgxpool.Pool) *LoyaltyRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS loyalty_rules (
+			cafe_id INT PRIMARY KEY,
+			earn_unit BIGINT DEFAULT 0,
+			points_per_unit BIGINT DEFAULT 0,
+			point_value BIGINT DEFAULT 0,
+			stamps_required INT DEFAULT 0,
+			stamp_reward_cap BIGINT DEFAULT 0,
+			FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "loyalty_rules").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS loyalty_cards (
+			user_id INT,
+			cafe_id INT,
+			points BIGINT DEFAULT 0,
+			stamps INT DEFAULT 0,
+			rewards INT DEFAULT 0,
+			PRIMARY KEY (user_id, cafe_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "loyalty_cards").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS loyalty_history (
+			id INT PRIMARY KEY,
+			user_id INT,
+			cafe_id INT,
+			points BIGINT,
+			stamps INT,
+			rewards INT,
+			reason TEXT,
+			reference TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "loyalty_history").Fatal("Unable to create table")
+	}
+
+	return &LoyaltyRepoImp{postgres: postgres}
+}
+
+func (l *LoyaltyRepoImp) GetRule(ctx context.Context, cafeID int32) (*models.LoyaltyRule, error) {
+	rule := models.LoyaltyRule{CafeID: cafeID}
+	err := l.postgres.QueryRow(ctx, "SELECT earn_unit, points_per_unit, point_value, stamps_required, stamp_reward_cap FROM loyalty_rules WHERE cafe_id = $1", cafeID).Scan(&rule.EarnUnit, &rule.PointsPerUnit, &rule.PointValue, &rule.StampsRequired, &rule.StampRewardCap)
+	if err == pgx.ErrNoRows {
+		// cafes without a rule simply don't take part in the program
+		return &rule, nil
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty rule. error: %v", err)
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (l *LoyaltyRepoImp) SetRule(ctx context.Context, rule *models.LoyaltyRule) error {
+	_, err := l.postgres.Exec(ctx,
+		`INSERT INTO loyalty_rules (cafe_id, earn_unit, points_per_unit, point_value, stamps_required, stamp_reward_cap)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (cafe_id) DO UPDATE SET
+			earn_unit = EXCLUDED.earn_unit,
+			points_per_unit = EXCLUDED.points_per_unit,
+			point_value = EXCLUDED.point_value,
+			stamps_required = EXCLUDED.stamps_required,
+			stamp_reward_cap = EXCLUDED.stamp_reward_cap`,
+		rule.CafeID, rule.EarnUnit, rule.PointsPerUnit, rule.PointValue, rule.StampsRequired, rule.StampRewardCap)
+	if err != nil {
+		log.GetLog().Errorf("Unable to set loyalty rule. error: %v", err)
+	}
+	return err
+}
+
+// Apply adds entry to the user's card at the cafe and records it in the history.
+// Every stampsRequired stamps are turned into one reward.
+func (l *LoyaltyRepoImp) Apply(ctx context.Context, entry *models.LoyaltyEntry, stampsRequired int32) (e error) {
+	tx, e := l.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	e = applyLoyalty(ctx, tx, entry, stampsRequired)
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+// RedeemAndPay spends the redeemed points or reward and makes the discounted payment together, so a failed
+// payment never costs the customer their points. The payment goes through the gift card when a code is given.
+func (l *LoyaltyRepoImp) RedeemAndPay(ctx context.Context, redeemed *models.LoyaltyEntry, stampsRequired int32, giftCardCode string, cafeID int32, payment *models.Transaction) (transactionID string, e error) {
+	tx, e := l.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	if redeemed != nil {
+		e = applyLoyalty(ctx, tx, redeemed, stampsRequired)
+		if e != nil {
+			return
+		}
+	}
+
+	transactionID, e = checkoutPayment(ctx, tx, giftCardCode, cafeID, payment)
+	if e != nil {
+		return
+	}
+
+	e = tx.Commit(ctx)
+	return
+}
+
+func applyLoyalty(ctx context.Context, tx pgx.Tx, entry *models.LoyaltyEntry, stampsRequired int32) error {
+	_, err := tx.Exec(ctx, "INSERT INTO loyalty_cards (user_id, cafe_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", entry.UserID, entry.CafeID)
+	if err != nil {
+		return err
+	}
+
+	var points int64
+	var stamps, rewards int32
+	err = tx.QueryRow(ctx, "SELECT points, stamps, rewards FROM loyalty_cards WHERE user_id = $1 AND cafe_id = $2 FOR UPDATE", entry.UserID, entry.CafeID).Scan(&points, &stamps, &rewards)
+	if err != nil {
+		return err
+	}
+
+	points += entry.Points
+	stamps += entry.Stamps
+	rewards += entry.Rewards
+	if points < 0 {
+		return errors.ErrNotEnoughPoints.Error()
+	}
+	if rewards < 0 {
+		return errors.ErrNoStampReward.Error()
+	}
+	if stampsRequired > 0 {
+		rewards += stamps / stampsRequired
+		stamps %= stampsRequired
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE loyalty_cards SET points = $1, stamps = $2, rewards = $3 WHERE user_id = $4 AND cafe_id = $5", points, stamps, rewards, entry.UserID, entry.CafeID)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = rand.Int31()
+	err = tx.QueryRow(ctx,
+		`INSERT INTO loyalty_history (id, user_id, cafe_id, points, stamps, rewards, reason, reference)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING created_at`,
+		entry.ID, entry.UserID, entry.CafeID, entry.Points, entry.Stamps, entry.Rewards, entry.Reason, entry.Reference).Scan(&entry.CreatedAt)
+	if err != nil {
+		log.GetLog().Errorf("Unable to insert loyalty history. error: %v", err)
+	}
+	return err
+}
+
+func (l *LoyaltyRepoImp) GetCards(ctx context.Context, userID int32) ([]models.LoyaltyCard, error) {
+	rows, err := l.postgres.Query(ctx,
+		`SELECT lc.user_id, lc.cafe_id, c.name, lc.points, lc.stamps, lc.rewards, COALESCE(lr.stamps_required, 0)
+		FROM loyalty_cards lc
+		JOIN cafes c ON c.id = lc.cafe_id
+		LEFT JOIN loyalty_rules lr ON lr.cafe_id = lc.cafe_id
+		WHERE lc.user_id = $1
+		ORDER BY lc.points DESC, lc.cafe_id`, userID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty cards. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := []models.LoyaltyCard{}
+	for rows.Next() {
+		var card models.LoyaltyCard
+		err = rows.Scan(&card.UserID, &card.CafeID, &card.CafeName, &card.Points, &card.Stamps, &card.Rewards, &card.StampsRequired)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan loyalty card. error: %v", err)
+			return nil, err
+		}
+		cards = append(cards, card)
+	}
+	return cards, rows.Err()
+}
+
+func (l *LoyaltyRepoImp) GetHistory(ctx context.Context, userID int32, limit int32) ([]models.LoyaltyEntry, error) {
+	rows, err := l.postgres.Query(ctx,
+		`SELECT id, user_id, cafe_id, points, stamps, rewards, reason, reference, created_at
+		FROM loyalty_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2`, userID, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get loyalty history. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.LoyaltyEntry{}
+	for rows.Next() {
+		var entry models.LoyaltyEntry
+		err = rows.Scan(&entry.ID, &entry.UserID, &entry.CafeID, &entry.Points, &entry.Stamps, &entry.Rewards, &entry.Reason, &entry.Reference, &entry.CreatedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan loyalty entry. error: %v", err)
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
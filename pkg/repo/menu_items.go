@@ -1,15 +1,36 @@
 package repo
 
 import (
+	"barista/pkg/errors"
 	"barista/pkg/log"
 	"barista/pkg/models"
 	"context"
+	"encoding/json"
 	"math/rand"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+const menuItemColumns = `id, cafe_id, sku, name, price, category, ingredients, options, allergens, dietary, calories, caffeine_mg,
+	hidden, sold_out_on, daily_stock, stock_day, stock_used, schedule`
+
+func scanMenuItem(row pgx.Row, item *models.MenuItem) error {
+	ingredients := ""
+	err := row.Scan(&item.ID, &item.CafeID, &item.SKU, &item.Name, &item.Price, &item.Category, &ingredients, &item.Options,
+		&item.Allergens, &item.Dietary, &item.Calories, &item.CaffeineMg,
+		&item.Hidden, &item.SoldOutOn, &item.DailyStock, &item.StockDay, &item.StockUsed, &item.Schedule)
+	if err != nil {
+		return err
+	}
+
+	item.Ingredients = append(item.Ingredients, strings.Split(ingredients, ",")...)
+	return nil
+}
+
 func init() {
 	log.GetLog().Info("Init MenuItemsRepo")
 }
@@ -21,6 +42,13 @@ type MenuItemsRepo interface {
 	UpdateName(ctx context.Context, id int32, newName string) error
 	UpdatePrice(ctx context.Context, id int32, newPrice float64) error
 	UpdateIngredients(ctx context.Context, id int32, newIngredients []string) error
+	UpdateOptions(ctx context.Context, id int32, newOptions []models.MenuOptionGroup) error
+	UpdateDietInfo(ctx context.Context, item *models.MenuItem) error
+	UpdateAvailability(ctx context.Context, availability *models.MenuAvailability, soldOutOn *time.Time) error
+	UseStock(ctx context.Context, id int32, day time.Time, quantity int32) error
+	CategorySchedules(ctx context.Context, cafeID int32) (map[models.MenuItemCategory]*models.MenuSchedule, error)
+	SetCategorySchedule(ctx context.Context, cafeID int32, category models.MenuItemCategory, schedule *models.MenuSchedule) error
+	Import(ctx context.Context, cafeID int32, items []models.MenuItem, replace bool, dryRun bool) (*models.MenuImportReport, error)
 	// UpdateImageID(ctx context.Context, id int32, newImage string) error
 	DeleteByID(ctx context.Context, id int32) error
 }
@@ -44,6 +72,40 @@ func NewMenuItemRepoImp(postgres *pgxpool.Pool) *MenuItemsRepoImp {
 		log.GetLog().WithError(err).WithField("table", "menu_items").Fatal("Unable to create table")
 	}
 
+	_, err = postgres.Exec(context.Background(), `ALTER TABLE menu_items
+		ADD COLUMN IF NOT EXISTS options JSONB DEFAULT '[]',
+		ADD COLUMN IF NOT EXISTS allergens TEXT[] DEFAULT '{}',
+		ADD COLUMN IF NOT EXISTS dietary TEXT[] DEFAULT '{}',
+		ADD COLUMN IF NOT EXISTS calories INTEGER,
+		ADD COLUMN IF NOT EXISTS caffeine_mg INTEGER,
+		ADD COLUMN IF NOT EXISTS hidden BOOLEAN DEFAULT FALSE,
+		ADD COLUMN IF NOT EXISTS sold_out_on DATE,
+		ADD COLUMN IF NOT EXISTS daily_stock INTEGER,
+		ADD COLUMN IF NOT EXISTS stock_day DATE,
+		ADD COLUMN IF NOT EXISTS stock_used INTEGER DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS schedule JSONB,
+		ADD COLUMN IF NOT EXISTS sku TEXT DEFAULT ''`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "menu_items").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(), `CREATE UNIQUE INDEX IF NOT EXISTS menu_items_cafe_id_sku ON menu_items (cafe_id, sku) WHERE sku <> ''`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "menu_items").Fatal("Unable to create index")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS menu_category_schedules (
+			cafe_id INT,
+			category TEXT,
+			schedule JSONB,
+			PRIMARY KEY (cafe_id, category),
+			FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "menu_category_schedules").Fatal("Unable to create table")
+	}
+
 	_, err = postgres.Exec(context.Background(), `INSERT INTO menu_items (id, cafe_id, name, price, category, ingredients)
 		VALUES
 		(91, 1, 'نسکافه', 50000.0, 'coffee', 'آب, دانه های قهوه'),
@@ -66,14 +128,31 @@ func NewMenuItemRepoImp(postgres *pgxpool.Pool) *MenuItemsRepoImp {
 	return &MenuItemsRepoImp{postgres: postgres}
 }
 
+// marshalOptions keeps an item without options as an empty list rather than null.
+func marshalOptions(options []models.MenuOptionGroup) ([]byte, error) {
+	if options == nil {
+		options = []models.MenuOptionGroup{}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		log.GetLog().Errorf("Unable to marshal menu item options. error: %v", err)
+	}
+	return data, err
+}
+
 func (c *MenuItemsRepoImp) Create(ctx context.Context, menuItem *models.MenuItem) (int32, error) {
 	menuItem.ID = rand.Int31()
 	ingredients := strings.Join(menuItem.Ingredients, ",")
+	options, err := marshalOptions(menuItem.Options)
+	if err != nil {
+		return 0, err
+	}
 
-	_, err := c.postgres.Exec(ctx,
-		`INSERT INTO menu_items (id, cafe_id, name, price, category, ingredients)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		menuItem.ID, menuItem.CafeID, menuItem.Name, menuItem.Price, menuItem.Category, ingredients)
+	_, err = c.postgres.Exec(ctx,
+		`INSERT INTO menu_items (id, cafe_id, name, price, category, ingredients, options, allergens, dietary, calories, caffeine_mg, sku)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		menuItem.ID, menuItem.CafeID, menuItem.Name, menuItem.Price, menuItem.Category, ingredients, options,
+		menuItem.Allergens, menuItem.Dietary, menuItem.Calories, menuItem.CaffeineMg, menuItem.SKU)
 	if err != nil {
 		log.GetLog().Errorf("Unable to insert menu item. error: %v", err)
 	}
@@ -82,7 +161,7 @@ func (c *MenuItemsRepoImp) Create(ctx context.Context, menuItem *models.MenuItem
 
 func (c *MenuItemsRepoImp) GetItemsByCafeID(ctx context.Context, cafeID int32) ([]*models.MenuItem, error) {
 	rows, err := c.postgres.Query(ctx,
-		`SELECT id, cafe_id, name, price, category, ingredients
+		`SELECT `+menuItemColumns+`
 		FROM menu_items
 		WHERE cafe_id = $1`, cafeID)
 	if err != nil {
@@ -94,15 +173,12 @@ func (c *MenuItemsRepoImp) GetItemsByCafeID(ctx context.Context, cafeID int32) (
 	var menu []*models.MenuItem
 	for rows.Next() {
 		var item models.MenuItem
-		ingredients := ""
-		err := rows.Scan(&item.ID, &item.CafeID, &item.Name, &item.Price, &item.Category, &ingredients)
+		err := scanMenuItem(rows, &item)
 		if err != nil {
 			log.GetLog().Errorf("Unable to scan menu item. error: %v", err)
 			return nil, err
 		}
 
-		item.Ingredients = append(item.Ingredients, strings.Split(ingredients, ",")...)
-
 		menu = append(menu, &item)
 	}
 
@@ -111,18 +187,15 @@ func (c *MenuItemsRepoImp) GetItemsByCafeID(ctx context.Context, cafeID int32) (
 
 func (c *MenuItemsRepoImp) GetByID(ctx context.Context, id int32) (*models.MenuItem, error) {
 	var item models.MenuItem
-	ingredients := ""
-	err := c.postgres.QueryRow(ctx,
-		`SELECT id, cafe_id, name, price, category, ingredients
+	err := scanMenuItem(c.postgres.QueryRow(ctx,
+		`SELECT `+menuItemColumns+`
 		FROM menu_items
-		WHERE id = $1`, id).Scan(&item.ID, &item.CafeID, &item.Name, &item.Price, &item.Category, &ingredients)
+		WHERE id = $1`, id), &item)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get menu item by id. error: %v", err)
 		return nil, err
 	}
 
-	item.Ingredients = append(item.Ingredients, strings.Split(ingredients, ",")...)
-
 	return &item, err
 }
 
@@ -169,6 +242,210 @@ func (c *MenuItemsRepoImp) UpdateIngredients(ctx context.Context, id int32, newI
 	return err
 }
 
+func (c *MenuItemsRepoImp) UpdateOptions(ctx context.Context, id int32, newOptions []models.MenuOptionGroup) error {
+	options, err := marshalOptions(newOptions)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.postgres.Exec(ctx,
+		`UPDATE menu_items
+		SET options = $1
+		WHERE id = $2`,
+		options, id)
+	if err != nil {
+		log.GetLog().Errorf("Unable to update menu items options. error: %v", err)
+		return err
+	}
+
+	return err
+}
+
+func (c *MenuItemsRepoImp) UpdateDietInfo(ctx context.Context, item *models.MenuItem) error {
+	_, err := c.postgres.Exec(ctx,
+		`UPDATE menu_items
+		SET allergens = $1, dietary = $2, calories = $3, caffeine_mg = $4
+		WHERE id = $5`,
+		item.Allergens, item.Dietary, item.Calories, item.CaffeineMg, item.ID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to update menu items diet info. error: %v", err)
+		return err
+	}
+
+	return err
+}
+
+// UpdateAvailability replaces every availability setting of the item.
+func (c *MenuItemsRepoImp) UpdateAvailability(ctx context.Context, availability *models.MenuAvailability, soldOutOn *time.Time) error {
+	_, err := c.postgres.Exec(ctx,
+		`UPDATE menu_items
+		SET hidden = $1, sold_out_on = $2, daily_stock = $3, schedule = $4
+		WHERE id = $5`,
+		availability.Hidden, soldOutOn, availability.DailyStock, availability.Schedule, availability.ItemID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to update menu items availability. error: %v", err)
+		return err
+	}
+
+	return err
+}
+
+// UseStock takes quantity from the item's stock for day, which starts again from the daily count on a new day.
+// Items without a daily count are never short.
+func (c *MenuItemsRepoImp) UseStock(ctx context.Context, id int32, day time.Time, quantity int32) error {
+	tag, err := c.postgres.Exec(ctx,
+		`UPDATE menu_items
+		SET stock_used = CASE WHEN stock_day = $2 THEN stock_used ELSE 0 END + $3, stock_day = $2
+		WHERE id = $1 AND (daily_stock IS NULL OR CASE WHEN stock_day = $2 THEN stock_used ELSE 0 END + $3 <= daily_stock)`,
+		id, day, quantity)
+	if err != nil {
+		log.GetLog().Errorf("Unable to use menu item stock. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrMenuItemSoldOut.Error()
+	}
+
+	return err
+}
+
+func (c *MenuItemsRepoImp) CategorySchedules(ctx context.Context, cafeID int32) (map[models.MenuItemCategory]*models.MenuSchedule, error) {
+	rows, err := c.postgres.Query(ctx,
+		`SELECT category, schedule
+		FROM menu_category_schedules
+		WHERE cafe_id = $1`, cafeID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get menu category schedules. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := map[models.MenuItemCategory]*models.MenuSchedule{}
+	for rows.Next() {
+		var category models.MenuItemCategory
+		var schedule models.MenuSchedule
+		err := rows.Scan(&category, &schedule)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan menu category schedule. error: %v", err)
+			return nil, err
+		}
+		schedules[category] = &schedule
+	}
+
+	return schedules, rows.Err()
+}
+
+// SetCategorySchedule limits every item of a category to the schedule, or lifts the limit when it is nil.
+func (c *MenuItemsRepoImp) SetCategorySchedule(ctx context.Context, cafeID int32, category models.MenuItemCategory, schedule *models.MenuSchedule) error {
+	var err error
+	if schedule == nil {
+		_, err = c.postgres.Exec(ctx,
+			`DELETE FROM menu_category_schedules
+			WHERE cafe_id = $1 AND category = $2`, cafeID, category)
+	} else {
+		_, err = c.postgres.Exec(ctx,
+			`INSERT INTO menu_category_schedules (cafe_id, category, schedule)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (cafe_id, category) DO UPDATE SET schedule = $3`, cafeID, category, schedule)
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to set menu category schedule. error: %v", err)
+	}
+
+	return err
+}
+
+// Import writes a whole menu in one transaction. Items whose SKU is already on the menu are updated and
+// keep their availability; the rest are added. A replace also removes every item the import does not
+// have, along with its images. A dry run rolls back, so the counts are exactly what the import would do.
+func (c *MenuItemsRepoImp) Import(ctx context.Context, cafeID int32, items []models.MenuItem, replace bool, dryRun bool) (report *models.MenuImportReport, e error) {
+	tx, e := c.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to import menu. error: %v", e)
+		}
+		if e != nil || dryRun {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	rows, e := tx.Query(ctx, "SELECT id, sku FROM menu_items WHERE cafe_id = $1 FOR UPDATE", cafeID)
+	if e != nil {
+		return
+	}
+	skus := map[string]int32{}
+	for rows.Next() {
+		var id int32
+		var sku string
+		e = rows.Scan(&id, &sku)
+		if e != nil {
+			rows.Close()
+			return
+		}
+		if sku != "" {
+			skus[sku] = id
+		}
+	}
+	rows.Close()
+	if e = rows.Err(); e != nil {
+		return
+	}
+
+	report = &models.MenuImportReport{Total: len(items)}
+	kept := []int32{}
+	for _, item := range items {
+		var options []byte
+		options, e = marshalOptions(item.Options)
+		if e != nil {
+			return
+		}
+		ingredients := strings.Join(item.Ingredients, ",")
+
+		if id, ok := skus[item.SKU]; ok && item.SKU != "" {
+			_, e = tx.Exec(ctx,
+				`UPDATE menu_items
+				SET name = $1, price = $2, category = $3, ingredients = $4, options = $5, allergens = $6, dietary = $7, calories = $8, caffeine_mg = $9
+				WHERE id = $10`,
+				item.Name, item.Price, item.Category, ingredients, options, item.Allergens, item.Dietary, item.Calories, item.CaffeineMg, id)
+			kept = append(kept, id)
+			report.Updated++
+		} else {
+			id := rand.Int31()
+			_, e = tx.Exec(ctx,
+				`INSERT INTO menu_items (id, cafe_id, name, price, category, ingredients, options, allergens, dietary, calories, caffeine_mg, sku)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+				id, cafeID, item.Name, item.Price, item.Category, ingredients, options, item.Allergens, item.Dietary, item.Calories, item.CaffeineMg, item.SKU)
+			kept = append(kept, id)
+			report.Created++
+		}
+		if e != nil {
+			return
+		}
+	}
+
+	if replace {
+		var tag pgconn.CommandTag
+		_, e = tx.Exec(ctx, "DELETE FROM images WHERE reference_id IN (SELECT id FROM menu_items WHERE cafe_id = $1 AND NOT (id = ANY($2)))", cafeID, kept)
+		if e != nil {
+			return
+		}
+		tag, e = tx.Exec(ctx, "DELETE FROM menu_items WHERE cafe_id = $1 AND NOT (id = ANY($2))", cafeID, kept)
+		if e != nil {
+			return
+		}
+		report.Deleted = int(tag.RowsAffected())
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	report.Applied = true
+	return report, tx.Commit(ctx)
+}
+
 // func (c *MenuItemsRepoImp) UpdateImageID(ctx context.Context, id int32, newImage string) error {
 // 	_, err := c.postgres.Exec(ctx,
 // 		`UPDATE menu_items
@@ -0,0 +1,99 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"math/rand"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type NotificationsRepo interface {
+	Create(ctx context.Context, notification *models.Notification) error
+	GetByUserID(ctx context.Context, userID int32, limit int) ([]models.Notification, error)
+	UnreadCount(ctx context.Context, userID int32) (int, error)
+	MarkRead(ctx context.Context, userID int32, ids []int32) error
+}
+
+type NotificationsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewNotificationsRepoImp(postgres *pgxpool.Pool) *NotificationsRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id INTEGER PRIMARY KEY,
+			user_id INTEGER,
+			kind TEXT,
+			title TEXT,
+			body TEXT,
+			reference_id INTEGER,
+			read BOOLEAN DEFAULT FALSE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "notifications").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(), `CREATE INDEX IF NOT EXISTS notifications_user_id_created_at ON notifications (user_id, created_at)`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "notifications").Fatal("Unable to create index")
+	}
+
+	return &NotificationsRepoImp{postgres: postgres}
+}
+
+func (n *NotificationsRepoImp) Create(ctx context.Context, notification *models.Notification) error {
+	notification.ID = rand.Int31()
+	err := n.postgres.QueryRow(ctx, `INSERT INTO notifications (id, user_id, kind, title, body, reference_id)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`,
+		notification.ID, notification.UserID, notification.Kind, notification.Title, notification.Body, notification.ReferenceID).Scan(&notification.CreatedAt)
+	if err != nil {
+		log.GetLog().Errorf("Unable to create notification. error: %v", err)
+	}
+	return err
+}
+
+func (n *NotificationsRepoImp) GetByUserID(ctx context.Context, userID int32, limit int) ([]models.Notification, error) {
+	rows, err := n.postgres.Query(ctx, `SELECT id, user_id, kind, title, body, reference_id, read, created_at
+		FROM notifications WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get notifications. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var notification models.Notification
+		err = rows.Scan(&notification.ID, &notification.UserID, &notification.Kind, &notification.Title, &notification.Body,
+			&notification.ReferenceID, &notification.Read, &notification.CreatedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan notification. error: %v", err)
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (n *NotificationsRepoImp) UnreadCount(ctx context.Context, userID int32) (int, error) {
+	var count int
+	err := n.postgres.QueryRow(ctx, "SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND NOT read", userID).Scan(&count)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count unread notifications. error: %v", err)
+	}
+	return count, err
+}
+
+// MarkRead marks the given notifications of the user as read, or all of them when ids is empty.
+func (n *NotificationsRepoImp) MarkRead(ctx context.Context, userID int32, ids []int32) error {
+	_, err := n.postgres.Exec(ctx, `UPDATE notifications SET read = TRUE
+		WHERE user_id = $1 AND NOT read AND (COALESCE(cardinality($2::INTEGER[]), 0) = 0 OR id = ANY($2))`, userID, ids)
+	if err != nil {
+		log.GetLog().Errorf("Unable to mark notifications read. error: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// a visitor opening the same profile again within this window is not counted twice
+const viewDedupWindow = time.Hour
+
+// PopularityRepo records profile views and keeps the periodically computed popularity of every cafe.
+type PopularityRepo interface {
+	RecordView(ctx context.Context, cafeID int32, userID int32, visitor string) error
+	Signals(ctx context.Context, halfLife time.Duration, trendHalfLife time.Duration, trendWindow time.Duration) ([]models.PopularitySignals, float64, error)
+	Save(ctx context.Context, scores []models.CafePopularity) error
+	Top(ctx context.Context, city int, limit int) ([]int32, error)
+	Trending(ctx context.Context, city int, limit int) ([]int32, error)
+}
+
+type PopularityRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewPopularityRepoImp(postgres *pgxpool.Pool) *PopularityRepoImp {
+	for table, query := range map[string]string{
+		"cafe_views": `CREATE TABLE IF NOT EXISTS cafe_views (
+				id INTEGER PRIMARY KEY,
+				cafe_id INTEGER,
+				user_id INTEGER,
+				viewed_at TIMESTAMP,
+				FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+			);`,
+		"cafe_popularity": `CREATE TABLE IF NOT EXISTS cafe_popularity (
+				cafe_id INTEGER PRIMARY KEY,
+				rating FLOAT,
+				popularity FLOAT,
+				trending FLOAT,
+				updated_at TIMESTAMP
+			);`,
+	} {
+		_, err := postgres.Exec(context.Background(), query)
+		if err != nil {
+			log.GetLog().WithError(err).WithField("table", table).Fatal("Unable to create table")
+		}
+	}
+
+	_, err := postgres.Exec(context.Background(), `ALTER TABLE cafe_views ADD COLUMN IF NOT EXISTS visitor TEXT`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafe_views").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(), `CREATE INDEX IF NOT EXISTS cafe_views_cafe_id_viewed_at ON cafe_views (cafe_id, viewed_at)`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafe_views").Fatal("Unable to create index")
+	}
+
+	return &PopularityRepoImp{postgres: postgres}
+}
+
+// RecordView counts a view of the cafe's profile. Signed-in users are told apart by id and anonymous
+// visitors by the opaque visitor key, so reloading the page does not add up.
+func (r *PopularityRepoImp) RecordView(ctx context.Context, cafeID int32, userID int32, visitor string) error {
+	var user, key any
+	if userID != 0 {
+		user = userID
+	} else {
+		key = visitor
+	}
+
+	_, err := r.postgres.Exec(ctx, `INSERT INTO cafe_views (id, cafe_id, user_id, visitor, viewed_at)
+		SELECT $1, $2, $3::INTEGER, $4::TEXT, NOW()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM cafe_views WHERE cafe_id = $2 AND viewed_at > NOW() - $5::INTERVAL
+			AND (user_id = $3 OR ($3::INTEGER IS NULL AND visitor = $4)))`,
+		rand.Int31(), cafeID, user, key, viewDedupWindow)
+	if err != nil {
+		log.GetLog().Errorf("Unable to record cafe view. error: %v", err)
+	}
+	return err
+}
+
+// decayed sums the activity of a cafe in a table, each event weighted by half for every half-life of age.
+// Reservations are dated by their visit, so those still ahead count in full.
+func decayed(table string, column string, halfLife string, window string, condition string) string {
+	query := fmt.Sprintf(`(SELECT COALESCE(SUM(exp(-ln(2) * GREATEST(EXTRACT(EPOCH FROM NOW() - t.%[2]s), 0) / %[3]s)), 0)
+		FROM %[1]s t WHERE t.cafe_id = cafes.id AND t.%[2]s IS NOT NULL%[4]s`, table, column, halfLife, condition)
+	if window != "" {
+		query += fmt.Sprintf(" AND t.%s > NOW() - %s", column, window)
+	}
+	return query + ")"
+}
+
+func activity(halfLife string, window string) string {
+	return decayed("reservations", "start_time", halfLife, window, " AND t.status <> 'released'") + ", " +
+		decayed("favorites", "created_at", halfLife, window, "") + ", " +
+		decayed("reviews", "created_at", halfLife, window, " AND t.comment <> '' AND t.status IN ('published', 'approved')") + ", " +
+		decayed("cafe_views", "viewed_at", halfLife, window, "")
+}
+
+// Signals returns the rating totals and decayed activity of every visible cafe, along with the mean of all ratings.
+func (r *PopularityRepoImp) Signals(ctx context.Context, halfLife time.Duration, trendHalfLife time.Duration, trendWindow time.Duration) ([]models.PopularitySignals, float64, error) {
+	var mean float64
+	err := r.postgres.QueryRow(ctx, "SELECT "+ratingMeanExpr).Scan(&mean)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get mean rating. error: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.postgres.Query(ctx, `SELECT cafes.id,
+			COALESCE(s.rating_sum, 0)::FLOAT, COALESCE(s.ratings, 0),
+			`+activity("$1::FLOAT", "")+`,
+			`+activity("$2::FLOAT", "$3::INTERVAL")+`
+		FROM cafes LEFT JOIN cafe_rating_stats s ON s.cafe_id = cafes.id WHERE NOT cafes.hidden`,
+		halfLife.Seconds(), trendHalfLife.Seconds(), trendWindow)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get popularity signals. error: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var signals []models.PopularitySignals
+	for rows.Next() {
+		var s models.PopularitySignals
+		err = rows.Scan(&s.CafeID, &s.RatingSum, &s.RatingCount,
+			&s.Recent.Reservations, &s.Recent.Favorites, &s.Recent.Comments, &s.Recent.Views,
+			&s.Trend.Reservations, &s.Trend.Favorites, &s.Trend.Comments, &s.Trend.Views)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan popularity signals. error: %v", err)
+			return nil, 0, err
+		}
+		signals = append(signals, s)
+	}
+	return signals, mean, rows.Err()
+}
+
+// Save replaces all scores at once; cafes hidden since the last run drop out of the table.
+func (r *PopularityRepoImp) Save(ctx context.Context, scores []models.CafePopularity) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to save popularity. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	_, e = tx.Exec(ctx, "DELETE FROM cafe_popularity")
+	if e != nil {
+		return
+	}
+
+	now := time.Now()
+	_, e = tx.CopyFrom(ctx, pgx.Identifier{"cafe_popularity"}, []string{"cafe_id", "rating", "popularity", "trending", "updated_at"},
+		pgx.CopyFromSlice(len(scores), func(i int) ([]any, error) {
+			return []any{scores[i].CafeID, scores[i].Rating, scores[i].Popularity, scores[i].Trending, now}, nil
+		}))
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *PopularityRepoImp) ranked(ctx context.Context, order string, city int, limit int) ([]int32, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT p.cafe_id FROM cafe_popularity p JOIN cafes c ON c.id = p.cafe_id
+		WHERE NOT c.hidden AND ($1 = 0 OR c.city = $1)
+		ORDER BY `+order+`, p.cafe_id LIMIT $2`, city, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get ranked cafes. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int32{}
+	for rows.Next() {
+		var id int32
+		err = rows.Scan(&id)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan ranked cafe. error: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Top lists the most popular cafes; a zero city means everywhere.
+func (r *PopularityRepoImp) Top(ctx context.Context, city int, limit int) ([]int32, error) {
+	return r.ranked(ctx, "p.popularity DESC", city, limit)
+}
+
+// Trending lists the cafes with the most activity lately, falling back on overall popularity when things are quiet.
+func (r *PopularityRepoImp) Trending(ctx context.Context, city int, limit int) ([]int32, error) {
+	return r.ranked(ctx, "p.trending DESC, p.popularity DESC", city, limit)
+}
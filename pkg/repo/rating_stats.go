@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// cafe_rating_stats keeps running totals of every cafe's ratings and cafe_rating_days the ratings given
+// each day, so profiles and rankings never aggregate the reviews table. They are changed in the same
+// transaction as the review.
+var ratingStatsColumns = []string{
+	"ratings", "rating_sum", "stars_1", "stars_2", "stars_3", "stars_4", "stars_5",
+	"coffee_sum", "coffee_count", "service_sum", "service_count", "ambience_sum", "ambience_count", "value_sum", "value_count",
+}
+
+// the mean of all ratings, used as the prior of the Bayesian averages
+const ratingMeanExpr = `(SELECT COALESCE(SUM(rating_sum)::FLOAT / NULLIF(SUM(ratings), 0), 0) FROM cafe_rating_stats)`
+
+// bayesianRatingExpr is utils.BayesianAverage of the cafe_rating_stats row s, for ranking by rating in SQL.
+var bayesianRatingExpr = fmt.Sprintf(`(%[1]d * %[2]s + s.rating_sum) / (%[1]d + s.ratings)`, models.RatingPriorWeight, ratingMeanExpr)
+
+func createRatingStatsTables(ctx context.Context, postgres *pgxpool.Pool) (e error) {
+	tx, e := postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	var exists bool
+	e = tx.QueryRow(ctx, `SELECT to_regclass('cafe_rating_stats') IS NOT NULL`).Scan(&exists)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS cafe_rating_stats (
+				cafe_id INTEGER PRIMARY KEY,
+				`+strings.Join(ratingStatsColumns, " INTEGER DEFAULT 0,\n\t\t\t\t")+` INTEGER DEFAULT 0,
+				FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+			);`)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS cafe_rating_days (
+				cafe_id INTEGER,
+				day DATE,
+				ratings INTEGER DEFAULT 0,
+				rating_sum INTEGER DEFAULT 0,
+				PRIMARY KEY (cafe_id, day),
+				FOREIGN KEY (cafe_id) REFERENCES cafes(id) ON DELETE CASCADE
+			);`)
+	if e != nil {
+		return
+	}
+
+	// the first time around, start from the reviews already there
+	if !exists {
+		_, e = tx.Exec(ctx, `INSERT INTO cafe_rating_stats (cafe_id, `+strings.Join(ratingStatsColumns, ", ")+`)
+			SELECT cafe_id, COUNT(rating), COALESCE(SUM(rating), 0),
+				COUNT(*) FILTER (WHERE rating = 1), COUNT(*) FILTER (WHERE rating = 2), COUNT(*) FILTER (WHERE rating = 3),
+				COUNT(*) FILTER (WHERE rating = 4), COUNT(*) FILTER (WHERE rating = 5),
+				COALESCE(SUM(coffee), 0), COUNT(coffee), COALESCE(SUM(service), 0), COUNT(service),
+				COALESCE(SUM(ambience), 0), COUNT(ambience), COALESCE(SUM(value), 0), COUNT(value)
+			FROM reviews GROUP BY cafe_id`)
+		if e != nil {
+			return
+		}
+
+		_, e = tx.Exec(ctx, `INSERT INTO cafe_rating_days (cafe_id, day, ratings, rating_sum)
+			SELECT cafe_id, rated_on, COUNT(*), SUM(rating) FROM reviews
+			WHERE rating IS NOT NULL AND rated_on IS NOT NULL GROUP BY cafe_id, rated_on`)
+		if e != nil {
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ratingSnapshot is the part of a review that counts towards the cafe's rating stats.
+type ratingSnapshot struct {
+	cafeID                           int32
+	rating                           *int32
+	coffee, service, ambience, value *int32
+	ratedOn                          *time.Time
+}
+
+// snapshotRating locks the user's review of the cafe for the rest of the transaction and returns its
+// ratings, or nil when there is no review. The advisory lock also covers a review that does not exist
+// yet, so two first writes cannot both count as new.
+func snapshotRating(ctx context.Context, tx pgx.Tx, userID int32, cafeID int32) (*ratingSnapshot, error) {
+	_, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock($1, $2)", userID, cafeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var s ratingSnapshot
+	err = tx.QueryRow(ctx, `SELECT cafe_id, rating, coffee, service, ambience, value, rated_on
+		FROM reviews WHERE user_id = $1 AND cafe_id = $2 FOR UPDATE`, userID, cafeID).
+		Scan(&s.cafeID, &s.rating, &s.coffee, &s.service, &s.ambience, &s.value, &s.ratedOn)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func scoreDelta(score *int32, sign int32) (int32, int32) {
+	if score == nil {
+		return 0, 0
+	}
+	return sign * *score, sign
+}
+
+// applyRating adds a review's ratings to the cafe's stats, or takes them out with a sign of -1.
+func applyRating(ctx context.Context, tx pgx.Tx, s *ratingSnapshot, sign int32) error {
+	if s == nil {
+		return nil
+	}
+
+	delta := make([]any, 0, len(ratingStatsColumns)+1)
+	delta = append(delta, s.cafeID)
+	sum, count := scoreDelta(s.rating, sign)
+	delta = append(delta, count, sum)
+	for star := int32(1); star <= 5; star++ {
+		if s.rating != nil && *s.rating == star {
+			delta = append(delta, sign)
+		} else {
+			delta = append(delta, int32(0))
+		}
+	}
+	for _, score := range []*int32{s.coffee, s.service, s.ambience, s.value} {
+		sum, count := scoreDelta(score, sign)
+		delta = append(delta, sum, count)
+	}
+
+	placeholders := make([]string, len(delta))
+	updates := make([]string, len(ratingStatsColumns))
+	for i := range delta {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	for i, column := range ratingStatsColumns {
+		updates[i] = fmt.Sprintf("%[1]s = cafe_rating_stats.%[1]s + EXCLUDED.%[1]s", column)
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO cafe_rating_stats (cafe_id, `+strings.Join(ratingStatsColumns, ", ")+`)
+		VALUES (`+strings.Join(placeholders, ", ")+`)
+		ON CONFLICT (cafe_id) DO UPDATE SET `+strings.Join(updates, ", "), delta...)
+	if err != nil || s.rating == nil || s.ratedOn == nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO cafe_rating_days (cafe_id, day, ratings, rating_sum) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (cafe_id, day) DO UPDATE SET ratings = cafe_rating_days.ratings + EXCLUDED.ratings,
+			rating_sum = cafe_rating_days.rating_sum + EXCLUDED.rating_sum`, s.cafeID, s.ratedOn, sign, sign*(*s.rating))
+	return err
+}
+
+func average(sum int64, count int32) *float64 {
+	if count == 0 {
+		return nil
+	}
+	avg := float64(sum) / float64(count)
+	return &avg
+}
+
+// RatingStats returns a cafe's rating summary with its weekly trend since the given time.
+func (r *ReviewsRepoImp) RatingStats(ctx context.Context, cafeID int32, since time.Time) (*models.RatingStats, error) {
+	stats := models.RatingStats{Trend: []models.RatingTrendPoint{}}
+	var sum int64
+	var aspectSums [4]int64
+	var aspectCounts [4]int32
+	err := r.postgres.QueryRow(ctx, `SELECT `+strings.Join(ratingStatsColumns, ", ")+` FROM cafe_rating_stats WHERE cafe_id = $1`, cafeID).Scan(
+		&stats.Count, &sum, &stats.Histogram[0], &stats.Histogram[1], &stats.Histogram[2], &stats.Histogram[3], &stats.Histogram[4],
+		&aspectSums[0], &aspectCounts[0], &aspectSums[1], &aspectCounts[1], &aspectSums[2], &aspectCounts[2], &aspectSums[3], &aspectCounts[3])
+	if err == pgx.ErrNoRows {
+		return &stats, nil
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get rating stats. error: %v", err)
+		return nil, err
+	}
+
+	if avg := average(sum, stats.Count); avg != nil {
+		stats.Average = *avg
+	}
+	stats.Aspects = models.AspectAverages{
+		Coffee:   average(aspectSums[0], aspectCounts[0]),
+		Service:  average(aspectSums[1], aspectCounts[1]),
+		Ambience: average(aspectSums[2], aspectCounts[2]),
+		Value:    average(aspectSums[3], aspectCounts[3]),
+	}
+
+	rows, err := r.postgres.Query(ctx, `SELECT date_trunc('week', day)::DATE, SUM(ratings)::INTEGER, SUM(rating_sum)
+		FROM cafe_rating_days WHERE cafe_id = $1 AND day >= $2::DATE
+		GROUP BY 1 HAVING SUM(ratings) > 0 ORDER BY 1`, cafeID, since)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get rating trend. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var point models.RatingTrendPoint
+		var weekSum int64
+		err = rows.Scan(&point.Week, &point.Count, &weekSum)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan rating trend. error: %v", err)
+			return nil, err
+		}
+		point.Average = *average(weekSum, point.Count)
+		stats.Trend = append(stats.Trend, point)
+	}
+	return &stats, rows.Err()
+}
@@ -0,0 +1,211 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"math/rand"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// RecommendationsRepo stores the model built by the offline recommendation job and serves it.
+type RecommendationsRepo interface {
+	CafeFeatures(ctx context.Context) ([]models.CafeFeatures, error)
+	Interactions(ctx context.Context) ([]models.Interaction, error)
+	Save(ctx context.Context, similarities []models.CafeSimilarity, recommendations []models.UserRecommendation, run *models.RecommendationRun) error
+	SimilarCafes(ctx context.Context, cafeID int32, limit int) ([]int32, error)
+	UserRecommendations(ctx context.Context, userID int32, limit int) ([]int32, error)
+	LatestRun(ctx context.Context) (*models.RecommendationRun, error)
+}
+
+type RecommendationsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewRecommendationsRepoImp(postgres *pgxpool.Pool) *RecommendationsRepoImp {
+	for table, query := range map[string]string{
+		"cafe_similarities": `CREATE TABLE IF NOT EXISTS cafe_similarities (
+				cafe_id INTEGER,
+				similar_id INTEGER,
+				score FLOAT,
+				PRIMARY KEY (cafe_id, similar_id)
+			);`,
+		"user_recommendations": `CREATE TABLE IF NOT EXISTS user_recommendations (
+				user_id INTEGER,
+				cafe_id INTEGER,
+				score FLOAT,
+				PRIMARY KEY (user_id, cafe_id)
+			);`,
+		"recommendation_runs": `CREATE TABLE IF NOT EXISTS recommendation_runs (
+				id INTEGER PRIMARY KEY,
+				created_at TIMESTAMP,
+				cafes INTEGER,
+				users INTEGER,
+				held_out INTEGER,
+				k INTEGER,
+				precision_at_k FLOAT,
+				recall_at_k FLOAT,
+				coverage FLOAT
+			);`,
+	} {
+		_, err := postgres.Exec(context.Background(), query)
+		if err != nil {
+			log.GetLog().WithError(err).WithField("table", table).Fatal("Unable to create table")
+		}
+	}
+
+	return &RecommendationsRepoImp{postgres: postgres}
+}
+
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (r *RecommendationsRepoImp) CafeFeatures(ctx context.Context) ([]models.CafeFeatures, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT id, COALESCE(city, 0), COALESCE(categories, ''), COALESCE(amenities, ''), `+cafePriceExpr+`
+		FROM cafes WHERE NOT hidden ORDER BY id`)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe features. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var features []models.CafeFeatures
+	for rows.Next() {
+		var cafe models.CafeFeatures
+		var categories, amenities string
+		err = rows.Scan(&cafe.ID, &cafe.City, &categories, &amenities, &cafe.Price)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan cafe features. error: %v", err)
+			return nil, err
+		}
+		cafe.Categories, cafe.Amenities = splitList(categories), splitList(amenities)
+		features = append(features, cafe)
+	}
+	return features, rows.Err()
+}
+
+func (r *RecommendationsRepoImp) Interactions(ctx context.Context) ([]models.Interaction, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT COALESCE(r.user_id, f.user_id), COALESCE(r.cafe_id, f.cafe_id), COALESCE(r.rating, 0), f.user_id IS NOT NULL
+		FROM (SELECT user_id, cafe_id, rating FROM reviews WHERE rating IS NOT NULL) r FULL OUTER JOIN (SELECT DISTINCT user_id, cafe_id FROM favorites) f ON f.user_id = r.user_id AND f.cafe_id = r.cafe_id`)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get interactions. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var interactions []models.Interaction
+	for rows.Next() {
+		var interaction models.Interaction
+		err = rows.Scan(&interaction.UserID, &interaction.CafeID, &interaction.Rating, &interaction.Favorite)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan interaction. error: %v", err)
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions, rows.Err()
+}
+
+// Save swaps the whole model in one transaction, so readers never see half of a rebuild.
+func (r *RecommendationsRepoImp) Save(ctx context.Context, similarities []models.CafeSimilarity, recommendations []models.UserRecommendation, run *models.RecommendationRun) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to save recommendations. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	_, e = tx.Exec(ctx, "DELETE FROM cafe_similarities")
+	if e != nil {
+		return
+	}
+	_, e = tx.Exec(ctx, "DELETE FROM user_recommendations")
+	if e != nil {
+		return
+	}
+
+	_, e = tx.CopyFrom(ctx, pgx.Identifier{"cafe_similarities"}, []string{"cafe_id", "similar_id", "score"},
+		pgx.CopyFromSlice(len(similarities), func(i int) ([]any, error) {
+			return []any{similarities[i].CafeID, similarities[i].SimilarID, similarities[i].Score}, nil
+		}))
+	if e != nil {
+		return
+	}
+	_, e = tx.CopyFrom(ctx, pgx.Identifier{"user_recommendations"}, []string{"user_id", "cafe_id", "score"},
+		pgx.CopyFromSlice(len(recommendations), func(i int) ([]any, error) {
+			return []any{recommendations[i].UserID, recommendations[i].CafeID, recommendations[i].Score}, nil
+		}))
+	if e != nil {
+		return
+	}
+
+	run.ID = rand.Int31()
+	_, e = tx.Exec(ctx, `INSERT INTO recommendation_runs (id, created_at, cafes, users, held_out, k, precision_at_k, recall_at_k, coverage)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		run.ID, run.CreatedAt, run.Cafes, run.Users, run.HeldOut, run.K, run.PrecisionAtK, run.RecallAtK, run.Coverage)
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *RecommendationsRepoImp) cafeIDs(ctx context.Context, query string, args ...any) ([]int32, error) {
+	rows, err := r.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get recommended cafes. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int32{}
+	for rows.Next() {
+		var id int32
+		err = rows.Scan(&id)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan recommended cafe. error: %v", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *RecommendationsRepoImp) SimilarCafes(ctx context.Context, cafeID int32, limit int) ([]int32, error) {
+	return r.cafeIDs(ctx, `SELECT s.similar_id FROM cafe_similarities s JOIN cafes c ON c.id = s.similar_id
+		WHERE s.cafe_id = $1 AND NOT c.hidden
+		ORDER BY s.score DESC, s.similar_id LIMIT $2`, cafeID, limit)
+}
+
+// UserRecommendations skips cafes favorited since the last rebuild.
+func (r *RecommendationsRepoImp) UserRecommendations(ctx context.Context, userID int32, limit int) ([]int32, error) {
+	return r.cafeIDs(ctx, `SELECT u.cafe_id FROM user_recommendations u JOIN cafes c ON c.id = u.cafe_id
+		WHERE u.user_id = $1 AND NOT c.hidden
+			AND NOT EXISTS (SELECT 1 FROM favorites f WHERE f.user_id = u.user_id AND f.cafe_id = u.cafe_id)
+		ORDER BY u.score DESC, u.cafe_id LIMIT $2`, userID, limit)
+}
+
+func (r *RecommendationsRepoImp) LatestRun(ctx context.Context) (*models.RecommendationRun, error) {
+	var run models.RecommendationRun
+	err := r.postgres.QueryRow(ctx, `SELECT id, created_at, cafes, users, held_out, k, precision_at_k, recall_at_k, coverage
+		FROM recommendation_runs ORDER BY created_at DESC LIMIT 1`).Scan(&run.ID, &run.CreatedAt, &run.Cafes, &run.Users, &run.HeldOut, &run.K, &run.PrecisionAtK, &run.RecallAtK, &run.Coverage)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get latest recommendation run. error: %v", err)
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,261 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReferralsRepo interface {
+	GetOrCreateCode(ctx context.Context, userID int32) (string, error)
+	GetReferrerByCode(ctx context.Context, code string) (int32, error)
+	Create(ctx context.Context, referral *models.Referral) error
+	MarkVerified(ctx context.Context, email string) error
+	Reward(ctx context.Context, referredID int32, amount int64) (bool, error)
+	GetStats(ctx context.Context, userID int32) (*models.ReferralStats, error)
+}
+
+type ReferralsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewReferralsRepoImp(postgres *pgxpool.Pool) *ReferralsRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS referral_codes (
+			user_id INT PRIMARY KEY,
+			code TEXT UNIQUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "referral_codes").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS referrals (
+			referred_id INT PRIMARY KEY,
+			referrer_id INT,
+			status TEXT,
+			reason TEXT DEFAULT '',
+			reward BIGINT DEFAULT 0,
+			referrer_transaction_id TEXT,
+			referred_transaction_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			rewarded_at TIMESTAMP,
+			FOREIGN KEY (referred_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (referrer_id) REFERENCES users(id) ON DELETE CASCADE
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "referrals").Fatal("Unable to create table")
+	}
+
+	// mailbox_key folds the aliases one mailbox can sign up with: case, "+tag" suffixes and, for gmail, dots
+	_, err = postgres.Exec(context.Background(),
+		`CREATE OR REPLACE FUNCTION mailbox_key(email TEXT) RETURNS TEXT
+		LANGUAGE SQL IMMUTABLE AS $$
+			SELECT CASE WHEN domain IN ('gmail.com', 'googlemail.com')
+				THEN replace(local, '.', '') || '@gmail.com'
+				ELSE local || '@' || domain END
+			FROM (SELECT regexp_replace(split_part(lower(btrim(COALESCE(email, ''))), '@', 1), '\+.*$', '') AS local,
+				split_part(lower(btrim(COALESCE(email, ''))), '@', 2) AS domain) e
+		$$`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("function", "mailbox_key").Fatal("Unable to create function")
+	}
+
+	return &ReferralsRepoImp{postgres: postgres}
+}
+
+const (
+	referralCodeCharset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	referralCodeLength  = 8
+	referralCodeRetries = 5
+)
+
+// duplicateIdentityQuery finds another account that is likely the same person as the referred user ($1):
+// their other role account, a mailbox alias, or a shared phone, national id or bank account. The referrer
+// ($2) is checked the same way, so inviting oneself from a second account is caught too.
+const duplicateIdentityQuery = `
+	SELECT CASE
+		WHEN b.id = $2 AND mailbox_key(b.email) = mailbox_key(a.email) THEN 'self referral'
+		WHEN lower(b.email) = lower(a.email) THEN 'duplicate email'
+		WHEN mailbox_key(b.email) = mailbox_key(a.email) THEN 'email alias'
+		WHEN b.phone = a.phone THEN 'duplicate phone'
+		WHEN COALESCE(a.extra_info->>'national_id', '') <> '' AND b.extra_info->>'national_id' = a.extra_info->>'national_id' THEN 'duplicate national id'
+		ELSE 'duplicate bank account' END
+	FROM users a
+	JOIN users b ON b.id <> a.id
+		AND (mailbox_key(b.email) = mailbox_key(a.email)
+			OR b.phone = a.phone
+			OR (COALESCE(a.extra_info->>'national_id', '') <> '' AND b.extra_info->>'national_id' = a.extra_info->>'national_id')
+			OR (COALESCE(a.extra_info->>'bank_account', '') <> '' AND b.extra_info->>'bank_account' = a.extra_info->>'bank_account'))
+	WHERE a.id = $1
+	ORDER BY b.id = $2 DESC
+	LIMIT 1`
+
+func newReferralCode() string {
+	code := make([]byte, referralCodeLength)
+	for i := range code {
+		code[i] = referralCodeCharset[rand.Intn(len(referralCodeCharset))]
+	}
+	return string(code)
+}
+
+func (r *ReferralsRepoImp) GetOrCreateCode(ctx context.Context, userID int32) (string, error) {
+	var code string
+	err := r.postgres.QueryRow(ctx, "SELECT code FROM referral_codes WHERE user_id = $1", userID).Scan(&code)
+	if err == nil {
+		return code, nil
+	}
+	if err != pgx.ErrNoRows {
+		log.GetLog().Errorf("Unable to get referral code. error: %v", err)
+		return "", err
+	}
+
+	for i := 0; i < referralCodeRetries; i++ {
+		_, err = r.postgres.Exec(ctx, "INSERT INTO referral_codes (user_id, code) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, newReferralCode())
+		if err != nil {
+			log.GetLog().Errorf("Unable to insert referral code. error: %v", err)
+			return "", err
+		}
+
+		// either our code went in, someone else created this user's code first, or the code was taken and we try again
+		err = r.postgres.QueryRow(ctx, "SELECT code FROM referral_codes WHERE user_id = $1", userID).Scan(&code)
+		if err == nil {
+			return code, nil
+		}
+		if err != pgx.ErrNoRows {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("unable to generate unique referral code after %d attempts", referralCodeRetries)
+}
+
+func (r *ReferralsRepoImp) GetReferrerByCode(ctx context.Context, code string) (int32, error) {
+	var userID int32
+	err := r.postgres.QueryRow(ctx, "SELECT user_id FROM referral_codes WHERE code = $1", strings.ToUpper(strings.TrimSpace(code))).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return 0, errors.ErrReferralCodeInvalid.Error()
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get referrer by code. error: %v", err)
+	}
+	return userID, err
+}
+
+func (r *ReferralsRepoImp) Create(ctx context.Context, referral *models.Referral) error {
+	referral.Status = models.ReferralPending
+	err := r.postgres.QueryRow(ctx, duplicateIdentityQuery, referral.ReferredID, referral.ReferrerID).Scan(&referral.Reason)
+	if err == nil {
+		referral.Status = models.ReferralRejected
+	} else if err != pgx.ErrNoRows {
+		log.GetLog().Errorf("Unable to check referral identity. error: %v", err)
+		return err
+	}
+
+	err = r.postgres.QueryRow(ctx, "INSERT INTO referrals (referred_id, referrer_id, status, reason) VALUES ($1, $2, $3, $4) RETURNING created_at", referral.ReferredID, referral.ReferrerID, referral.Status, referral.Reason).Scan(&referral.CreatedAt)
+	if err != nil {
+		log.GetLog().Errorf("Unable to insert referral. error: %v", err)
+	}
+	return err
+}
+
+func (r *ReferralsRepoImp) MarkVerified(ctx context.Context, email string) error {
+	_, err := r.postgres.Exec(ctx, "UPDATE referrals SET status = $1 WHERE status = $2 AND referred_id IN (SELECT id FROM users WHERE email = $3 AND is_verified)", models.ReferralVerified, models.ReferralPending, email)
+	if err != nil {
+		log.GetLog().Errorf("Unable to mark referral verified. error: %v", err)
+	}
+	return err
+}
+
+// Reward pays both sides of a verified referral once. Identities are checked again since phone numbers can change after signup.
+func (r *ReferralsRepoImp) Reward(ctx context.Context, referredID int32, amount int64) (rewarded bool, e error) {
+	tx, e := r.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	var referrerID int32
+	e = tx.QueryRow(ctx, "SELECT referrer_id FROM referrals WHERE referred_id = $1 AND status = $2 FOR UPDATE", referredID, models.ReferralVerified).Scan(&referrerID)
+	if e == pgx.ErrNoRows {
+		e = nil
+		tx.Rollback(ctx)
+		return
+	}
+	if e != nil {
+		return
+	}
+
+	var reason string
+	e = tx.QueryRow(ctx, duplicateIdentityQuery, referredID, referrerID).Scan(&reason)
+	if e == nil {
+		_, e = tx.Exec(ctx, "UPDATE referrals SET status = $1, reason = $2 WHERE referred_id = $3", models.ReferralRejected, reason, referredID)
+		if e != nil {
+			return
+		}
+		e = tx.Commit(ctx)
+		return
+	}
+	if e != pgx.ErrNoRows {
+		return
+	}
+
+	referrerReward := &models.Transaction{SenderID: referrerID, ReceiverID: referrerID, Amount: amount, Description: "referral reward", Type: models.ReferralReward}
+	e = createTransaction(ctx, tx, referrerReward)
+	if e != nil {
+		return
+	}
+	referredReward := &models.Transaction{SenderID: referredID, ReceiverID: referredID, Amount: amount, Description: "referral reward", Type: models.ReferralReward}
+	e = createTransaction(ctx, tx, referredReward)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx,
+		`UPDATE referrals SET status = $1, reward = $2, referrer_transaction_id = $3, referred_transaction_id = $4, rewarded_at = NOW()
+		WHERE referred_id = $5`,
+		models.ReferralRewarded, amount, referrerReward.ID, referredReward.ID, referredID)
+	if e != nil {
+		return
+	}
+
+	e = tx.Commit(ctx)
+	return e == nil, e
+}
+
+// GetStats leaves Code empty for users who do not have a code yet.
+func (r *ReferralsRepoImp) GetStats(ctx context.Context, userID int32) (*models.ReferralStats, error) {
+	var stats models.ReferralStats
+	err := r.postgres.QueryRow(ctx, "SELECT code FROM referral_codes WHERE user_id = $1", userID).Scan(&stats.Code)
+	if err != nil && err != pgx.ErrNoRows {
+		log.GetLog().Errorf("Unable to get referral code. error: %v", err)
+		return nil, err
+	}
+
+	err = r.postgres.QueryRow(ctx,
+		`SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE status IN ($2, $3)),
+			COUNT(*) FILTER (WHERE status = $3),
+			COUNT(*) FILTER (WHERE status = $4),
+			COALESCE(SUM(reward), 0)
+		FROM referrals
+		WHERE referrer_id = $1`,
+		userID, models.ReferralVerified, models.ReferralRewarded, models.ReferralRejected).Scan(&stats.Invited, &stats.Verified, &stats.Rewarded, &stats.Rejected, &stats.Earned)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get referral stats. error: %v", err)
+		return nil, err
+	}
+	return &stats, nil
+}
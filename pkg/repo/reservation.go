@@ -1,12 +1,14 @@
 package repo
 
 import (
+	"barista/pkg/errors"
 	"barista/pkg/log"
 	"barista/pkg/models"
 	"context"
 	"math/rand"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -20,6 +22,12 @@ type ReservationRepo interface {
 	CountByTime(ctx context.Context, cafeID int32, startTime time.Time, endTime time.Time) (int32, error)
 	GetFullyBookedDays(ctx context.Context, cafeID int32, startDate time.Time, openingTime int8, closingTime int8) ([]time.Time, error)
 	GetAvailableTimeSlots(ctx context.Context, cafeID int32, day time.Time, cafeCapacity int32, openingTime int8, closingTime int8) ([]map[string]interface{}, error)
+	CreateGroup(ctx context.Context, reservation *models.Reservation, shares []models.ReservationShare, receiverID int32) error
+	GetShares(ctx context.Context, reservationID int32) ([]models.ReservationShare, error)
+	GetPendingSharesByUserID(ctx context.Context, userID int32) ([]models.ReservationShare, error)
+	PayShare(ctx context.Context, reservationID int32, userID int32, receiverID int32) (confirmed bool, e error)
+	ReleaseExpired(ctx context.Context) (int, error)
+	GetTransactions(ctx context.Context, reservationID int32) ([]models.Transaction, error)
 }
 
 type ReservationRepoImp struct {
@@ -43,15 +51,49 @@ func NewReservationRepoImp(postgres *pgxpool.Pool) *ReservationRepoImp {
 	if err != nil {
 		log.GetLog().WithError(err).WithField("table", "reservations").Fatal("Unable to create table")
 	}
+
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE reservations
+			ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'confirmed',
+			ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS payout_transaction_id TEXT;`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reservations").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS reservation_shares (
+				id INTEGER PRIMARY KEY,
+				reservation_id INTEGER,
+				user_id INTEGER,
+				amount BIGINT,
+				status TEXT,
+				transaction_id TEXT,
+				refund_transaction_id TEXT,
+				FOREIGN KEY (reservation_id) REFERENCES reservations(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				UNIQUE (reservation_id, user_id)
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reservation_shares").Fatal("Unable to create table")
+	}
+
 	return &ReservationRepoImp{postgres: postgres}
 }
 
+const reservationColumns = "id, cafe_id, user_id, COALESCE(transaction_id, ''), start_time, end_time, people, status, expires_at"
+
+func scanReservation(row pgx.Row, reservation *models.Reservation) error {
+	return row.Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People, &reservation.Status, &reservation.ExpiresAt)
+}
+
 func (r *ReservationRepoImp) Create(ctx context.Context, reservation *models.Reservation) error {
 	reservation.ID = rand.Int31()
+	reservation.Status = models.ReservationConfirmed
 	_, err := r.postgres.Exec(ctx,
-		`INSERT INTO reservations (id, cafe_id, user_id, transaction_id, start_time, end_time, people)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		reservation.ID, reservation.CafeID, reservation.UserID, reservation.TransactionID, reservation.StartTime, reservation.EndTime, reservation.People)
+		`INSERT INTO reservations (id, cafe_id, user_id, transaction_id, start_time, end_time, people, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		reservation.ID, reservation.CafeID, reservation.UserID, reservation.TransactionID, reservation.StartTime, reservation.EndTime, reservation.People, reservation.Status)
 	if err != nil {
 		log.GetLog().Errorf("Unable to insert reservation. error: %v", err)
 	}
@@ -60,7 +102,7 @@ func (r *ReservationRepoImp) Create(ctx context.Context, reservation *models.Res
 
 func (r *ReservationRepoImp) GetByID(ctx context.Context, id int32) (*models.Reservation, error) {
 	var reservation models.Reservation
-	err := r.postgres.QueryRow(ctx, "SELECT id, cafe_id, user_id, transaction_id, start_time, end_time, people FROM reservations WHERE id = $1", id).Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People)
+	err := scanReservation(r.postgres.QueryRow(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE id = $1", id), &reservation)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get reservation by id. error: %v", err)
 	}
@@ -68,7 +110,7 @@ func (r *ReservationRepoImp) GetByID(ctx context.Context, id int32) (*models.Res
 }
 
 func (r *ReservationRepoImp) GetByUserID(ctx context.Context, userID int32) (*[]models.Reservation, error) {
-	rows, err := r.postgres.Query(ctx, "SELECT id, cafe_id, user_id, transaction_id, start_time, end_time, people FROM reservations WHERE user_id = $1", userID)
+	rows, err := r.postgres.Query(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE user_id = $1", userID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get reservations by user id. error: %v", err)
 	}
@@ -77,7 +119,7 @@ func (r *ReservationRepoImp) GetByUserID(ctx context.Context, userID int32) (*[]
 	var reservations []models.Reservation
 	for rows.Next() {
 		var reservation models.Reservation
-		err = rows.Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People)
+		err = scanReservation(rows, &reservation)
 		if err != nil {
 			log.GetLog().Errorf("Unable to scan reservation. error: %v", err)
 			break
@@ -88,7 +130,7 @@ func (r *ReservationRepoImp) GetByUserID(ctx context.Context, userID int32) (*[]
 }
 
 func (r *ReservationRepoImp) GetByCafeID(ctx context.Context, cafeID int32) ([]*models.Reservation, error) {
-	rows, err := r.postgres.Query(ctx, "SELECT id, cafe_id, user_id, transaction_id, start_time, end_time, people FROM reservations WHERE cafe_id = $1", cafeID)
+	rows, err := r.postgres.Query(ctx, "SELECT "+reservationColumns+" FROM reservations WHERE cafe_id = $1", cafeID)
 	if err != nil {
 		log.GetLog().Errorf("Unable to get reservations by cafe id. error: %v", err)
 	}
@@ -97,7 +139,7 @@ func (r *ReservationRepoImp) GetByCafeID(ctx context.Context, cafeID int32) ([]*
 	var reservations []*models.Reservation
 	for rows.Next() {
 		var reservation models.Reservation
-		err = rows.Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People)
+		err = scanReservation(rows, &reservation)
 		if err != nil {
 			log.GetLog().Errorf("Unable to scan reservation. error: %v", err)
 			break
@@ -115,6 +157,7 @@ func (r *ReservationRepoImp) CountByTime(ctx context.Context, cafeID int32, star
 		WHERE cafe_id = $1
 		AND start_time <= $2
 		AND end_time >= $3
+		AND status <> 'released'
 	`
 	err := r.postgres.QueryRow(ctx, query, cafeID, startTime, endTime).Scan(&totalPeople)
 	if err != nil {
@@ -128,7 +171,7 @@ func (r *ReservationRepoImp) GetFullyBookedDays(ctx context.Context, cafeID int3
 	query := `
         SELECT date_trunc('day', start_time) AS day
         FROM reservations
-        WHERE cafe_id = $1 AND start_time >= $2 AND
+        WHERE cafe_id = $1 AND start_time >= $2 AND status <> 'released' AND
               EXTRACT(HOUR FROM start_time) >= $3 AND EXTRACT(HOUR FROM start_time) < $4
         GROUP BY day
         HAVING COUNT(*) >= ($4 - $3)
@@ -166,7 +209,7 @@ func (r *ReservationRepoImp) GetAvailableTimeSlots(ctx context.Context, cafeID i
             time_slots.slot_time,
             ($4 - COALESCE(SUM(reservations.people), 0)) AS remaining_capacity
         FROM time_slots
-        LEFT JOIN reservations ON time_slots.slot_time = reservations.start_time AND reservations.cafe_id = $1
+        LEFT JOIN reservations ON time_slots.slot_time = reservations.start_time AND reservations.cafe_id = $1 AND reservations.status <> 'released'
         GROUP BY time_slots.slot_time
         HAVING ($4 - COALESCE(SUM(reservations.people), 0)) > 0
 		ORDER BY time_slots.slot_time
@@ -196,9 +239,10 @@ func (r *ReservationRepoImp) GetAvailableTimeSlots(ctx context.Context, cafeID i
 
 func (r *ReservationRepoImp) GetByDateUserID(ctx context.Context, userID int32, startTime time.Time, endTime time.Time) (*[]models.Reservation, error) {
 	rows, err := r.postgres.Query(ctx,
-		`SELECT *
+		`SELECT `+reservationColumns+`
 		FROM reservations
-		WHERE user_id = $1
+		WHERE (user_id = $1 OR id IN (SELECT reservation_id FROM reservation_shares WHERE user_id = $1))
+		AND status <> 'released'
 		AND start_time >= $2
 		AND end_time <= $3
 		ORDER BY start_time, end_time`,
@@ -211,7 +255,7 @@ func (r *ReservationRepoImp) GetByDateUserID(ctx context.Context, userID int32,
 	var reservations []models.Reservation
 	for rows.Next() {
 		reservation := models.Reservation{}
-		err = rows.Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People)
+		err = scanReservation(rows, &reservation)
 		if err != nil {
 			log.GetLog().Errorf("Unable to get reservation by date. error: %v", err)
 			return nil, err
@@ -224,9 +268,10 @@ func (r *ReservationRepoImp) GetByDateUserID(ctx context.Context, userID int32,
 
 func (r *ReservationRepoImp) GetByDateCafeID(ctx context.Context, cafeID int32, startTime time.Time, endTime time.Time) (*[]models.Reservation, error) {
 	rows, err := r.postgres.Query(ctx,
-		`SELECT *
+		`SELECT `+reservationColumns+`
 		FROM reservations
 		WHERE cafe_id = $1
+		AND status <> 'released'
 		AND start_time >= $2
 		AND end_time <= $3
 		ORDER BY start_time, end_time`,
@@ -239,7 +284,7 @@ func (r *ReservationRepoImp) GetByDateCafeID(ctx context.Context, cafeID int32,
 	var reservations []models.Reservation
 	for rows.Next() {
 		reservation := models.Reservation{}
-		err = rows.Scan(&reservation.ID, &reservation.CafeID, &reservation.UserID, &reservation.TransactionID, &reservation.StartTime, &reservation.EndTime, &reservation.People)
+		err = scanReservation(rows, &reservation)
 		if err != nil {
 			log.GetLog().Errorf("Unable to get reservation by date & cafe id. error: %v", err)
 			return nil, err
@@ -249,3 +294,303 @@ func (r *ReservationRepoImp) GetByDateCafeID(ctx context.Context, cafeID int32,
 
 	return &reservations, nil
 }
+
+func (r *ReservationRepoImp) CreateGroup(ctx context.Context, reservation *models.Reservation, shares []models.ReservationShare, receiverID int32) (e error) {
+	tx, e := r.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	reservation.ID = rand.Int31()
+	reservation.Status = models.ReservationPending
+
+	// the organizer pays their share up front, which also backs the reservation's own transaction
+	for i := range shares {
+		shares[i].ID = rand.Int31()
+		shares[i].ReservationID = reservation.ID
+		shares[i].Status = models.SharePending
+		if shares[i].UserID != reservation.UserID {
+			continue
+		}
+
+		hold := &models.Transaction{
+			SenderID:    reservation.UserID,
+			ReceiverID:  receiverID,
+			Amount:      shares[i].Amount,
+			Description: "group reservation share",
+			Type:        models.ReservationHold,
+		}
+		e = createTransaction(ctx, tx, hold)
+		if e != nil {
+			return
+		}
+		shares[i].Status = models.SharePaid
+		shares[i].TransactionID = hold.ID
+		reservation.TransactionID = hold.ID
+	}
+
+	_, e = tx.Exec(ctx,
+		`INSERT INTO reservations (id, cafe_id, user_id, transaction_id, start_time, end_time, people, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		reservation.ID, reservation.CafeID, reservation.UserID, reservation.TransactionID, reservation.StartTime, reservation.EndTime, reservation.People, reservation.Status, reservation.ExpiresAt)
+	if e != nil {
+		log.GetLog().Errorf("Unable to insert group reservation. error: %v", e)
+		return
+	}
+
+	for _, share := range shares {
+		_, e = tx.Exec(ctx,
+			`INSERT INTO reservation_shares (id, reservation_id, user_id, amount, status, transaction_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`,
+			share.ID, share.ReservationID, share.UserID, share.Amount, share.Status, share.TransactionID)
+		if e != nil {
+			log.GetLog().Errorf("Unable to insert reservation share. error: %v", e)
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *ReservationRepoImp) getShares(ctx context.Context, query string, args ...any) ([]models.ReservationShare, error) {
+	rows, err := r.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reservation shares. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []models.ReservationShare
+	for rows.Next() {
+		var share models.ReservationShare
+		err = rows.Scan(&share.ID, &share.ReservationID, &share.UserID, &share.Amount, &share.Status, &share.TransactionID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan reservation share. error: %v", err)
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+func (r *ReservationRepoImp) GetShares(ctx context.Context, reservationID int32) ([]models.ReservationShare, error) {
+	return r.getShares(ctx, "SELECT id, reservation_id, user_id, amount, status, COALESCE(transaction_id, '') FROM reservation_shares WHERE reservation_id = $1 ORDER BY id", reservationID)
+}
+
+func (r *ReservationRepoImp) GetPendingSharesByUserID(ctx context.Context, userID int32) ([]models.ReservationShare, error) {
+	return r.getShares(ctx,
+		`SELECT s.id, s.reservation_id, s.user_id, s.amount, s.status, COALESCE(s.transaction_id, '')
+		FROM reservation_shares s
+		JOIN reservations r ON r.id = s.reservation_id
+		WHERE s.user_id = $1 AND s.status = $2 AND r.status = $3 AND r.expires_at > NOW()
+		ORDER BY r.expires_at`, userID, models.SharePending, models.ReservationPending)
+}
+
+func (r *ReservationRepoImp) PayShare(ctx context.Context, reservationID int32, userID int32, receiverID int32) (confirmed bool, e error) {
+	tx, e := r.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	// lock the reservation first so the release job and the last payer can't race each other
+	var organizerID int32
+	e = tx.QueryRow(ctx, "SELECT user_id FROM reservations WHERE id = $1 AND status = $2 AND expires_at > NOW() FOR UPDATE", reservationID, models.ReservationPending).Scan(&organizerID)
+	if e == pgx.ErrNoRows {
+		e = errors.ErrReservationExpired.Error()
+		return
+	}
+	if e != nil {
+		return
+	}
+
+	var shareID int32
+	var amount int64
+	e = tx.QueryRow(ctx, "SELECT id, amount FROM reservation_shares WHERE reservation_id = $1 AND user_id = $2 AND status = $3", reservationID, userID, models.SharePending).Scan(&shareID, &amount)
+	if e == pgx.ErrNoRows {
+		e = errors.ErrShareInvalid.Error()
+		return
+	}
+	if e != nil {
+		return
+	}
+
+	hold := &models.Transaction{
+		SenderID:    userID,
+		ReceiverID:  receiverID,
+		Amount:      amount,
+		Description: "group reservation share",
+		Type:        models.ReservationHold,
+	}
+	e = createTransaction(ctx, tx, hold)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, "UPDATE reservation_shares SET status = $1, transaction_id = $2 WHERE id = $3", models.SharePaid, hold.ID, shareID)
+	if e != nil {
+		return
+	}
+
+	var pending int32
+	var total int64
+	e = tx.QueryRow(ctx, "SELECT COUNT(*) FILTER (WHERE status = $2), COALESCE(SUM(amount) FILTER (WHERE status = $3), 0) FROM reservation_shares WHERE reservation_id = $1", reservationID, models.SharePending, models.SharePaid).Scan(&pending, &total)
+	if e != nil {
+		return
+	}
+
+	if pending == 0 {
+		payout := &models.Transaction{
+			SenderID:    organizerID,
+			ReceiverID:  receiverID,
+			Amount:      total,
+			Description: "cafe reservation transaction",
+			Type:        models.ReservationPayout,
+		}
+		e = createTransaction(ctx, tx, payout)
+		if e != nil {
+			return
+		}
+
+		_, e = tx.Exec(ctx, "UPDATE reservations SET status = $1, expires_at = NULL, payout_transaction_id = $2 WHERE id = $3", models.ReservationConfirmed, payout.ID, reservationID)
+		if e != nil {
+			return
+		}
+		confirmed = true
+	}
+
+	e = tx.Commit(ctx)
+	return
+}
+
+func (r *ReservationRepoImp) ReleaseExpired(ctx context.Context) (int, error) {
+	rows, err := r.postgres.Query(ctx, "SELECT id FROM reservations WHERE status = $1 AND expires_at <= NOW()", models.ReservationPending)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get expired reservations. error: %v", err)
+		return 0, err
+	}
+	var ids []int32
+	for rows.Next() {
+		var id int32
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	released := 0
+	for _, id := range ids {
+		ok, err := r.release(ctx, id)
+		if err != nil {
+			log.GetLog().Errorf("Unable to release reservation. id: %d, error: %v", id, err)
+			continue
+		}
+		if ok {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// release refunds every paid share of an expired group reservation and frees its seats.
+func (r *ReservationRepoImp) release(ctx context.Context, reservationID int32) (released bool, e error) {
+	tx, e := r.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	tag, e := tx.Exec(ctx, "UPDATE reservations SET status = $1 WHERE id = $2 AND status = $3 AND expires_at <= NOW()", models.ReservationReleased, reservationID, models.ReservationPending)
+	if e != nil || tag.RowsAffected() == 0 {
+		return
+	}
+
+	rows, e := tx.Query(ctx, "SELECT id, user_id, amount FROM reservation_shares WHERE reservation_id = $1 AND status = $2", reservationID, models.SharePaid)
+	if e != nil {
+		return
+	}
+	var paid []models.ReservationShare
+	for rows.Next() {
+		var share models.ReservationShare
+		if e = rows.Scan(&share.ID, &share.UserID, &share.Amount); e != nil {
+			rows.Close()
+			return
+		}
+		paid = append(paid, share)
+	}
+	rows.Close()
+
+	for _, share := range paid {
+		refund := &models.Transaction{
+			SenderID:    share.UserID,
+			ReceiverID:  share.UserID,
+			Amount:      share.Amount,
+			Description: "group reservation expired",
+			Type:        models.Refund,
+		}
+		e = createTransaction(ctx, tx, refund)
+		if e != nil {
+			return
+		}
+		_, e = tx.Exec(ctx, "UPDATE reservation_shares SET status = $1, refund_transaction_id = $2 WHERE id = $3", models.ShareRefunded, refund.ID, share.ID)
+		if e != nil {
+			return
+		}
+	}
+
+	_, e = tx.Exec(ctx, "UPDATE reservation_shares SET status = $1 WHERE reservation_id = $2 AND status = $3", models.ShareCancelled, reservationID, models.SharePending)
+	if e != nil {
+		return
+	}
+
+	e = tx.Commit(ctx)
+	return e == nil, e
+}
+
+func (r *ReservationRepoImp) GetTransactions(ctx context.Context, reservationID int32) ([]models.Transaction, error) {
+	rows, err := r.postgres.Query(ctx,
+		`SELECT t.id, t.sender_id, t.receiver_id, t.amount, t.description, t.transaction_type, t.created_at
+		FROM transactions t
+		WHERE t.id IN (
+			SELECT transaction_id FROM reservations WHERE id = $1
+			UNION
+			SELECT payout_transaction_id FROM reservations WHERE id = $1
+			UNION
+			SELECT transaction_id FROM reservation_shares WHERE reservation_id = $1
+			UNION
+			SELECT refund_transaction_id FROM reservation_shares WHERE reservation_id = $1
+		)
+		ORDER BY t.created_at`, reservationID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reservation transactions. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var transaction models.Transaction
+		err = rows.Scan(&transaction.ID, &transaction.SenderID, &transaction.ReceiverID, &transaction.Amount, &transaction.Description, &transaction.Type, &transaction.CreatedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan transaction. error: %v", err)
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, rows.Err()
+}
@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const reviewPhotoColumns = "p.image_id, p.review_id, p.cafe_id, p.user_id, COALESCE(u.first_name, ''), p.status, p.created_at"
+
+const reviewPhotoFrom = " FROM review_photos p LEFT JOIN users u ON u.id = p.user_id "
+
+type ReviewPhotosRepo interface {
+	Add(ctx context.Context, photo *models.ReviewPhoto, limit int) error
+	Exists(ctx context.Context, imageID string) (bool, error)
+	Delete(ctx context.Context, imageID string, userID int32) error
+	Gallery(ctx context.Context, cafeID int32, limit int) ([]models.ReviewPhoto, error)
+	Queue(ctx context.Context, limit int, offset int) ([]models.ReviewPhoto, int, error)
+	SetStatus(ctx context.Context, imageID string, status models.ReviewStatus) error
+}
+
+type ReviewPhotosRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewReviewPhotosRepoImp(postgres *pgxpool.Pool) *ReviewPhotosRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS review_photos (
+				image_id TEXT PRIMARY KEY,
+				review_id INTEGER,
+				cafe_id INTEGER,
+				user_id INTEGER,
+				status TEXT DEFAULT 'pending',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "review_photos").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(), `CREATE INDEX IF NOT EXISTS review_photos_cafe_id_created_at ON review_photos (cafe_id, created_at)`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "review_photos").Fatal("Unable to create index")
+	}
+
+	return &ReviewPhotosRepoImp{postgres: postgres}
+}
+
+func scanReviewPhotos(rows pgx.Rows) ([]models.ReviewPhoto, error) {
+	defer rows.Close()
+
+	photos := []models.ReviewPhoto{}
+	for rows.Next() {
+		var photo models.ReviewPhoto
+		err := rows.Scan(&photo.ImageID, &photo.ReviewID, &photo.CafeID, &photo.UserID, &photo.FirstName, &photo.Status, &photo.CreatedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan review photo. error: %v", err)
+			return nil, err
+		}
+		photos = append(photos, photo)
+	}
+	return photos, rows.Err()
+}
+
+// Add attaches a photo to a review unless the review already has limit photos. The review row is locked
+// while counting, so two uploads at once cannot both take the last place.
+func (r *ReviewPhotosRepoImp) Add(ctx context.Context, photo *models.ReviewPhoto, limit int) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	var reviewID int32
+	e = tx.QueryRow(ctx, "SELECT id FROM reviews WHERE id = $1 FOR UPDATE", photo.ReviewID).Scan(&reviewID)
+	if e == pgx.ErrNoRows {
+		return errors.ErrReviewNotFound.Error()
+	}
+	if e != nil {
+		log.GetLog().Errorf("Unable to lock review for photo. error: %v", e)
+		return
+	}
+
+	var count int
+	e = tx.QueryRow(ctx, "SELECT COUNT(*) FROM review_photos WHERE review_id = $1", photo.ReviewID).Scan(&count)
+	if e != nil {
+		log.GetLog().Errorf("Unable to count review photos. error: %v", e)
+		return
+	}
+	if count >= limit {
+		return errors.ErrReviewPhotoLimit.Error()
+	}
+
+	tag, e := tx.Exec(ctx, `INSERT INTO review_photos (image_id, review_id, cafe_id, user_id, status)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (image_id) DO NOTHING`,
+		photo.ImageID, photo.ReviewID, photo.CafeID, photo.UserID, models.ReviewPending)
+	if e != nil {
+		log.GetLog().Errorf("Unable to add review photo. error: %v", e)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrImageInUse.Error()
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *ReviewPhotosRepoImp) Exists(ctx context.Context, imageID string) (bool, error) {
+	var exists bool
+	err := r.postgres.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM review_photos WHERE image_id = $1)", imageID).Scan(&exists)
+	if err != nil {
+		log.GetLog().Errorf("Unable to check review photo existence. error: %v", err)
+	}
+	return exists, err
+}
+
+// Delete takes a photo off the user's review. Rejected photos stay, so they keep counting towards the
+// review's limit and cannot be swapped endlessly.
+func (r *ReviewPhotosRepoImp) Delete(ctx context.Context, imageID string, userID int32) error {
+	tag, err := r.postgres.Exec(ctx, "DELETE FROM review_photos WHERE image_id = $1 AND user_id = $2 AND status <> $3",
+		imageID, userID, models.ReviewRejected)
+	if err != nil {
+		log.GetLog().Errorf("Unable to delete review photo. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	var rejected bool
+	err = r.postgres.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM review_photos WHERE image_id = $1 AND user_id = $2)", imageID, userID).Scan(&rejected)
+	if err != nil {
+		log.GetLog().Errorf("Unable to check review photo existence. error: %v", err)
+		return err
+	}
+	if rejected {
+		return errors.ErrReviewPhotoRejected.Error()
+	}
+	return errors.ErrReviewPhotoNotFound.Error()
+}
+
+// Gallery lists the approved photos of a cafe's visible reviews, newest first.
+func (r *ReviewPhotosRepoImp) Gallery(ctx context.Context, cafeID int32, limit int) ([]models.ReviewPhoto, error) {
+	rows, err := r.postgres.Query(ctx, "SELECT "+reviewPhotoColumns+reviewPhotoFrom+`JOIN reviews rv ON rv.id = p.review_id
+		WHERE p.cafe_id = $1 AND p.status = 'approved' AND `+reviewPublic+`
+		ORDER BY p.created_at DESC LIMIT $2`, cafeID, limit)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review photo gallery. error: %v", err)
+		return nil, err
+	}
+	return scanReviewPhotos(rows)
+}
+
+// Queue lists the photos waiting for an admin, oldest first.
+func (r *ReviewPhotosRepoImp) Queue(ctx context.Context, limit int, offset int) ([]models.ReviewPhoto, int, error) {
+	var total int
+	err := r.postgres.QueryRow(ctx, "SELECT COUNT(*) FROM review_photos WHERE status = 'pending'").Scan(&total)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count review photo queue. error: %v", err)
+		return nil, 0, err
+	}
+
+	rows, err := r.postgres.Query(ctx, "SELECT "+reviewPhotoColumns+reviewPhotoFrom+`WHERE p.status = 'pending'
+		ORDER BY p.created_at, p.image_id LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review photo queue. error: %v", err)
+		return nil, 0, err
+	}
+	photos, err := scanReviewPhotos(rows)
+	return photos, total, err
+}
+
+func (r *ReviewPhotosRepoImp) SetStatus(ctx context.Context, imageID string, status models.ReviewStatus) error {
+	tag, err := r.postgres.Exec(ctx, "UPDATE review_photos SET status = $2 WHERE image_id = $1", imageID, status)
+	if err != nil {
+		log.GetLog().Errorf("Unable to set review photo status. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrReviewPhotoNotFound.Error()
+	}
+	return nil
+}
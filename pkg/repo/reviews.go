@@ -0,0 +1,751 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// a review counts as a verified visit once the user has been to the cafe, either on a reservation or for an event
+const reviewVerifiedExpr = `(EXISTS (SELECT 1 FROM reservations rs
+		WHERE rs.user_id = rv.user_id AND rs.cafe_id = rv.cafe_id AND rs.status = 'confirmed' AND rs.start_time < NOW())
+	OR EXISTS (SELECT 1 FROM event_reservations er JOIN events e ON e.id = er.event_id
+		WHERE er.user_id = rv.user_id AND e.cafe_id = rv.cafe_id AND e.start_time < NOW()))`
+
+const reviewColumns = `rv.id, rv.user_id, rv.cafe_id, COALESCE(rv.rating, 0), rv.comment, rv.coffee, rv.service, rv.ambience, rv.value,
+	rv.visit_date, ` + reviewVerifiedExpr + `, COALESCE(u.first_name, ''), COALESCE(u.last_name, ''),
+	rv.reply, rv.reply_created_at, rv.reply_updated_at, (SELECT COUNT(*) FROM review_edits e WHERE e.review_id = rv.id),
+	rv.status, rv.flags, (SELECT COUNT(*) FROM review_reports rr WHERE rr.review_id = rv.id),
+	ARRAY(SELECT p.image_id FROM review_photos p WHERE p.review_id = rv.id AND p.status = 'approved' ORDER BY p.created_at),
+	rv.helpful, rv.unhelpful, rv.created_at, rv.updated_at`
+
+// reviews held for moderation are only shown to admins
+const reviewPublic = "rv.status IN ('published', 'approved')"
+
+const reviewFrom = " FROM reviews rv LEFT JOIN users u ON u.id = rv.user_id "
+
+func scanReview(row pgx.Row, review *models.Review) error {
+	var reply *string
+	var replyCreatedAt, replyUpdatedAt *time.Time
+	err := row.Scan(&review.ID, &review.UserID, &review.CafeID, &review.Rating, &review.Comment, &review.Coffee, &review.Service, &review.Ambience, &review.Value,
+		&review.VisitDate, &review.Verified, &review.FirstName, &review.LastName,
+		&reply, &replyCreatedAt, &replyUpdatedAt, &review.Edits, &review.Status, &review.Flags, &review.Reports, &review.Photos,
+		&review.Helpful, &review.Unhelpful, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if reply != nil {
+		review.Reply = &models.ReviewReply{Comment: *reply, CreatedAt: *replyCreatedAt, UpdatedAt: *replyUpdatedAt}
+	}
+	return nil
+}
+
+type ReviewsRepo interface {
+	Upsert(ctx context.Context, review *models.Review) error
+	SetRating(ctx context.Context, userID int32, cafeID int32, rating int32) error
+	SetComment(ctx context.Context, userID int32, cafeID int32, comment string, status models.ReviewStatus, flags []string) error
+	SetReply(ctx context.Context, reviewID int32, reply *string) error
+	GetByID(ctx context.Context, reviewID int32) (*models.Review, error)
+	GetByUserAndCafe(ctx context.Context, userID int32, cafeID int32) (*models.Review, error)
+	GetByCafeID(ctx context.Context, cafeID int32, query models.ReviewQuery) (*models.ReviewPage, error)
+	GetLatest(ctx context.Context, limit int) ([]*models.Review, error)
+	Delete(ctx context.Context, userID int32, cafeID int32) error
+	CafeRating(ctx context.Context, cafeID int32) (float64, error)
+	CafeRatings(ctx context.Context, cafeIDs []int32) (map[int32]float64, error)
+	TopRated(ctx context.Context, n int) ([]int32, error)
+	RatingStats(ctx context.Context, cafeID int32, since time.Time) (*models.RatingStats, error)
+	History(ctx context.Context, reviewID int32) ([]models.ReviewEdit, error)
+	RecentWrites(ctx context.Context, userID int32, since time.Time) (int, error)
+	HasDuplicate(ctx context.Context, userID int32, cafeID int32, comment string) (bool, error)
+	Report(ctx context.Context, reviewID int32, userID int32, reason string, hideAfter int) (bool, error)
+	Queue(ctx context.Context, limit int, offset int) ([]*models.Review, int, error)
+	SetStatus(ctx context.Context, reviewID int32, status models.ReviewStatus) error
+	Vote(ctx context.Context, reviewID int32, userID int32, helpful *bool, score func(helpful int32, unhelpful int32) float64) error
+}
+
+type ReviewsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewReviewsRepoImp(postgres *pgxpool.Pool) *ReviewsRepoImp {
+	err := createReviewsTable(context.Background(), postgres)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reviews").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`ALTER TABLE reviews
+			ADD COLUMN IF NOT EXISTS reply TEXT,
+			ADD COLUMN IF NOT EXISTS reply_created_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS reply_updated_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS status TEXT DEFAULT 'published',
+			ADD COLUMN IF NOT EXISTS flags TEXT[] DEFAULT '{}',
+			ADD COLUMN IF NOT EXISTS rated_on DATE,
+			ADD COLUMN IF NOT EXISTS helpful INTEGER DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS unhelpful INTEGER DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS helpfulness FLOAT DEFAULT 0;`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reviews").Fatal("Unable to alter table")
+	}
+
+	_, err = postgres.Exec(context.Background(), `UPDATE reviews SET rated_on = updated_at::DATE WHERE rated_on IS NULL AND rating IS NOT NULL`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reviews").Fatal("Unable to backfill rating dates")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS review_edits (
+				id INTEGER PRIMARY KEY,
+				review_id INTEGER,
+				rating INTEGER,
+				comment TEXT,
+				coffee INTEGER,
+				service INTEGER,
+				ambience INTEGER,
+				value INTEGER,
+				visit_date DATE,
+				edited_at TIMESTAMP,
+				FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "review_edits").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS review_reports (
+				review_id INTEGER,
+				user_id INTEGER,
+				reason TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (review_id, user_id),
+				FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "review_reports").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS review_votes (
+				review_id INTEGER,
+				user_id INTEGER,
+				helpful BOOLEAN,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (review_id, user_id),
+				FOREIGN KEY (review_id) REFERENCES reviews(id) ON DELETE CASCADE,
+				FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "review_votes").Fatal("Unable to create table")
+	}
+
+	_, err = postgres.Exec(context.Background(),
+		`CREATE INDEX IF NOT EXISTS reviews_cafe_id_created_at ON reviews (cafe_id, created_at)`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "reviews").Fatal("Unable to create index")
+	}
+
+	err = createRatingStatsTables(context.Background(), postgres)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "cafe_rating_stats").Fatal("Unable to create table")
+	}
+
+	return &ReviewsRepoImp{postgres: postgres}
+}
+
+// createReviewsTable creates the table and, the first time only, moves the old ratings and comments into it.
+// A user's rating and comments on the same cafe become one review, their comments joined oldest first.
+// The old tables are left in place.
+func createReviewsTable(ctx context.Context, postgres *pgxpool.Pool) (e error) {
+	tx, e := postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	var exists, legacy bool
+	e = tx.QueryRow(ctx, `SELECT to_regclass('reviews') IS NOT NULL,
+		to_regclass('ratings') IS NOT NULL AND to_regclass('comments') IS NOT NULL`).Scan(&exists, &legacy)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS reviews (
+				id INTEGER PRIMARY KEY,
+				user_id INTEGER,
+				cafe_id INTEGER,
+				rating INTEGER,
+				comment TEXT DEFAULT '',
+				coffee INTEGER,
+				service INTEGER,
+				ambience INTEGER,
+				value INTEGER,
+				visit_date DATE,
+				created_at TIMESTAMP,
+				updated_at TIMESTAMP,
+				FOREIGN KEY (cafe_id) REFERENCES cafes(id),
+				FOREIGN KEY (user_id) REFERENCES users(id),
+				UNIQUE (user_id, cafe_id)
+			);`)
+	if e != nil {
+		return
+	}
+
+	if !exists && legacy {
+		_, e = tx.Exec(ctx, `INSERT INTO reviews (id, user_id, cafe_id, rating, comment, created_at, updated_at)
+			SELECT ROW_NUMBER() OVER (ORDER BY COALESCE(c.first_date, NOW())), COALESCE(r.user_id, c.user_id), COALESCE(r.cafe_id, c.cafe_id), r.rating,
+				COALESCE(c.comment, ''), COALESCE(c.first_date, NOW()), COALESCE(c.last_date, NOW())
+			FROM ratings r
+			FULL OUTER JOIN (
+				SELECT user_id, cafe_id, string_agg(comment, E'\n\n' ORDER BY date) AS comment, MIN(date) AS first_date, MAX(date) AS last_date
+				FROM comments GROUP BY user_id, cafe_id
+			) c ON c.user_id = r.user_id AND c.cafe_id = r.cafe_id
+			ON CONFLICT (user_id, cafe_id) DO NOTHING`)
+		if e != nil {
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// save runs an insert-or-update of the user's review, keeping the version it replaces in review_edits
+// and the cafe's rating stats in step. Writes that leave the review as it was do not add to the history.
+func (r *ReviewsRepoImp) save(ctx context.Context, userID int32, cafeID int32, query string, args ...any) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to save review. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	before, e := snapshotRating(ctx, tx, userID, cafeID)
+	if e != nil {
+		return
+	}
+
+	editID := rand.Int31()
+	_, e = tx.Exec(ctx, `INSERT INTO review_edits (id, review_id, rating, comment, coffee, service, ambience, value, visit_date, edited_at)
+		SELECT $1, id, rating, comment, coffee, service, ambience, value, visit_date, NOW()
+		FROM reviews WHERE user_id = $2 AND cafe_id = $3`, editID, userID, cafeID)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, query, args...)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, `DELETE FROM review_edits e USING reviews rv
+		WHERE e.id = $1 AND rv.id = e.review_id
+		AND (e.rating, e.comment, e.coffee, e.service, e.ambience, e.value, e.visit_date)
+			IS NOT DISTINCT FROM (rv.rating, rv.comment, rv.coffee, rv.service, rv.ambience, rv.value, rv.visit_date)`, editID)
+	if e != nil {
+		return
+	}
+
+	after, e := snapshotRating(ctx, tx, userID, cafeID)
+	if e != nil {
+		return
+	}
+	// a rating counts towards the trend on the day it was last changed
+	if after.rating != nil && (before == nil || before.rating == nil || *before.rating != *after.rating) {
+		e = tx.QueryRow(ctx, "UPDATE reviews SET rated_on = CURRENT_DATE WHERE user_id = $1 AND cafe_id = $2 RETURNING rated_on", userID, cafeID).Scan(&after.ratedOn)
+		if e != nil {
+			return
+		}
+	}
+
+	e = applyRating(ctx, tx, before, -1)
+	if e != nil {
+		return
+	}
+	e = applyRating(ctx, tx, after, 1)
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+// editLockedStatuses is models.ReviewEditLocked for queries, where status keeps its current value on
+// conflict instead of the moderated one, the same as ReviewStatus.AfterEdit.
+func editLockedStatuses() []string {
+	var statuses []string
+	for _, status := range models.ReviewEditLocked {
+		statuses = append(statuses, string(status))
+	}
+	return statuses
+}
+
+func (r *ReviewsRepoImp) Upsert(ctx context.Context, review *models.Review) error {
+	return r.save(ctx, review.UserID, review.CafeID, `INSERT INTO reviews (id, user_id, cafe_id, rating, comment, coffee, service, ambience, value, visit_date, status, flags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW(), NOW())
+		ON CONFLICT (user_id, cafe_id) DO UPDATE SET rating = $4, comment = $5, coffee = $6, service = $7, ambience = $8, value = $9, visit_date = $10,
+			status = CASE WHEN reviews.status = ANY($13::TEXT[]) THEN reviews.status ELSE $11 END,
+			flags = CASE WHEN reviews.status = ANY($13::TEXT[]) THEN reviews.flags ELSE $12 END, updated_at = NOW()`,
+		rand.Int31(), review.UserID, review.CafeID, review.Rating, review.Comment, review.Coffee, review.Service, review.Ambience, review.Value, review.VisitDate,
+		review.Status, review.Flags, editLockedStatuses())
+}
+
+// SetRating and SetComment change one part of a review, starting one if the user has none yet.
+func (r *ReviewsRepoImp) SetRating(ctx context.Context, userID int32, cafeID int32, rating int32) error {
+	return r.save(ctx, userID, cafeID, `INSERT INTO reviews (id, user_id, cafe_id, rating, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (user_id, cafe_id) DO UPDATE SET rating = $4, updated_at = NOW()`, rand.Int31(), userID, cafeID, rating)
+}
+
+func (r *ReviewsRepoImp) SetComment(ctx context.Context, userID int32, cafeID int32, comment string, status models.ReviewStatus, flags []string) error {
+	return r.save(ctx, userID, cafeID, `INSERT INTO reviews (id, user_id, cafe_id, comment, status, flags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (user_id, cafe_id) DO UPDATE SET comment = $4,
+			status = CASE WHEN reviews.status = ANY($7::TEXT[]) THEN reviews.status ELSE $5 END,
+			flags = CASE WHEN reviews.status = ANY($7::TEXT[]) THEN reviews.flags ELSE $6 END, updated_at = NOW()`,
+		rand.Int31(), userID, cafeID, comment, status, flags, editLockedStatuses())
+}
+
+// SetReply sets the manager's reply to a review, or removes it when reply is nil.
+func (r *ReviewsRepoImp) SetReply(ctx context.Context, reviewID int32, reply *string) error {
+	tag, err := r.postgres.Exec(ctx, `UPDATE reviews SET reply = $2::TEXT,
+			reply_created_at = CASE WHEN $2::TEXT IS NULL THEN NULL ELSE COALESCE(reply_created_at, NOW()) END,
+			reply_updated_at = CASE WHEN $2::TEXT IS NULL THEN NULL ELSE NOW() END
+		WHERE id = $1`, reviewID, reply)
+	if err != nil {
+		log.GetLog().Errorf("Unable to set review reply. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrReviewNotFound.Error()
+	}
+	return nil
+}
+
+// GetByID returns nil when there is no such review.
+func (r *ReviewsRepoImp) GetByID(ctx context.Context, reviewID int32) (*models.Review, error) {
+	var review models.Review
+	err := scanReview(r.postgres.QueryRow(ctx, "SELECT "+reviewColumns+reviewFrom+"WHERE rv.id = $1", reviewID), &review)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review. error: %v", err)
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetByUserAndCafe returns nil when the user has not reviewed the cafe.
+func (r *ReviewsRepoImp) GetByUserAndCafe(ctx context.Context, userID int32, cafeID int32) (*models.Review, error) {
+	var review models.Review
+	err := scanReview(r.postgres.QueryRow(ctx, "SELECT "+reviewColumns+reviewFrom+"WHERE rv.user_id = $1 AND rv.cafe_id = $2", userID, cafeID), &review)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review. error: %v", err)
+		return nil, err
+	}
+	return &review, nil
+}
+
+func (r *ReviewsRepoImp) queryReviews(ctx context.Context, query string, args ...any) ([]*models.Review, error) {
+	rows, err := r.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reviews. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []*models.Review{}
+	for rows.Next() {
+		var review models.Review
+		err = scanReview(rows, &review)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan review. error: %v", err)
+			return nil, err
+		}
+		reviews = append(reviews, &review)
+	}
+	return reviews, rows.Err()
+}
+
+// reviewSort is how a models.ReviewSort is paged: by key, then by id, newest id first on ties.
+// The key is compared as text cast back to keyType, so any key fits in a cursor.
+type reviewSort struct {
+	key     string
+	keyType string
+	asc     bool
+	where   string
+}
+
+var reviewSorts = map[models.ReviewSort]reviewSort{
+	models.ReviewSortNewest:   {key: "rv.created_at", keyType: "TIMESTAMP"},
+	models.ReviewSortHelpful:  {key: "rv.helpfulness", keyType: "FLOAT"},
+	models.ReviewSortHighest:  {key: "rv.rating", keyType: "INTEGER", where: " AND rv.rating IS NOT NULL"},
+	models.ReviewSortLowest:   {key: "rv.rating", keyType: "INTEGER", asc: true, where: " AND rv.rating IS NOT NULL"},
+	models.ReviewSortVerified: {key: "rv.created_at", keyType: "TIMESTAMP", where: " AND " + reviewVerifiedExpr},
+}
+
+func encodeReviewCursor(key string, id int32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key + "|" + strconv.Itoa(int(id))))
+}
+
+func decodeReviewCursor(cursor string) (string, int32, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errors.ErrBadRequest.Error()
+	}
+	i := strings.LastIndex(string(raw), "|")
+	if i < 0 {
+		return "", 0, errors.ErrBadRequest.Error()
+	}
+	id, err := strconv.ParseInt(string(raw[i+1:]), 10, 32)
+	if err != nil {
+		return "", 0, errors.ErrBadRequest.Error()
+	}
+	return string(raw[:i]), int32(id), nil
+}
+
+// keyedRow scans a row of review columns followed by extra columns.
+type keyedRow struct {
+	row   pgx.Row
+	extra []any
+}
+
+func (k keyedRow) Scan(dest ...any) error {
+	return k.row.Scan(append(dest, k.extra...)...)
+}
+
+// GetByCafeID returns a page of a cafe's visible reviews in the requested order, along with how many
+// there are in all.
+func (r *ReviewsRepoImp) GetByCafeID(ctx context.Context, cafeID int32, query models.ReviewQuery) (*models.ReviewPage, error) {
+	sort, ok := reviewSorts[query.Sort]
+	if !ok {
+		return nil, errors.ErrBadRequest.Error()
+	}
+
+	where := "WHERE rv.cafe_id = $1 AND " + reviewPublic + sort.where
+	if query.WithComment {
+		where += " AND rv.comment <> ''"
+	}
+
+	page := &models.ReviewPage{Reviews: []*models.Review{}}
+	err := r.postgres.QueryRow(ctx, "SELECT COUNT(*)"+reviewFrom+where, cafeID).Scan(&page.Total)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count reviews. error: %v", err)
+		return nil, err
+	}
+
+	order, after := "DESC", "<"
+	if sort.asc {
+		order, after = "ASC", ">"
+	}
+	args := []any{cafeID, query.Limit + 1}
+	if query.Cursor != "" {
+		key, id, err := decodeReviewCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		where += fmt.Sprintf(" AND (%[1]s %[2]s $3::TEXT::%[3]s OR (%[1]s = $3::TEXT::%[3]s AND rv.id < $4))", sort.key, after, sort.keyType)
+		args = append(args, key, id)
+	}
+
+	rows, err := r.postgres.Query(ctx, "SELECT "+reviewColumns+", "+sort.key+"::TEXT"+reviewFrom+where+
+		" ORDER BY "+sort.key+" "+order+", rv.id DESC LIMIT $2", args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get reviews. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var key string
+	for rows.Next() {
+		if len(page.Reviews) == query.Limit {
+			last := page.Reviews[len(page.Reviews)-1]
+			page.Next = encodeReviewCursor(key, last.ID)
+			break
+		}
+
+		var review models.Review
+		err = scanReview(keyedRow{row: rows, extra: []any{&key}}, &review)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan review. error: %v", err)
+			return nil, err
+		}
+		page.Reviews = append(page.Reviews, &review)
+	}
+	return page, rows.Err()
+}
+
+// GetLatest lists the newest written reviews across all visible cafes.
+func (r *ReviewsRepoImp) GetLatest(ctx context.Context, limit int) ([]*models.Review, error) {
+	return r.queryReviews(ctx, "SELECT "+reviewColumns+reviewFrom+`JOIN cafes c ON c.id = rv.cafe_id
+		WHERE rv.comment <> '' AND `+reviewPublic+` AND NOT c.hidden ORDER BY rv.created_at DESC LIMIT $1`, limit)
+}
+
+func (r *ReviewsRepoImp) Delete(ctx context.Context, userID int32, cafeID int32) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to delete review. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	before, e := snapshotRating(ctx, tx, userID, cafeID)
+	if e != nil {
+		return
+	}
+	if before == nil {
+		return errors.ErrReviewNotFound.Error()
+	}
+
+	_, e = tx.Exec(ctx, "DELETE FROM reviews WHERE user_id = $1 AND cafe_id = $2", userID, cafeID)
+	if e != nil {
+		return
+	}
+
+	e = applyRating(ctx, tx, before, -1)
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *ReviewsRepoImp) CafeRating(ctx context.Context, cafeID int32) (float64, error) {
+	var rating float64
+	err := r.postgres.QueryRow(ctx, `SELECT COALESCE((SELECT rating_sum::FLOAT / NULLIF(ratings, 0) FROM cafe_rating_stats WHERE cafe_id = $1), 0)`, cafeID).Scan(&rating)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe rating. error: %v", err)
+	}
+	return rating, err
+}
+
+// CafeRatings maps each rated cafe among cafeIDs to its average rating.
+func (r *ReviewsRepoImp) CafeRatings(ctx context.Context, cafeIDs []int32) (map[int32]float64, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT cafe_id, rating_sum::FLOAT / ratings FROM cafe_rating_stats
+		WHERE cafe_id = ANY($1) AND ratings > 0`, cafeIDs)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get cafe ratings. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	ratings := map[int32]float64{}
+	for rows.Next() {
+		var cafeID int32
+		var rating float64
+		err = rows.Scan(&cafeID, &rating)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan cafe rating. error: %v", err)
+			return nil, err
+		}
+		ratings[cafeID] = rating
+	}
+	return ratings, rows.Err()
+}
+
+// TopRated ranks by the Bayesian average of each cafe's ratings.
+func (r *ReviewsRepoImp) TopRated(ctx context.Context, n int) ([]int32, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT s.cafe_id FROM cafe_rating_stats s WHERE s.ratings > 0
+		ORDER BY `+bayesianRatingExpr+` DESC, s.cafe_id
+		LIMIT $1`, n)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get top rated cafes. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cafeIDs []int32
+	for rows.Next() {
+		var cafeID int32
+		err = rows.Scan(&cafeID)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan top rated cafe. error: %v", err)
+			return nil, err
+		}
+		cafeIDs = append(cafeIDs, cafeID)
+	}
+	return cafeIDs, rows.Err()
+}
+
+// History lists the earlier versions of a review, newest first.
+func (r *ReviewsRepoImp) History(ctx context.Context, reviewID int32) ([]models.ReviewEdit, error) {
+	rows, err := r.postgres.Query(ctx, `SELECT COALESCE(rating, 0), comment, coffee, service, ambience, value, visit_date, edited_at
+		FROM review_edits WHERE review_id = $1 ORDER BY edited_at DESC`, reviewID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get review history. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	edits := []models.ReviewEdit{}
+	for rows.Next() {
+		var edit models.ReviewEdit
+		err = rows.Scan(&edit.Rating, &edit.Comment, &edit.Coffee, &edit.Service, &edit.Ambience, &edit.Value, &edit.VisitDate, &edit.EditedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan review edit. error: %v", err)
+			return nil, err
+		}
+		edits = append(edits, edit)
+	}
+	return edits, rows.Err()
+}
+
+// RecentWrites counts the reviews the user has written or edited since the given time.
+func (r *ReviewsRepoImp) RecentWrites(ctx context.Context, userID int32, since time.Time) (int, error) {
+	var count int
+	err := r.postgres.QueryRow(ctx, `SELECT
+			(SELECT COUNT(*) FROM reviews WHERE user_id = $1 AND created_at > $2) +
+			(SELECT COUNT(*) FROM review_edits e JOIN reviews rv ON rv.id = e.review_id WHERE rv.user_id = $1 AND e.edited_at > $2)`,
+		userID, since).Scan(&count)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count recent reviews. error: %v", err)
+	}
+	return count, err
+}
+
+// HasDuplicate reports whether the user already posted the same text on another cafe.
+func (r *ReviewsRepoImp) HasDuplicate(ctx context.Context, userID int32, cafeID int32, comment string) (bool, error) {
+	var exists bool
+	err := r.postgres.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM reviews
+		WHERE user_id = $1 AND cafe_id <> $2 AND comment <> '' AND persian_normalize(comment) = persian_normalize($3))`,
+		userID, cafeID, comment).Scan(&exists)
+	if err != nil {
+		log.GetLog().Errorf("Unable to check duplicate review. error: %v", err)
+	}
+	return exists, err
+}
+
+// Report records a user's report of a review, once per user, and hides a published review when it
+// reaches hideAfter reports. The first result reports whether this report hid it.
+func (r *ReviewsRepoImp) Report(ctx context.Context, reviewID int32, userID int32, reason string, hideAfter int) (hidden bool, e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to report review. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	_, e = tx.Exec(ctx, `INSERT INTO review_reports (review_id, user_id, reason) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, reviewID, userID, reason)
+	if e != nil {
+		return
+	}
+
+	tag, e := tx.Exec(ctx, `UPDATE reviews SET status = 'hidden'
+		WHERE id = $1 AND status = 'published' AND (SELECT COUNT(*) FROM review_reports WHERE review_id = $1) >= $2`, reviewID, hideAfter)
+	if e != nil {
+		return
+	}
+
+	return tag.RowsAffected() > 0, tx.Commit(ctx)
+}
+
+// Queue lists the reviews waiting for an admin, oldest first.
+func (r *ReviewsRepoImp) Queue(ctx context.Context, limit int, offset int) ([]*models.Review, int, error) {
+	where := "WHERE rv.status IN ('pending', 'hidden')"
+
+	var total int
+	err := r.postgres.QueryRow(ctx, "SELECT COUNT(*)"+reviewFrom+where).Scan(&total)
+	if err != nil {
+		log.GetLog().Errorf("Unable to count review queue. error: %v", err)
+		return nil, 0, err
+	}
+
+	reviews, err := r.queryReviews(ctx, "SELECT "+reviewColumns+reviewFrom+where+" ORDER BY rv.updated_at, rv.id LIMIT $1 OFFSET $2", limit, offset)
+	return reviews, total, err
+}
+
+func (r *ReviewsRepoImp) SetStatus(ctx context.Context, reviewID int32, status models.ReviewStatus) error {
+	tag, err := r.postgres.Exec(ctx, "UPDATE reviews SET status = $2 WHERE id = $1", reviewID, status)
+	if err != nil {
+		log.GetLog().Errorf("Unable to set review status. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrReviewNotFound.Error()
+	}
+	return nil
+}
+
+// Vote records the user's helpful or unhelpful vote on a review, or takes it back when helpful is nil,
+// and stores the review's new vote counts with the ranking score for them. Votes on the same review are
+// counted one at a time.
+func (r *ReviewsRepoImp) Vote(ctx context.Context, reviewID int32, userID int32, helpful *bool, score func(helpful int32, unhelpful int32) float64) (e error) {
+	tx, e := r.postgres.Begin(ctx)
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			log.GetLog().Errorf("Unable to vote on review. error: %v", e)
+			tx.Rollback(ctx)
+		}
+	}()
+
+	var author int32
+	e = tx.QueryRow(ctx, "SELECT rv.user_id FROM reviews rv WHERE rv.id = $1 AND "+reviewPublic+" FOR UPDATE", reviewID).Scan(&author)
+	if e == pgx.ErrNoRows {
+		e = errors.ErrReviewNotFound.Error()
+	}
+	if e != nil {
+		return
+	}
+	if author == userID {
+		e = errors.ErrReviewSelfVote.Error()
+		return
+	}
+
+	if helpful == nil {
+		_, e = tx.Exec(ctx, "DELETE FROM review_votes WHERE review_id = $1 AND user_id = $2", reviewID, userID)
+	} else {
+		_, e = tx.Exec(ctx, `INSERT INTO review_votes (review_id, user_id, helpful) VALUES ($1, $2, $3)
+			ON CONFLICT (review_id, user_id) DO UPDATE SET helpful = $3, created_at = NOW()`, reviewID, userID, *helpful)
+	}
+	if e != nil {
+		return
+	}
+
+	var up, down int32
+	e = tx.QueryRow(ctx, `SELECT COUNT(*) FILTER (WHERE helpful), COUNT(*) FILTER (WHERE NOT helpful)
+		FROM review_votes WHERE review_id = $1`, reviewID).Scan(&up, &down)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, "UPDATE reviews SET helpful = $2, unhelpful = $3, helpfulness = $4 WHERE id = $1",
+		reviewID, up, down, score(up, down))
+	if e != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
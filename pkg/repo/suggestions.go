@@ -0,0 +1,233 @@
+package repo
+
+import (
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const recentSearchesKept = 20
+
+type SuggestionsRepo interface {
+	Cafes(ctx context.Context, prefixes []string, lat *float64, lng *float64, limit int) ([]models.Suggestion, error)
+	MenuItems(ctx context.Context, prefixes []string, limit int) ([]models.Suggestion, error)
+	AreaCafeCounts(ctx context.Context) (map[int]int, map[int]int, error)
+	AddRecentSearch(ctx context.Context, userID int32, query string, normalized string) error
+	GetRecentSearches(ctx context.Context, userID int32, prefixes []string, limit int) ([]models.RecentSearch, error)
+	ClearRecentSearches(ctx context.Context, userID int32) error
+}
+
+type SuggestionsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+// NewSuggestionsRepoImp needs the cafes and menu_items tables, persian_normalize and pg_trgm to exist already.
+func NewSuggestionsRepoImp(postgres *pgxpool.Pool) *SuggestionsRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS recent_searches (
+				user_id INTEGER,
+				query TEXT,
+				normalized TEXT,
+				searched_at TIMESTAMP DEFAULT NOW(),
+				PRIMARY KEY (user_id, normalized),
+				FOREIGN KEY (user_id) REFERENCES users(id)
+			);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "recent_searches").Fatal("Unable to create table")
+	}
+
+	// names are matched at the start of any word, which a btree cannot serve; trigram indexes handle both
+	// the leading and the mid-name LIKE patterns
+	for table, index := range map[string]string{
+		"cafes":      "cafes_name",
+		"menu_items": "menu_items_name",
+	} {
+		_, err = postgres.Exec(context.Background(), fmt.Sprintf("DROP INDEX IF EXISTS %s_prefix", index))
+		if err != nil {
+			log.GetLog().WithError(err).WithField("table", table).Fatal("Unable to drop index")
+		}
+
+		_, err = postgres.Exec(context.Background(),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_trgm ON %s USING GIN (persian_normalize(name) gin_trgm_ops)", index, table))
+		if err != nil {
+			log.GetLog().WithError(err).WithField("table", table).Fatal("Unable to create index")
+		}
+	}
+
+	return &SuggestionsRepoImp{postgres: postgres}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// prefixCondition matches column against any of the prefixes, either at the start or at the start of a later word.
+func prefixCondition(column string, prefixes []string, arg func(any) string) string {
+	var conditions []string
+	for _, prefix := range prefixes {
+		escaped := likeEscaper.Replace(prefix)
+		conditions = append(conditions, column+" LIKE "+arg(escaped+"%"), column+" LIKE "+arg("% "+escaped+"%"))
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// Cafes ranks by popularity, and by distance as well when a position is given: each doubling of
+// the distance weighs as much as a doubling of popularity.
+func (s *SuggestionsRepoImp) Cafes(ctx context.Context, prefixes []string, lat *float64, lng *float64, limit int) ([]models.Suggestion, error) {
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := prefixCondition("persian_normalize(name)", prefixes, arg)
+	distance := "NULL::FLOAT"
+	if lat != nil && lng != nil {
+		distance = fmt.Sprintf("haversine_km(%s, %s, l.latitude, l.longitude)", arg(*lat), arg(*lng))
+	}
+
+	query := fmt.Sprintf(`SELECT cafes.id, name, %[1]s AS distance
+		FROM cafes LEFT JOIN locations l ON l.id = cafes.id
+		WHERE NOT cafes.hidden AND %[2]s
+		ORDER BY ln(1 + %[3]s) - COALESCE(ln(1 + %[1]s), 0) DESC, length(name), cafes.id
+		LIMIT %[4]s`, distance, where, cafePopularityExpr, arg(limit))
+
+	rows, err := s.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to suggest cafes. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		suggestion := models.Suggestion{Type: models.SuggestionCafe}
+		err = rows.Scan(&suggestion.ID, &suggestion.Text, &suggestion.Distance)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan cafe suggestion. error: %v", err)
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// MenuItems groups items by their normalized name and ranks them by how many cafes serve them.
+func (s *SuggestionsRepoImp) MenuItems(ctx context.Context, prefixes []string, limit int) ([]models.Suggestion, error) {
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := fmt.Sprintf(`SELECT MIN(m.name), COUNT(DISTINCT m.cafe_id) AS cafes
+		FROM menu_items m JOIN cafes c ON c.id = m.cafe_id
+		WHERE NOT c.hidden AND NOT m.hidden AND %s
+		GROUP BY persian_normalize(m.name)
+		ORDER BY cafes DESC, MIN(m.name)
+		LIMIT %s`, prefixCondition("persian_normalize(m.name)", prefixes, arg), arg(limit))
+
+	rows, err := s.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to suggest menu items. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suggestions []models.Suggestion
+	for rows.Next() {
+		suggestion := models.Suggestion{Type: models.SuggestionMenuItem}
+		err = rows.Scan(&suggestion.Text, &suggestion.Count)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan menu item suggestion. error: %v", err)
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, rows.Err()
+}
+
+// AreaCafeCounts returns the number of visible cafes per province and per city.
+func (s *SuggestionsRepoImp) AreaCafeCounts(ctx context.Context) (map[int]int, map[int]int, error) {
+	rows, err := s.postgres.Query(ctx, "SELECT province, city, COUNT(*) FROM cafes WHERE NOT hidden GROUP BY province, city")
+	if err != nil {
+		log.GetLog().Errorf("Unable to count cafes by area. error: %v", err)
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	provinces, cities := map[int]int{}, map[int]int{}
+	for rows.Next() {
+		var province, city, count int
+		err = rows.Scan(&province, &city, &count)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan area count. error: %v", err)
+			return nil, nil, err
+		}
+		provinces[province] += count
+		cities[city] += count
+	}
+	return provinces, cities, rows.Err()
+}
+
+// AddRecentSearch keeps one row per distinct normalized query and only the latest searches of each user.
+func (s *SuggestionsRepoImp) AddRecentSearch(ctx context.Context, userID int32, query string, normalized string) error {
+	_, err := s.postgres.Exec(ctx,
+		`INSERT INTO recent_searches (user_id, query, normalized, searched_at) VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id, normalized) DO UPDATE SET query = $2, searched_at = NOW()`, userID, query, normalized)
+	if err != nil {
+		log.GetLog().Errorf("Unable to add recent search. error: %v", err)
+		return err
+	}
+
+	_, err = s.postgres.Exec(ctx,
+		`DELETE FROM recent_searches WHERE user_id = $1 AND normalized NOT IN (
+			SELECT normalized FROM recent_searches WHERE user_id = $1 ORDER BY searched_at DESC LIMIT $2)`, userID, recentSearchesKept)
+	if err != nil {
+		log.GetLog().Errorf("Unable to trim recent searches. error: %v", err)
+	}
+	return err
+}
+
+func (s *SuggestionsRepoImp) GetRecentSearches(ctx context.Context, userID int32, prefixes []string, limit int) ([]models.RecentSearch, error) {
+	args := []any{userID}
+	arg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	query := "SELECT query, searched_at FROM recent_searches WHERE user_id = $1"
+	if len(prefixes) > 0 {
+		query += " AND " + prefixCondition("normalized", prefixes, arg)
+	}
+	query += " ORDER BY searched_at DESC LIMIT " + arg(limit)
+
+	rows, err := s.postgres.Query(ctx, query, args...)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get recent searches. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := []models.RecentSearch{}
+	for rows.Next() {
+		var search models.RecentSearch
+		err = rows.Scan(&search.Query, &search.SearchedAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan recent search. error: %v", err)
+			return nil, err
+		}
+		searches = append(searches, search)
+	}
+	return searches, rows.Err()
+}
+
+func (s *SuggestionsRepoImp) ClearRecentSearches(ctx context.Context, userID int32) error {
+	_, err := s.postgres.Exec(ctx, "DELETE FROM recent_searches WHERE user_id = $1", userID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to clear recent searches. error: %v", err)
+	}
+	return err
+}
@@ -5,10 +5,10 @@ import (
 	"barista/pkg/log"
 	"barista/pkg/models"
 	"context"
+	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"math/rand"
-	"time"
 )
 
 type Transaction interface {
@@ -40,17 +40,34 @@ func NewTransactionImp(postgres *pgxpool.Pool) *TransactionImp {
 		log.GetLog().WithError(err).WithField("table", "transactions").Fatal("Unable to create table")
 	}
 
+	_, err = postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS transaction_collisions (
+			id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);`)
+
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "transaction_collisions").Fatal("Unable to create table")
+	}
+
 	return &TransactionImp{postgres: postgres}
 }
 
-func (t *TransactionImp) Create(ctx context.Context, transaction *models.Transaction) (transactionID string, e error) {
+const (
+	transactionIDLength  = 12
+	transactionIDRetries = 5
+)
+
+func newTransactionID() string {
 	charset := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-	password := make([]byte, 12)
-	for i := range password {
-		password[i] = charset[random.Intn(len(charset))]
+	id := make([]byte, transactionIDLength)
+	for i := range id {
+		id[i] = charset[rand.Intn(len(charset))]
 	}
-	transaction.ID = string(password)
+	return string(id)
+}
+
+func (t *TransactionImp) Create(ctx context.Context, transaction *models.Transaction) (transactionID string, e error) {
 	// transactions
 	tx, e := t.postgres.BeginTx(ctx, pgx.TxOptions{})
 	if e != nil {
@@ -63,41 +80,92 @@ func (t *TransactionImp) Create(ctx context.Context, transaction *models.Transac
 		}
 	}()
 
-	// get sender balance
-	var senderBalance int64
-	row := tx.QueryRow(ctx, "SELECT balance FROM users WHERE id = $1", transaction.SenderID)
-	e = row.Scan(&senderBalance)
+	e = createTransaction(ctx, tx, transaction)
 	if e != nil {
 		return
 	}
 
-	if senderBalance < transaction.Amount && transaction.Type != models.Deposit {
-		return "", errors.ErrNotEnoughBalance.Error()
+	e = tx.Commit(ctx)
+	return transaction.ID, e
+}
+
+// createTransaction moves money inside an open database transaction so other repos can pay as part of their own writes.
+func createTransaction(ctx context.Context, tx pgx.Tx, transaction *models.Transaction) error {
+	// get sender balance
+	var senderBalance int64
+	row := tx.QueryRow(ctx, "SELECT balance FROM users WHERE id = $1 FOR UPDATE", transaction.SenderID)
+	err := row.Scan(&senderBalance)
+	if err != nil {
+		return err
 	}
 
-	_, e = tx.Exec(ctx, "INSERT INTO transactions (id, sender_id, receiver_id, amount, description, transaction_type) VALUES ($1, $2, $3, $4, $5, $6)", transaction.ID, transaction.SenderID, transaction.ReceiverID, transaction.Amount, transaction.Description, transaction.Type)
-	if e != nil {
-		return
+	if senderBalance < transaction.Amount && transaction.Type.DebitsSender() {
+		return errors.ErrNotEnoughBalance.Error()
+	}
+
+	// ids are random, so retry on the rare primary key collision instead of failing the payment
+	for i := 0; ; i++ {
+		if i == transactionIDRetries {
+			return fmt.Errorf("unable to generate unique transaction id after %d attempts", transactionIDRetries)
+		}
+
+		transaction.ID = newTransactionID()
+		tag, err := tx.Exec(ctx, "INSERT INTO transactions (id, sender_id, receiver_id, amount, description, transaction_type) VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (id) DO NOTHING", transaction.ID, transaction.SenderID, transaction.ReceiverID, transaction.Amount, transaction.Description, transaction.Type)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 1 {
+			break
+		}
+
+		log.GetLog().Warnf("Transaction id collision. id: %v", transaction.ID)
+		_, err = tx.Exec(ctx, "INSERT INTO transaction_collisions (id) VALUES ($1)", transaction.ID)
+		if err != nil {
+			return err
+		}
 	}
 
 	// update sender balance
-	if transaction.Type != models.Deposit {
-		_, e = tx.Exec(ctx, "UPDATE users SET balance = balance - $1 WHERE id = $2", transaction.Amount, transaction.SenderID)
-		if e != nil {
-			return
+	if transaction.Type.DebitsSender() {
+		_, err = tx.Exec(ctx, "UPDATE users SET balance = balance - $1 WHERE id = $2", transaction.Amount, transaction.SenderID)
+		if err != nil {
+			return err
 		}
 	}
 
 	// update receiver balance
-	if transaction.Type != models.Withdraw {
-		_, e = tx.Exec(ctx, "UPDATE users SET balance = balance + $1 WHERE id = $2", transaction.Amount, transaction.ReceiverID)
-		if e != nil {
-			return
+	if transaction.Type.CreditsReceiver() {
+		_, err = tx.Exec(ctx, "UPDATE users SET balance = balance + $1 WHERE id = $2", transaction.Amount, transaction.ReceiverID)
+		if err != nil {
+			return err
 		}
 	}
 
-	e = tx.Commit(ctx)
-	return transaction.ID, e
+	return nil
+}
+
+// checkoutPayment pays from the wallet inside an open transaction, letting the gift card with the given
+// code, if any, cover as much of the amount as it can first.
+func checkoutPayment(ctx context.Context, tx pgx.Tx, code string, cafeID int32, payment *models.Transaction) (string, error) {
+	if code == "" || payment.Amount <= 0 {
+		err := createTransaction(ctx, tx, payment)
+		return payment.ID, err
+	}
+
+	covered, transactionID, err := redeemGiftCard(ctx, tx, code, payment.SenderID, cafeID, payment.ReceiverID, payment.Amount, payment.Description)
+	if err != nil {
+		return "", err
+	}
+
+	if covered < payment.Amount {
+		payment.Amount -= covered
+		err = createTransaction(ctx, tx, payment)
+		if err != nil {
+			return "", err
+		}
+		transactionID = payment.ID
+	}
+	return transactionID, nil
 }
 
 func (t *TransactionImp) GetByID(ctx context.Context, id string) (transaction *models.Transaction, e error) {
@@ -0,0 +1,122 @@
+package repo
+
+import (
+	"barista/pkg/errors"
+	"barista/pkg/log"
+	"barista/pkg/models"
+	"context"
+	"math/rand"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TransferRequestsRepo interface {
+	Create(ctx context.Context, request *models.TransferRequest) error
+	GetBySenderID(ctx context.Context, senderID int32) ([]models.TransferRequest, error)
+	Confirm(ctx context.Context, id int32, senderID int32) (transactionID string, e error)
+	Cancel(ctx context.Context, id int32, senderID int32) error
+}
+
+type TransferRequestsRepoImp struct {
+	postgres *pgxpool.Pool
+}
+
+func NewTransferRequestsRepoImp(postgres *pgxpool.Pool) *TransferRequestsRepoImp {
+	_, err := postgres.Exec(context.Background(),
+		`CREATE TABLE IF NOT EXISTS transfer_requests (
+			id INT PRIMARY KEY,
+			sender_id INT,
+			receiver_id INT,
+			amount BIGINT,
+			message TEXT,
+			status TEXT,
+			transaction_id TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP
+		);`)
+	if err != nil {
+		log.GetLog().WithError(err).WithField("table", "transfer_requests").Fatal("Unable to create table")
+	}
+
+	return &TransferRequestsRepoImp{postgres: postgres}
+}
+
+func (t *TransferRequestsRepoImp) Create(ctx context.Context, request *models.TransferRequest) error {
+	request.ID = rand.Int31()
+	request.Status = models.TransferPending
+	err := t.postgres.QueryRow(ctx, "INSERT INTO transfer_requests (id, sender_id, receiver_id, amount, message, status, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at", request.ID, request.SenderID, request.ReceiverID, request.Amount, request.Message, request.Status, request.ExpiresAt).Scan(&request.CreatedAt)
+	if err != nil {
+		log.GetLog().Errorf("Unable to insert transfer request. error: %v", err)
+	}
+	return err
+}
+
+func (t *TransferRequestsRepoImp) GetBySenderID(ctx context.Context, senderID int32) ([]models.TransferRequest, error) {
+	rows, err := t.postgres.Query(ctx, "SELECT id, sender_id, receiver_id, amount, message, status, COALESCE(transaction_id, ''), created_at, expires_at FROM transfer_requests WHERE sender_id = $1 ORDER BY created_at DESC", senderID)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get transfer requests by sender id. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.TransferRequest
+	for rows.Next() {
+		var request models.TransferRequest
+		err = rows.Scan(&request.ID, &request.SenderID, &request.ReceiverID, &request.Amount, &request.Message, &request.Status, &request.TransactionID, &request.CreatedAt, &request.ExpiresAt)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan transfer request. error: %v", err)
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, nil
+}
+
+func (t *TransferRequestsRepoImp) Confirm(ctx context.Context, id int32, senderID int32) (transactionID string, e error) {
+	tx, e := t.postgres.BeginTx(ctx, pgx.TxOptions{})
+	if e != nil {
+		return
+	}
+	defer func() {
+		if e != nil {
+			tx.Rollback(ctx)
+		}
+	}()
+
+	transaction := &models.Transaction{SenderID: senderID, Type: models.Transfer}
+	e = tx.QueryRow(ctx, "SELECT receiver_id, amount, message FROM transfer_requests WHERE id = $1 AND sender_id = $2 AND status = $3 AND expires_at > NOW() FOR UPDATE", id, senderID, models.TransferPending).Scan(&transaction.ReceiverID, &transaction.Amount, &transaction.Description)
+	// requests to contacts without an account are never confirmed, with the same error as an expired one
+	if e == pgx.ErrNoRows || (e == nil && transaction.ReceiverID == 0) {
+		e = errors.ErrTransferInvalid.Error()
+		return
+	}
+	if e != nil {
+		return
+	}
+
+	e = createTransaction(ctx, tx, transaction)
+	if e != nil {
+		return
+	}
+
+	_, e = tx.Exec(ctx, "UPDATE transfer_requests SET status = $1, transaction_id = $2 WHERE id = $3", models.TransferConfirmed, transaction.ID, id)
+	if e != nil {
+		return
+	}
+
+	e = tx.Commit(ctx)
+	return transaction.ID, e
+}
+
+func (t *TransferRequestsRepoImp) Cancel(ctx context.Context, id int32, senderID int32) error {
+	tag, err := t.postgres.Exec(ctx, "UPDATE transfer_requests SET status = $1 WHERE id = $2 AND sender_id = $3 AND status = $4", models.TransferCancelled, id, senderID, models.TransferPending)
+	if err != nil {
+		log.GetLog().Errorf("Unable to cancel transfer request. error: %v", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.ErrTransferInvalid.Error()
+	}
+	return nil
+}
@@ -17,6 +17,7 @@ type UsersRepo interface {
 	Verify(ctx context.Context, email string) error
 	GetByID(ctx context.Context, id int32) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) ([]*models.User, error)
+	GetByPhone(ctx context.Context, phone int64) ([]*models.User, error)
 	GetBalance(ctx context.Context, id int32) (int64, error)
 	DeleteByID(ctx context.Context, id int32) error
 	UpdateFirstName(ctx context.Context, id int32, newFirstName string) error
@@ -133,6 +134,27 @@ func (u *UserRepoImp) GetByEmail(ctx context.Context, email string) ([]*models.U
 	}
 	return users, nil
 }
+
+func (u *UserRepoImp) GetByPhone(ctx context.Context, phone int64) ([]*models.User, error) {
+	var users []*models.User
+	rows, err := u.postgres.Query(ctx, "SELECT id, first_name, last_name, email, phone, user_role, is_verified FROM users WHERE phone = $1", phone)
+	if err != nil {
+		log.GetLog().Errorf("Unable to get user by phone. error: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var user models.User
+		err = rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.Phone, &user.Role, &user.IsVerified)
+		if err != nil {
+			log.GetLog().Errorf("Unable to scan user. error: %v", err)
+			return nil, err
+		}
+		users = append(users, &user)
+	}
+	return users, nil
+}
+
 func (u *UserRepoImp) GetBalance(ctx context.Context, id int32) (int64, error) {
 	var balance int64
 	err := u.postgres.QueryRow(ctx, "SELECT balance FROM users WHERE id = $1", id).Scan(&balance)
@@ -0,0 +1,67 @@
+package utils
+
+import "barista/pkg/models"
+
+// pointInRing casts a ray east from the point and counts edge crossings.
+func pointInRing(lat, lng float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lng < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// PointInPolygon reports whether the point is inside the outer ring of a GeoJSON polygon and outside all of its holes.
+func PointInPolygon(lat, lng float64, polygon [][][2]float64) bool {
+	if len(polygon) == 0 || !pointInRing(lat, lng, polygon[0]) {
+		return false
+	}
+	for _, hole := range polygon[1:] {
+		if pointInRing(lat, lng, hole) {
+			return false
+		}
+	}
+	return true
+}
+
+func locateArea(boundaries []models.Boundary, lat, lng float64) (province int, city int) {
+	for _, boundary := range boundaries {
+		if (boundary.Level == models.AreaProvince && province != 0) || (boundary.Level == models.AreaCity && city != 0) {
+			continue
+		}
+		if lat < boundary.MinLat || lat > boundary.MaxLat || lng < boundary.MinLng || lng > boundary.MaxLng {
+			continue
+		}
+		for _, polygon := range boundary.Polygons {
+			if PointInPolygon(lat, lng, polygon) {
+				if boundary.Level == models.AreaProvince {
+					province = boundary.ID
+				} else {
+					city = boundary.ID
+				}
+				break
+			}
+		}
+	}
+
+	// a city outline is enough to know the province
+	if province == 0 && city != 0 {
+		province = models.CityByID[city].ProvinceID
+	}
+	return province, city
+}
+
+// AreaLookupAvailable reports whether any province or city outlines are loaded. Without them every point
+// is unknown, so callers should not offer lookups by position at all.
+func AreaLookupAvailable() bool {
+	return len(models.Boundaries) > 0
+}
+
+// LocateArea returns the province and city that contain the point, or zero for whichever is unknown.
+func LocateArea(lat, lng float64) (province int, city int) {
+	return locateArea(models.Boundaries, lat, lng)
+}
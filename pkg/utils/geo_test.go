@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"barista/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func square(minLng, minLat, maxLng, maxLat float64) [][2]float64 {
+	return [][2]float64{{minLng, minLat}, {maxLng, minLat}, {maxLng, maxLat}, {minLng, maxLat}, {minLng, minLat}}
+}
+
+func TestPointInPolygon(t *testing.T) {
+	polygon := [][][2]float64{square(0, 0, 10, 10), square(4, 4, 6, 6)}
+
+	assert.True(t, PointInPolygon(2, 2, polygon))
+	assert.False(t, PointInPolygon(5, 5, polygon))
+	assert.False(t, PointInPolygon(11, 2, polygon))
+	assert.False(t, PointInPolygon(2, -1, polygon))
+	assert.False(t, PointInPolygon(2, 2, nil))
+}
+
+func TestLocateArea(t *testing.T) {
+	boundaries := []models.Boundary{
+		{Level: models.AreaProvince, ID: 8, Polygons: [][][][2]float64{{square(50, 35, 52, 36)}}, MinLat: 35, MinLng: 50, MaxLat: 36, MaxLng: 52},
+		{Level: models.AreaCity, ID: 329, Polygons: [][][][2]float64{{square(51, 35.5, 51.6, 35.9)}}, MinLat: 35.5, MinLng: 51, MaxLat: 35.9, MaxLng: 51.6},
+	}
+
+	province, city := locateArea(boundaries, 35.7, 51.4)
+	assert.Equal(t, 8, province)
+	assert.Equal(t, 329, city)
+
+	province, city = locateArea(boundaries, 35.2, 50.5)
+	assert.Equal(t, 8, province)
+	assert.Equal(t, 0, city)
+
+	province, city = locateArea(boundaries, 30, 50)
+	assert.Equal(t, 0, province)
+	assert.Equal(t, 0, city)
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"barista/pkg/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MenuCSVHeader is the column order of an exported menu. Lists are separated by "|" within a cell and
+// options are written as JSON.
+var MenuCSVHeader = []string{"sku", "name", "category", "price", "ingredients", "allergens", "dietary", "calories", "caffeine_mg", "options"}
+
+const menuCSVListSeparator = "|"
+
+func splitMenuCSVList(cell string) []string {
+	var list []string
+	for _, value := range strings.Split(cell, menuCSVListSeparator) {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func parseMenuCSVInt(cell string) (*int32, error) {
+	if cell = strings.TrimSpace(cell); cell == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(cell, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	number := int32(value)
+	return &number, nil
+}
+
+// ParseMenuCSV reads menu items from CSV with a header row, along with the line each came from. Columns
+// may come in any order and only name, category and price are required. Rows that cannot be read are
+// reported by line and left out.
+func ParseMenuCSV(r io.Reader) ([]models.MenuItem, []int, []models.MenuImportError) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, []models.MenuImportError{{Row: 1, Message: "unable to read header: " + err.Error()}}
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+	for _, name := range []string{"name", "category", "price"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, []models.MenuImportError{{Row: 1, Message: "missing column " + name}}
+		}
+	}
+
+	var items []models.MenuItem
+	var lines []int
+	var errs []models.MenuImportError
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, models.MenuImportError{Row: line, Message: err.Error()})
+			continue
+		}
+
+		cell := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		fail := func(format string, args ...any) {
+			errs = append(errs, models.MenuImportError{Row: line, SKU: cell("sku"), Message: fmt.Sprintf(format, args...)})
+		}
+
+		item := models.MenuItem{
+			SKU:         cell("sku"),
+			Name:        cell("name"),
+			Category:    models.MenuItemCategory(cell("category")),
+			Ingredients: splitMenuCSVList(cell("ingredients")),
+		}
+		item.Price, err = strconv.ParseFloat(cell("price"), 64)
+		if err != nil {
+			fail("invalid price %q", cell("price"))
+			continue
+		}
+		for _, allergen := range splitMenuCSVList(cell("allergens")) {
+			item.Allergens = append(item.Allergens, models.Allergen(allergen))
+		}
+		for _, tag := range splitMenuCSVList(cell("dietary")) {
+			item.Dietary = append(item.Dietary, models.DietaryTag(tag))
+		}
+		item.Calories, err = parseMenuCSVInt(cell("calories"))
+		if err != nil {
+			fail("invalid calories %q", cell("calories"))
+			continue
+		}
+		item.CaffeineMg, err = parseMenuCSVInt(cell("caffeine_mg"))
+		if err != nil {
+			fail("invalid caffeine_mg %q", cell("caffeine_mg"))
+			continue
+		}
+		if options := cell("options"); options != "" {
+			err = json.Unmarshal([]byte(options), &item.Options)
+			if err != nil {
+				fail("invalid options: %v", err)
+				continue
+			}
+		}
+
+		items = append(items, item)
+		lines = append(lines, line)
+	}
+	return items, lines, errs
+}
+
+// WriteMenuCSV writes items in the form ParseMenuCSV reads.
+func WriteMenuCSV(w io.Writer, items []*models.MenuItem) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write(MenuCSVHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var allergens, dietary []string
+		for _, allergen := range item.Allergens {
+			allergens = append(allergens, string(allergen))
+		}
+		for _, tag := range item.Dietary {
+			dietary = append(dietary, string(tag))
+		}
+		var ingredients []string
+		for _, ingredient := range item.Ingredients {
+			if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+				ingredients = append(ingredients, ingredient)
+			}
+		}
+
+		calories, caffeine, options := "", "", ""
+		if item.Calories != nil {
+			calories = strconv.Itoa(int(*item.Calories))
+		}
+		if item.CaffeineMg != nil {
+			caffeine = strconv.Itoa(int(*item.CaffeineMg))
+		}
+		if len(item.Options) > 0 {
+			data, err := json.Marshal(item.Options)
+			if err != nil {
+				return err
+			}
+			options = string(data)
+		}
+
+		err = writer.Write([]string{
+			item.SKU, item.Name, string(item.Category), strconv.FormatFloat(item.Price, 'f', -1, 64),
+			strings.Join(ingredients, menuCSVListSeparator), strings.Join(allergens, menuCSVListSeparator),
+			strings.Join(dietary, menuCSVListSeparator), calories, caffeine, options,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"barista/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMenuCSVRoundTrip(t *testing.T) {
+	calories := int32(120)
+	items := []*models.MenuItem{
+		{
+			SKU: "LAT-1", Name: "لاته", Category: models.MenuItemCategoryCoffee, Price: 70000,
+			Ingredients: []string{"اسپرسو", " شیر"}, Allergens: []models.Allergen{models.AllergenDairy},
+			Dietary: []models.DietaryTag{models.DietaryVegetarian}, Calories: &calories,
+			Options: []models.MenuOptionGroup{{Name: "سایز", Kind: models.MenuOptionVariant, Required: true, MinSelect: 1, MaxSelect: 1,
+				Choices: []models.MenuOption{{Name: "بزرگ", PriceDelta: 15000}}}},
+		},
+		{Name: "Tea, green", Category: models.MenuItemCategoryTea, Price: 60000.5},
+	}
+
+	var buf strings.Builder
+	assert.NoError(t, WriteMenuCSV(&buf, items))
+
+	parsed, lines, errs := ParseMenuCSV(strings.NewReader(buf.String()))
+	assert.Empty(t, errs)
+	assert.Len(t, parsed, 2)
+	assert.Equal(t, []int{2, 3}, lines)
+	assert.Equal(t, "LAT-1", parsed[0].SKU)
+	assert.Equal(t, []string{"اسپرسو", "شیر"}, parsed[0].Ingredients)
+	assert.Equal(t, items[0].Allergens, parsed[0].Allergens)
+	assert.Equal(t, int32(120), *parsed[0].Calories)
+	assert.Nil(t, parsed[0].CaffeineMg)
+	assert.Equal(t, items[0].Options, parsed[0].Options)
+	assert.Equal(t, "Tea, green", parsed[1].Name)
+	assert.Equal(t, 60000.5, parsed[1].Price)
+}
+
+func TestParseMenuCSVErrors(t *testing.T) {
+	_, _, errs := ParseMenuCSV(strings.NewReader("name,price\nlatte,1000\n"))
+	assert.Equal(t, []models.MenuImportError{{Row: 1, Message: "missing column category"}}, errs)
+
+	items, lines, errs := ParseMenuCSV(strings.NewReader("Price,Name,Category,SKU\n1000,latte,coffee,A\nfree,mocha,coffee,B\n2000,tea,tea\n"))
+	assert.Len(t, items, 2)
+	assert.Equal(t, []int{2, 4}, lines)
+	assert.Equal(t, "", items[1].SKU)
+	assert.Equal(t, []models.MenuImportError{{Row: 3, SKU: "B", Message: `invalid price "free"`}}, errs)
+}
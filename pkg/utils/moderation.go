@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"barista/pkg/models"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// profanity is kept squeezed (see squeeze) so "fuuuck" and "fuck" compare equal. Words that are also
+// everyday Persian, like "کس" in "هیچ کس", are left out on purpose.
+var profanity = map[string]bool{}
+
+func init() {
+	for _, word := range []string{
+		"fuck", "fucker", "fucking", "motherfucker", "shit", "bullshit", "bitch", "bastard", "asshole", "cunt", "whore", "slut",
+		"کیر", "کون", "کونی", "جنده", "کسکش", "کسخل", "مادرجنده", "حرومزاده", "حرامزاده", "لاشی", "پفیوز", "گوه", "جاکش",
+	} {
+		profanity[squeeze(word)] = true
+	}
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+var (
+	linkPattern = regexp.MustCompile(`(?i)(https?://|www\.|t\.me/|\b[a-z0-9-]+\.(com|ir|net|org|io|me|co|info|xyz|app|link|site)\b)`)
+	// Iranian mobile and landline numbers, once separators are gone
+	phonePattern     = regexp.MustCompile(`(\+98|0098|0)?9\d{9}|0\d{10}`)
+	phoneSeparators  = regexp.MustCompile(`[\s\-.()_]+`)
+	nonLetterPattern = regexp.MustCompile(`[^\p{L}]+`)
+)
+
+// squeeze collapses runs of the same letter, so stretched spellings match the list.
+func squeeze(s string) string {
+	var b strings.Builder
+	var last rune
+	for _, r := range s {
+		if r != last {
+			b.WriteRune(r)
+		}
+		last = r
+	}
+	return b.String()
+}
+
+func hasLatin(s string) bool {
+	for _, r := range s {
+		if r < unicode.MaxASCII && unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// moderationWords splits normalized text into words for the profanity check. Leetspeak is undone in
+// Latin words, and runs of single letters are joined so "f u c k" is seen as one word.
+func moderationWords(text string) []string {
+	var words []string
+	var spelled strings.Builder
+	flush := func() {
+		if len([]rune(spelled.String())) > 1 {
+			words = append(words, spelled.String())
+		}
+		spelled.Reset()
+	}
+
+	// a ZWNJ inside a word only changes how it is drawn, so it must not split a compound like "مادر‌جنده"
+	for _, field := range strings.Fields(NormalizePersian(strings.ReplaceAll(text, "\u200c", ""))) {
+		if hasLatin(field) {
+			field = leetReplacer.Replace(field)
+		}
+		for _, word := range nonLetterPattern.Split(field, -1) {
+			if word == "" {
+				continue
+			}
+			if len([]rune(word)) == 1 {
+				spelled.WriteString(word)
+				continue
+			}
+			flush()
+			words = append(words, word)
+		}
+	}
+	flush()
+	return words
+}
+
+// FindProfanity returns the listed words used in text, in Persian or English.
+func FindProfanity(text string) []string {
+	var found []string
+	for _, word := range moderationWords(text) {
+		if profanity[squeeze(word)] {
+			found = append(found, word)
+		}
+	}
+	return found
+}
+
+func ContainsLink(text string) bool {
+	return linkPattern.MatchString(NormalizePersian(text))
+}
+
+func ContainsPhoneNumber(text string) bool {
+	return phonePattern.MatchString(phoneSeparators.ReplaceAllString(NormalizePersian(text), ""))
+}
+
+// ModerationFlags lists what is wrong with a piece of user text; nil means it can go live.
+func ModerationFlags(text string) []string {
+	var flags []string
+	if len(FindProfanity(text)) > 0 {
+		flags = append(flags, models.ModerationProfanity)
+	}
+	if ContainsLink(text) {
+		flags = append(flags, models.ModerationLink)
+	}
+	if ContainsPhoneNumber(text) {
+		flags = append(flags, models.ModerationPhone)
+	}
+	return flags
+}
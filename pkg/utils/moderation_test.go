@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"barista/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindProfanity(t *testing.T) {
+	assert.Empty(t, FindProfanity("قهوه عالی بود، هیچ کس ناراضی نبود"))
+	assert.Empty(t, FindProfanity("Great latte, I will pass by again"))
+	assert.Equal(t, []string{"shit"}, FindProfanity("this place is SHIT"))
+	assert.Equal(t, []string{"fuuuck"}, FindProfanity("fuuuck this"))
+	assert.Equal(t, []string{"shit"}, FindProfanity("$h1t coffee"))
+	assert.Equal(t, []string{"fuck"}, FindProfanity("f u c k"))
+	assert.Equal(t, []string{"fuck"}, FindProfanity("f.u.c.k them"))
+	assert.Equal(t, []string{"جاکش"}, FindProfanity("صاحبش جاكش است"))
+	assert.Equal(t, []string{"مادرجنده"}, FindProfanity("مادر\u200cجنده"))
+}
+
+func TestContainsLink(t *testing.T) {
+	assert.True(t, ContainsLink("بیاین اینجا https://example.com"))
+	assert.True(t, ContainsLink("www.cheapcoffee.ir"))
+	assert.True(t, ContainsLink("join t.me/coffee_offers"))
+	assert.True(t, ContainsLink("visit mycafe.com now"))
+	assert.False(t, ContainsLink("قهوه خوب بود. سرویس هم عالی."))
+}
+
+func TestContainsPhoneNumber(t *testing.T) {
+	assert.True(t, ContainsPhoneNumber("تماس: 09121234567"))
+	assert.True(t, ContainsPhoneNumber("۰۹۱۲ ۱۲۳ ۴۵۶۷"))
+	assert.True(t, ContainsPhoneNumber("+98 912-123-4567"))
+	assert.True(t, ContainsPhoneNumber("021-88776655"))
+	assert.False(t, ContainsPhoneNumber("قیمت 150,000 تومان بود"))
+	assert.False(t, ContainsPhoneNumber("ساعت 9 تا 12"))
+}
+
+func TestModerationFlags(t *testing.T) {
+	assert.Nil(t, ModerationFlags("یک کافه دنج با قهوه خوب"))
+	assert.Equal(t, []string{models.ModerationLink, models.ModerationPhone},
+		ModerationFlags("www.offers.ir یا 09121234567"))
+}
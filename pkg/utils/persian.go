@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+var persianReplacer = strings.NewReplacer(
+	"ي", "ی", "ى", "ی", "ئ", "ی",
+	"ك", "ک",
+	"ة", "ه", "ۀ", "ه",
+	"أ", "ا", "إ", "ا", "آ", "ا", "ٱ", "ا",
+	"ؤ", "و",
+	"۰", "0", "۱", "1", "۲", "2", "۳", "3", "۴", "4", "۵", "5", "۶", "6", "۷", "7", "۸", "8", "۹", "9",
+	"٠", "0", "١", "1", "٢", "2", "٣", "3", "٤", "4", "٥", "5", "٦", "6", "٧", "7", "٨", "8", "٩", "9",
+	"\u200c", " ",
+	"\u200b", "", "\u200d", "", "\ufeff", "", "\u0640", "",
+)
+
+// NormalizePersian folds Arabic letter and digit variants into their Persian/ASCII forms, drops diacritics,
+// turns ZWNJ into a space and lowercases Latin text, so that differently typed spellings compare equal.
+// Keep it in sync with the persian_normalize SQL function in the cafes repo.
+func NormalizePersian(s string) string {
+	s = persianReplacer.Replace(s)
+	s = strings.Map(func(r rune) rune {
+		// harakat, tanvin, shadda, sukun and superscript alef
+		if (r >= '\u064b' && r <= '\u0652') || r == '\u0670' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// persianKeyboard maps keys of a US layout to the letters they produce on the common Persian layout.
+var persianKeyboard = map[rune]rune{
+	'q': 'ض', 'w': 'ص', 'e': 'ث', 'r': 'ق', 't': 'ف', 'y': 'غ', 'u': 'ع', 'i': 'ه', 'o': 'خ', 'p': 'ح', '[': 'ج', ']': 'چ',
+	'a': 'ش', 's': 'س', 'd': 'ی', 'f': 'ب', 'g': 'ل', 'h': 'ا', 'j': 'ت', 'k': 'ن', 'l': 'م', ';': 'ک', '\'': 'گ',
+	'z': 'ظ', 'x': 'ط', 'c': 'ز', 'v': 'ر', 'b': 'ذ', 'n': 'د', 'm': 'پ', ',': 'و', '\\': 'پ', 'C': 'ژ',
+}
+
+// PersianFromLatinKeyboard rewrites text typed with the keyboard left on the English layout, e.g. ";hti" becomes "کافه".
+// The second result reports whether anything was rewritten.
+func PersianFromLatinKeyboard(s string) (string, bool) {
+	changed := false
+	s = strings.Map(func(r rune) rune {
+		if p, ok := persianKeyboard[r]; ok {
+			changed = true
+			return p
+		}
+		if p, ok := persianKeyboard[unicode.ToLower(r)]; ok {
+			changed = true
+			return p
+		}
+		return r
+	}, s)
+	return s, changed
+}
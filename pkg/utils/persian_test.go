@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNormalizePersian(t *testing.T) {
+	assert.Equal(t, "کافه باکارا", NormalizePersian("كافه باكارا"))
+	assert.Equal(t, "چای ایرانی", NormalizePersian("چاي ايراني"))
+	assert.Equal(t, "می خواهم", NormalizePersian("می\u200cخواهم"))
+	assert.Equal(t, "کافه 123", NormalizePersian("کافه ۱۲۳"))
+	assert.Equal(t, "کافه 45", NormalizePersian("کافه ٤٥"))
+	assert.Equal(t, "محمد", NormalizePersian("مُحَمَّد"))
+	assert.Equal(t, "latte art", NormalizePersian("  Latte   ART "))
+}
+
+func TestPersianFromLatinKeyboard(t *testing.T) {
+	s, changed := PersianFromLatinKeyboard(";hti")
+	assert.True(t, changed)
+	assert.Equal(t, "کافه", s)
+
+	s, _ = PersianFromLatinKeyboard("jivhk")
+	assert.Equal(t, "تهران", s)
+
+	s, _ = PersianFromLatinKeyboard("Chgi")
+	assert.Equal(t, "ژاله", s)
+
+	s, changed = PersianFromLatinKeyboard("کافه 12")
+	assert.False(t, changed)
+	assert.Equal(t, "کافه 12", s)
+}
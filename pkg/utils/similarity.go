@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// Jaccard is the share of distinct values two lists have in common; two empty lists share nothing.
+func Jaccard(a []string, b []string) float64 {
+	set := map[string]bool{}
+	for _, value := range a {
+		set[value] = true
+	}
+
+	union := len(set)
+	common := 0
+	seen := map[string]bool{}
+	for _, value := range b {
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		if set[value] {
+			common++
+		} else {
+			union++
+		}
+	}
+
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+// Cosine compares two sparse vectors.
+func Cosine(a map[int32]float64, b map[int32]float64) float64 {
+	var dot, normA, normB float64
+	for key, value := range a {
+		normA += value * value
+		dot += value * b[key]
+	}
+	for _, value := range b {
+		normB += value * value
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// TopK returns up to k keys with the highest scores, ties broken by the smaller key so results are stable.
+func TopK(scores map[int32]float64, k int) []int32 {
+	keys := make([]int32, 0, len(scores))
+	for key := range scores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if scores[keys[i]] != scores[keys[j]] {
+			return scores[keys[i]] > scores[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) > k {
+		keys = keys[:k]
+	}
+	return keys
+}
+
+// PrecisionRecallAtK scores the first k recommendations against the items known to be relevant.
+func PrecisionRecallAtK(recommended []int32, relevant map[int32]bool, k int) (float64, float64) {
+	if k <= 0 || len(relevant) == 0 {
+		return 0, 0
+	}
+	if len(recommended) > k {
+		recommended = recommended[:k]
+	}
+
+	hits := 0
+	for _, key := range recommended {
+		if relevant[key] {
+			hits++
+		}
+	}
+	return float64(hits) / float64(k), float64(hits) / float64(len(relevant))
+}
+
+// BayesianAverage pulls an average towards the prior mean as if priorWeight extra votes of that mean had been cast,
+// so a couple of perfect ratings cannot outrank a long record of good ones.
+func BayesianAverage(sum float64, count int, priorMean float64, priorWeight float64) float64 {
+	if float64(count)+priorWeight == 0 {
+		return 0
+	}
+	return (priorWeight*priorMean + sum) / (priorWeight + float64(count))
+}
+
+// WilsonScore is the lower bound of the 95% confidence interval for the share of positive votes, so a
+// review with 40 of 50 helpful votes ranks above one with a single helpful vote.
+func WilsonScore(positive int32, negative int32) float64 {
+	n := float64(positive + negative)
+	if n == 0 {
+		return 0
+	}
+
+	const z = 1.96
+	p := float64(positive) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJaccard(t *testing.T) {
+	assert.Equal(t, 1.0, Jaccard([]string{"a", "b"}, []string{"b", "a"}))
+	assert.Equal(t, 1.0/3, Jaccard([]string{"a", "b"}, []string{"b", "c", "c"}))
+	assert.Equal(t, 0.0, Jaccard([]string{"a"}, []string{"b"}))
+	assert.Equal(t, 0.0, Jaccard(nil, nil))
+}
+
+func TestCosine(t *testing.T) {
+	assert.InDelta(t, 1.0, Cosine(map[int32]float64{1: 1, 2: 2}, map[int32]float64{1: 2, 2: 4}), 1e-9)
+	assert.InDelta(t, 0.0, Cosine(map[int32]float64{1: 1}, map[int32]float64{2: 1}), 1e-9)
+	assert.InDelta(t, 0.5, Cosine(map[int32]float64{1: 1, 2: 1}, map[int32]float64{2: 1, 3: 1}), 1e-9)
+	assert.Equal(t, 0.0, Cosine(map[int32]float64{}, map[int32]float64{1: 1}))
+}
+
+func TestTopK(t *testing.T) {
+	scores := map[int32]float64{1: 0.5, 2: 0.9, 3: 0.5, 4: 0.1}
+
+	assert.Equal(t, []int32{2, 1, 3}, TopK(scores, 3))
+	assert.Equal(t, []int32{2, 1, 3, 4}, TopK(scores, 10))
+	assert.Empty(t, TopK(nil, 3))
+}
+
+func TestPrecisionRecallAtK(t *testing.T) {
+	relevant := map[int32]bool{2: true, 5: true, 9: true, 11: true}
+
+	precision, recall := PrecisionRecallAtK([]int32{2, 3, 5, 7, 9}, relevant, 4)
+	assert.Equal(t, 0.5, precision)
+	assert.Equal(t, 0.5, recall)
+
+	precision, recall = PrecisionRecallAtK([]int32{1}, nil, 4)
+	assert.Equal(t, 0.0, precision)
+	assert.Equal(t, 0.0, recall)
+}
+
+func TestBayesianAverage(t *testing.T) {
+	single := BayesianAverage(5, 1, 4, 10)
+	many := BayesianAverage(480, 100, 4, 10)
+
+	assert.InDelta(t, 4.0909, single, 1e-4)
+	assert.InDelta(t, 4.7273, many, 1e-4)
+	assert.Greater(t, many, single)
+	assert.Equal(t, 4.0, BayesianAverage(0, 0, 4, 10))
+	assert.Equal(t, 0.0, BayesianAverage(0, 0, 4, 0))
+}
+
+func TestWilsonScore(t *testing.T) {
+	assert.Equal(t, 0.0, WilsonScore(0, 0))
+	assert.InDelta(t, 0.2065, WilsonScore(1, 0), 1e-4)
+	assert.InDelta(t, 0.6696, WilsonScore(40, 10), 1e-4)
+	assert.Greater(t, WilsonScore(40, 10), WilsonScore(3, 0))
+	assert.Less(t, WilsonScore(0, 5), WilsonScore(1, 5))
+}
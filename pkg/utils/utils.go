@@ -18,6 +18,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// IranLocation is used for business hours and daily jobs. Iran has no daylight saving time since 2022.
+var IranLocation = time.FixedZone("Asia/Tehran", 3*60*60+30*60)
+
 func GenerateRandomStr(length int) string {
 	charset := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// visitorKey keys the hashes of anonymous visitors' addresses. Without SECRET_KEY it is random, so keys
+// only match within one run of the server.
+var visitorKey = func() []byte {
+	if SECRET_KEY != "" {
+		return []byte(SECRET_KEY)
+	}
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+	return key
+}()
+
+func visitorHash(key []byte, clientIP string, at time.Time) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(at.In(IranLocation).Format(time.DateOnly)))
+	mac.Write([]byte{0})
+	mac.Write([]byte(clientIP))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VisitorHash is an opaque key for an anonymous visitor's address on the day of at. It needs the server
+// secret to compute, and the same address gets a different key each day.
+func VisitorHash(clientIP string, at time.Time) string {
+	return visitorHash(visitorKey, clientIP, at)
+}
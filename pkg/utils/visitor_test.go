@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVisitorHash(t *testing.T) {
+	key := []byte("secret")
+	morning := time.Date(2026, time.March, 10, 8, 0, 0, 0, IranLocation)
+	evening := time.Date(2026, time.March, 10, 23, 0, 0, 0, IranLocation)
+	nextDay := time.Date(2026, time.March, 11, 0, 5, 0, 0, IranLocation)
+
+	hash := visitorHash(key, "5.160.12.7", morning)
+	assert.Equal(t, hash, visitorHash(key, "5.160.12.7", evening))
+	assert.NotEqual(t, hash, visitorHash(key, "5.160.12.7", nextDay))
+	assert.NotEqual(t, hash, visitorHash(key, "5.160.12.8", morning))
+	assert.NotEqual(t, hash, visitorHash([]byte("other"), "5.160.12.7", morning))
+	assert.NotEqual(t, fmt.Sprintf("%x", sha256.Sum256([]byte("5.160.12.7"))), hash)
+}